@@ -4,28 +4,16 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 
-	rh "github.com/rinosukmandityo/maknews/repositories/helper"
-	"github.com/rinosukmandityo/maknews/services/logic"
+	svc "github.com/rinosukmandityo/maknews/services"
 )
 
-func RegisterHandler() *chi.Mux {
+// RegisterHandler only serves newsSvc, the outbox relay and the consumer are started by their own entry points in cmd
+func RegisterHandler(newsSvc svc.NewsService) *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 
-	newsRepo := rh.ChooseRepo()
-	elasticRepo := rh.ElasticRepo()
-	kafkaRepo := rh.KafkaConnection()
-	redisRepo := rh.RedisRepo()
-
-	newsSvc := logic.NewNewsService(newsRepo, redisRepo, elasticRepo, kafkaRepo)
-	kafkaSvc := logic.NewKafkaService(kafkaRepo)
-
-	go func() { // just assume that this is another service that register kafka topic
-		kafkaSvc.ReadMessage(newsRepo, elasticRepo)
-	}()
-
 	registerNewsHandler(r, NewNewsHandler(newsSvc))
 
 	return r
@@ -34,8 +22,11 @@ func RegisterHandler() *chi.Mux {
 func registerNewsHandler(r *chi.Mux, handler NewsHandler) {
 	// Subrouters:
 	r.Route("/news", func(r chi.Router) {
-		r.Post("/", handler.Post) // POST /news
-		r.Get("/", handler.Get)   // GET /news?offset=0&limit=10
+		r.Post("/", handler.Post)         // POST /news
+		r.Post("/bulk", handler.PostBulk) // POST /news/bulk
+		r.Get("/", handler.Get)           // GET /news?offset=0&limit=10
+		r.Get("/search", handler.Search)  // GET /news/search?q=alex&offset=0&limit=10&fragment_size=100
+		r.Get("/stats", handler.Stats)    // GET /news/stats?interval=day&time_zone=Asia/Jakarta&authors=10
 		// Subrouters:
 		r.Route("/{id}", func(r chi.Router) {
 			r.Use(handler.NewsCtx)
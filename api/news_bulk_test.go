@@ -0,0 +1,88 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/rinosukmandityo/maknews/api"
+	m "github.com/rinosukmandityo/maknews/models"
+	mem "github.com/rinosukmandityo/maknews/repositories/memory"
+	"github.com/rinosukmandityo/maknews/services/logic"
+
+	"github.com/go-chi/chi"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v -run TestPostBulk
+*/
+
+func newMemoryServer() *httptest.Server {
+	newsSvc := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10),
+		mem.NewElasticRepository())
+	handler := NewNewsHandler(newsSvc)
+	r := chi.NewRouter()
+	r.Post("/news/bulk", handler.PostBulk)
+	return httptest.NewServer(r)
+}
+
+func postBulk(t *testing.T, ts *httptest.Server, contentType string, body []byte) (*http.Response, []m.BulkResult) {
+	resp, e := http.Post(ts.URL+"/news/bulk", contentType, bytes.NewReader(body))
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to post data %s", e.Error())
+	}
+	defer resp.Body.Close()
+	res := []m.BulkResult{}
+	if resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusMultiStatus {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		if e := json.Unmarshal(buf.Bytes(), &res); e != nil {
+			t.Fatalf("[ERROR] - Failed to decode response %s", e.Error())
+		}
+	}
+	return resp, res
+}
+
+func TestPostBulk(t *testing.T) {
+	ts := newMemoryServer()
+	defer ts.Close()
+
+	data := []m.News{{
+		ID:      1,
+		Author:  "Alex",
+		Body:    "Hello this is news from Alex",
+		Created: time.Now().UTC(),
+	}, {
+		ID:      2,
+		Author:  "Bacca",
+		Body:    "Hello this is news from Bacca",
+		Created: time.Now().UTC(),
+	}}
+	body, _ := json.Marshal(data)
+
+	t.Run("Case: Positive Test", func(t *testing.T) {
+		resp, res := postBulk(t, ts, ContentTypeJson, body)
+		if resp.StatusCode != http.StatusCreated || len(res) != 2 || !res[0].Success || !res[1].Success {
+			t.Errorf("[ERROR] - Status should be 'Status Created' (201) %d %+v", resp.StatusCode, res)
+		}
+	})
+	t.Run("Case: Partial Failure", func(t *testing.T) {
+		data = append(data, m.News{ID: 3, Author: "Chicarito", Created: time.Now().UTC()})
+		body, _ := json.Marshal(data)
+		resp, res := postBulk(t, ts, ContentTypeJson, body)
+		if resp.StatusCode != http.StatusMultiStatus || len(res) != 3 || res[0].Success || res[1].Success || !res[2].Success {
+			t.Errorf("[ERROR] - Status should be 'Multi-Status' (207) %d %+v", resp.StatusCode, res)
+		}
+	})
+	t.Run("Case: Empty Data", func(t *testing.T) {
+		if resp, _ := postBulk(t, ts, ContentTypeJson, []byte("[]")); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("[ERROR] - Status should be 'Bad Request' (400) %d", resp.StatusCode)
+		}
+	})
+}
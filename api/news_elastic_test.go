@@ -0,0 +1,230 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	. "github.com/rinosukmandityo/maknews/api"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	mem "github.com/rinosukmandityo/maknews/repositories/memory"
+	"github.com/rinosukmandityo/maknews/services/logic"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v -run "TestSearch|TestStats"
+*/
+
+// newElasticServer serves the news of Alex and Bacca created one second apart
+func newElasticServer(t *testing.T, elastic bool) *httptest.Server {
+	var newsSvc = logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10), nil)
+	if elastic {
+		newsSvc = logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10),
+			mem.NewElasticRepository())
+	}
+	for i, v := range []m.News{
+		{ID: 1, Author: "Alex", Body: "Hello Alex, this is news from Alex"},
+		{ID: 2, Author: "Bacca", Body: "Alex met Bacca"},
+	} {
+		data := v
+		data.Created = time.Now().UTC().Add(time.Duration(i) * time.Second)
+		if e := newsSvc.Store(context.Background(), &data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+	handler := NewNewsHandler(newsSvc)
+	r := chi.NewRouter()
+	r.Get("/news/search", handler.Search)
+	r.Get("/news/stats", handler.Stats)
+	return httptest.NewServer(r)
+}
+
+func search(t *testing.T, ts *httptest.Server, query url.Values) (*http.Response, m.SearchResult) {
+	resp, e := http.Get(ts.URL + "/news/search?" + query.Encode())
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to search %s", e.Error())
+	}
+	defer resp.Body.Close()
+	res := m.SearchResult{}
+	if resp.StatusCode == http.StatusOK {
+		if e := json.NewDecoder(resp.Body).Decode(&res); e != nil {
+			t.Fatalf("[ERROR] - Failed to decode response %s", e.Error())
+		}
+	}
+	return resp, res
+}
+
+func TestSearch(t *testing.T) {
+	ts := newElasticServer(t, true)
+	defer ts.Close()
+
+	resp, res := search(t, ts, url.Values{"q": {"alex"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("[ERROR] - Expected status %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+	if res.Total != 2 || len(res.Hits) != 2 {
+		t.Fatalf("[ERROR] - Expected 2 hits but got %d of %d", len(res.Hits), res.Total)
+	}
+	if res.Hits[0].News.ID != 1 || res.Hits[0].Score < res.Hits[1].Score {
+		t.Errorf("[ERROR] - Expected news 1 with the highest score first but got %+v", res.Hits)
+	}
+
+	_, res = search(t, ts, url.Values{"q": {"bacca"}, "pre_tag": {"<mark>"}, "post_tag": {"</mark>"}, "fragment_size": {"10"}})
+	if len(res.Hits) != 1 {
+		t.Fatalf("[ERROR] - Expected 1 hit but got %+v", res)
+	}
+	if author := res.Hits[0].Highlights["author"]; len(author) != 1 || author[0] != "<mark>Bacca</mark>" {
+		t.Errorf("[ERROR] - Expected highlighted author but got %q", author)
+	}
+	if body := res.Hits[0].Highlights["body"]; len(body) != 1 || body[0] != "<mark>Bacca</mark>" {
+		t.Errorf("[ERROR] - Expected one highlighted body fragment but got %q", body)
+	}
+
+	_, res = search(t, ts, url.Values{"q": {"alex"}, "offset": {"1"}, "limit": {"1"}})
+	if res.Total != 2 || len(res.Hits) != 1 || res.Hits[0].News.ID != 2 {
+		t.Errorf("[ERROR] - Expected news 2 on the second page but got %+v", res)
+	}
+
+	for _, query := range []url.Values{
+		{"q": {" "}},
+		{"q": {"alex"}, "offset": {"-1"}},
+		{"q": {"alex"}, "limit": {"-1"}},
+		{"q": {"alex"}, "fragment_size": {"-1"}},
+		{"q": {"alex"}, "pre_tag": {"<mark>"}},
+		{"q": {"alex"}, "pre_tag": {"<script>"}, "post_tag": {"</script>"}},
+	} {
+		if resp, _ := search(t, ts, query); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("[ERROR] - Expected status %d for %v but got %d", http.StatusBadRequest, query, resp.StatusCode)
+		}
+	}
+}
+
+func TestSearchUnavailable(t *testing.T) {
+	ts := newElasticServer(t, false)
+	defer ts.Close()
+
+	if resp, _ := search(t, ts, url.Values{"q": {"alex"}}); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("[ERROR] - Expected status %d but got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+}
+
+// failingElastic fails every search like an elasticsearch cluster that answers with errors
+type failingElastic struct {
+	repo.ElasticRepository
+}
+
+func (failingElastic) Search(ctx context.Context, param m.SearchPayload) (*m.ElasticSearchResult, error) {
+	return nil, errors.New("elastic: Error 500 (Internal Server Error)")
+}
+
+func (failingElastic) Stats(ctx context.Context, param m.StatsPayload) (*m.NewsStats, error) {
+	return nil, errors.New("elastic: Error 500 (Internal Server Error)")
+}
+
+func newFailingElasticServer() *httptest.Server {
+	newsSvc := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10),
+		failingElastic{mem.NewElasticRepository()})
+	handler := NewNewsHandler(newsSvc)
+	r := chi.NewRouter()
+	r.Get("/news/search", handler.Search)
+	r.Get("/news/stats", handler.Stats)
+	return httptest.NewServer(r)
+}
+
+func TestSearchBackendFailure(t *testing.T) {
+	ts := newFailingElasticServer()
+	defer ts.Close()
+
+	if resp, _ := search(t, ts, url.Values{"q": {"alex"}}); resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("[ERROR] - Expected status %d but got %d", http.StatusBadGateway, resp.StatusCode)
+	}
+}
+
+func getStats(t *testing.T, ts *httptest.Server, contentType string, query url.Values) (*http.Response, m.NewsStats) {
+	req, e := http.NewRequest(http.MethodGet, ts.URL+"/news/stats?"+query.Encode(), nil)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to create request %s", e.Error())
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, e := http.DefaultClient.Do(req)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get stats %s", e.Error())
+	}
+	defer resp.Body.Close()
+	res := m.NewsStats{}
+	if resp.StatusCode != http.StatusOK {
+		return resp, res
+	}
+	if contentType == ContentTypeMsgPack {
+		e = msgpack.NewDecoder(resp.Body).Decode(&res)
+	} else {
+		e = json.NewDecoder(resp.Body).Decode(&res)
+	}
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to decode response %s", e.Error())
+	}
+	return resp, res
+}
+
+func TestStats(t *testing.T) {
+	ts := newElasticServer(t, true)
+	defer ts.Close()
+
+	for _, contentType := range []string{ContentTypeJson, ContentTypeMsgPack} {
+		t.Run("Case: "+contentType, func(t *testing.T) {
+			resp, res := getStats(t, ts, contentType, url.Values{"interval": {"year"}, "authors": {"1"}})
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("[ERROR] - Expected status %d but got %d", http.StatusOK, resp.StatusCode)
+			}
+			if res.Total != 2 || len(res.Authors) != 1 || res.Authors[0].Author != "Alex" || res.OtherAuthors != 1 {
+				t.Errorf("[ERROR] - Expected Alex and 1 news of other authors but got %+v", res)
+			}
+			if len(res.Histogram) != 1 || res.Histogram[0].Count != 2 {
+				t.Errorf("[ERROR] - Expected one yearly bucket of 2 news but got %+v", res.Histogram)
+			}
+		})
+	}
+
+	for _, query := range []url.Values{
+		{"interval": {"fortnight"}},
+		{"time_zone": {"Mars/Olympus"}},
+		{"authors": {"-1"}},
+		{"from": {"yesterday"}},
+		{"authors": {"10001"}},
+		{"interval": {"minute"}, "from": {"2020-05-01T00:00:00Z"}, "to": {"2020-06-01T00:00:00Z"}},
+	} {
+		if resp, _ := getStats(t, ts, ContentTypeJson, query); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("[ERROR] - Expected status %d for %v but got %d", http.StatusBadRequest, query, resp.StatusCode)
+		}
+	}
+}
+
+func TestStatsUnavailable(t *testing.T) {
+	ts := newElasticServer(t, false)
+	defer ts.Close()
+
+	if resp, _ := getStats(t, ts, ContentTypeJson, url.Values{}); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("[ERROR] - Expected status %d but got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+}
+
+func TestStatsBackendFailure(t *testing.T) {
+	ts := newFailingElasticServer()
+	defer ts.Close()
+
+	if resp, _ := getStats(t, ts, ContentTypeJson, url.Values{}); resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("[ERROR] - Expected status %d but got %d", http.StatusBadGateway, resp.StatusCode)
+	}
+}
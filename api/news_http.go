@@ -2,9 +2,13 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rinosukmandityo/maknews/helper"
 	m "github.com/rinosukmandityo/maknews/models"
@@ -17,7 +21,10 @@ import (
 type NewsHandler interface {
 	NewsCtx(http.Handler) http.Handler
 	Get(http.ResponseWriter, *http.Request)
+	Search(http.ResponseWriter, *http.Request)
+	Stats(http.ResponseWriter, *http.Request)
 	Post(http.ResponseWriter, *http.Request)
+	PostBulk(http.ResponseWriter, *http.Request)
 	Update(http.ResponseWriter, *http.Request)
 	Delete(http.ResponseWriter, *http.Request)
 }
@@ -36,14 +43,16 @@ func (u *newshandler) NewsCtx(next http.Handler) http.Handler {
 		idInt, e := strconv.Atoi(id)
 		if e != nil {
 			http.Error(w, helper.ErrDataInvalid.Error(), http.StatusBadRequest)
+			return
 		}
-		data, e := u.newsService.GetById(idInt)
+		data, e := u.newsService.GetById(r.Context(), idInt)
 		if e != nil {
 			if errors.Cause(e) == helper.ErrDataNotFound {
 				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 				return
 			}
 			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
 		}
 		ctx := context.WithValue(r.Context(), "news", data)
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -73,7 +82,7 @@ func (u *newshandler) Get(w http.ResponseWriter, r *http.Request) {
 
 	contentType := r.Header.Get("Content-Type")
 
-	data, e := u.newsService.GetData(payload)
+	data, e := u.newsService.GetData(r.Context(), payload)
 	if e != nil {
 		if errors.Cause(e) == helper.ErrDataNotFound {
 			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
@@ -90,6 +99,110 @@ func (u *newshandler) Get(w http.ResponseWriter, r *http.Request) {
 	SetupResponse(w, contentType, respBody, http.StatusFound)
 }
 
+func (u *newshandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	payload := m.SearchPayload{
+		Query:  strings.TrimSpace(q.Get("q")),
+		Offset: 0,
+		Limit:  10,
+		Highlight: m.Highlight{
+			PreTag:  q.Get("pre_tag"),
+			PostTag: q.Get("post_tag"),
+		},
+	}
+	if payload.Query == "" {
+		http.Error(w, "Query can not be empty", http.StatusBadRequest)
+		return
+	}
+	if q.Get("offset") != "" {
+		payload.Offset, _ = strconv.Atoi(q.Get("offset"))
+		if payload.Offset < 0 {
+			http.Error(w, "Offset can not be less than 0", http.StatusBadRequest)
+			return
+		}
+	}
+	if q.Get("limit") != "" {
+		payload.Limit, _ = strconv.Atoi(q.Get("limit"))
+		if payload.Limit < 0 {
+			http.Error(w, "Limit can not be less than 0", http.StatusBadRequest)
+			return
+		}
+	}
+	if q.Get("fragment_size") != "" {
+		payload.Highlight.FragmentSize, _ = strconv.Atoi(q.Get("fragment_size"))
+		if payload.Highlight.FragmentSize < 0 {
+			http.Error(w, "Fragment size can not be less than 0", http.StatusBadRequest)
+			return
+		}
+	}
+
+	contentType := r.Header.Get("Content-Type")
+
+	data, e := u.newsService.Search(r.Context(), payload)
+	if e != nil {
+		http.Error(w, e.Error(), searchStatusCode(e))
+		return
+	}
+	respBody, e := GetSerializer(contentType).EncodeSearchResult(data)
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+	SetupResponse(w, contentType, respBody, http.StatusOK)
+}
+
+// searchStatusCode only blames the client for an invalid request, any other failure comes from the backends behind search
+func searchStatusCode(e error) int {
+	switch errors.Cause(e) {
+	case helper.ErrDataInvalid:
+		return http.StatusBadRequest
+	case helper.ErrSearchUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+// Stats reads from and to as RFC 3339 dates, like 2020-05-24T00:00:00+07:00
+func (u *newshandler) Stats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	payload := m.StatsPayload{
+		Interval: q.Get("interval"),
+		TimeZone: q.Get("time_zone"),
+	}
+	if q.Get("authors") != "" {
+		payload.Authors, _ = strconv.Atoi(q.Get("authors"))
+		if payload.Authors < 0 {
+			http.Error(w, "Authors can not be less than 0", http.StatusBadRequest)
+			return
+		}
+	}
+	for k, v := range map[string]*time.Time{"from": &payload.From, "to": &payload.To} {
+		if q.Get(k) == "" {
+			continue
+		}
+		date, e := time.Parse(time.RFC3339, q.Get(k))
+		if e != nil {
+			http.Error(w, fmt.Sprintf("%s must be RFC 3339 date", k), http.StatusBadRequest)
+			return
+		}
+		*v = date
+	}
+
+	contentType := r.Header.Get("Content-Type")
+
+	data, e := u.newsService.Stats(r.Context(), payload)
+	if e != nil {
+		http.Error(w, e.Error(), searchStatusCode(e))
+		return
+	}
+	respBody, e := GetSerializer(contentType).EncodeStats(data)
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+	SetupResponse(w, contentType, respBody, http.StatusOK)
+}
+
 func (u *newshandler) Post(w http.ResponseWriter, r *http.Request) {
 	contentType := r.Header.Get("Content-Type")
 	requestBody, e := ioutil.ReadAll(r.Body)
@@ -103,7 +216,7 @@ func (u *newshandler) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if e := u.newsService.Store(data); e != nil {
+	if e := u.newsService.Store(r.Context(), data); e != nil {
 		http.Error(w, e.Error(), http.StatusBadRequest)
 		return
 	}
@@ -116,6 +229,52 @@ func (u *newshandler) Post(w http.ResponseWriter, r *http.Request) {
 	SetupResponse(w, contentType, respBody, http.StatusCreated)
 }
 
+func (u *newshandler) PostBulk(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	requestBody, e := ioutil.ReadAll(r.Body)
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+	data, e := GetSerializer(contentType).DecodeMany(requestBody)
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, helper.ErrDataInvalid.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, e := u.newsService.StoreMany(r.Context(), data)
+	if e != nil {
+		if errors.Cause(e) == helper.ErrDataInvalid {
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(results) != len(data) {
+			http.Error(w, e.Error(), http.StatusInternalServerError)
+			return
+		}
+		// the news stored before the failure are kept, the report tells the client which ones
+		log.Println(e)
+	}
+
+	statusCode := http.StatusCreated
+	for _, v := range results {
+		if !v.Success {
+			statusCode = http.StatusMultiStatus
+			break
+		}
+	}
+	respBody, e := GetSerializer(contentType).EncodeBulkResult(results)
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+	SetupResponse(w, contentType, respBody, statusCode)
+}
+
 func (u *newshandler) Update(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	existingData, ok := ctx.Value("news").(*m.News)
@@ -135,7 +294,7 @@ func (u *newshandler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, e.Error(), http.StatusBadRequest)
 		return
 	}
-	updatedData, e := u.newsService.Update(data, id)
+	updatedData, e := u.newsService.Update(r.Context(), data, id)
 	if e != nil {
 		http.Error(w, e.Error(), http.StatusBadRequest)
 		return
@@ -158,7 +317,7 @@ func (u *newshandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 	id := existingData.ID
 	contentType := r.Header.Get("Content-Type")
-	if e := u.newsService.Delete(*existingData); e != nil {
+	if e := u.newsService.Delete(r.Context(), *existingData); e != nil {
 		http.Error(w, e.Error(), http.StatusBadRequest)
 		return
 	}
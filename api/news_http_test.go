@@ -4,6 +4,7 @@ package api_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	. "github.com/rinosukmandityo/maknews/api"
+	"github.com/rinosukmandityo/maknews/config"
 	"github.com/rinosukmandityo/maknews/helper"
 	m "github.com/rinosukmandityo/maknews/models"
 	repo "github.com/rinosukmandityo/maknews/repositories"
@@ -32,25 +34,25 @@ import (
 	===================================
 	TO SET DATABASE INFO FROM TERMINAL
 	===================================
-	set url=root:Password.1@tcp(127.0.0.1:3306)/news
-	set timeout=10
-	set db=news
-	set driver=mysql
-	set redis_url=redis://:@localhost:6379/0
-	set redis_timeout=10
-	set elastic_url=http://localhost:9200
-	set elastic_timeout=10
-	set elastic_index=news
-	set kafka_url=localhost:9092
-	set kafka_timeout=10
-	set kafka_topic=news
+	set MAKNEWS_DATABASE_URL=root:Password.1@tcp(127.0.0.1:3306)/news
+	set MAKNEWS_DATABASE_TIMEOUT=10s
+	set MAKNEWS_DATABASE_NAME=news
+	set MAKNEWS_DATABASE_DRIVER=mysql
+	set MAKNEWS_REDIS_URL=redis://:@localhost:6379/0
+	set MAKNEWS_REDIS_EXPIRED=10s
+	set MAKNEWS_ELASTIC_URL=http://localhost:9200
+	set MAKNEWS_ELASTIC_TIMEOUT=10s
+	set MAKNEWS_ELASTIC_INDEX=news
+	set MAKNEWS_KAFKA_URL=localhost:9092
+	set MAKNEWS_KAFKA_TIMEOUT=10s
+	set MAKNEWS_KAFKA_TOPIC=news
 */
 
 var (
+	ctx         = context.Background()
 	newsRepo    repo.NewsRepository
 	elasticRepo repo.ElasticRepository
 	cacheRepo   repo.CacheRepository
-	kafkaRepo   repo.KafkaRepository
 	r           *chi.Mux
 	ts          *httptest.Server
 )
@@ -85,11 +87,20 @@ func ListTestData() []m.News {
 }
 
 func init() {
-	newsRepo = rh.ChooseRepo()
-	elasticRepo = rh.ElasticRepo()
-	cacheRepo = rh.RedisRepo()
-	kafkaRepo = rh.KafkaConnection()
-	r = RegisterHandler()
+	cfg, e := config.Load("")
+	if e != nil {
+		panic(e)
+	}
+	if newsRepo, e = rh.ChooseRepo(cfg); e != nil {
+		panic(e)
+	}
+	if elasticRepo, e = rh.ElasticRepo(cfg); e != nil {
+		panic(e)
+	}
+	if cacheRepo, e = rh.RedisRepo(cfg); e != nil {
+		panic(e)
+	}
+	r = RegisterHandler(logic.NewNewsService(newsRepo, cacheRepo, elasticRepo))
 }
 
 func TestNewsHTTP(t *testing.T) {
@@ -156,7 +167,7 @@ func getBytes(_data m.News) ([]byte, error) {
 }
 
 func InsertData(t *testing.T) {
-	newsService := logic.NewNewsService(newsRepo, cacheRepo, elasticRepo, kafkaRepo)
+	newsService := logic.NewNewsService(newsRepo, cacheRepo, elasticRepo)
 
 	tts := []TestTable{
 		{
@@ -175,9 +186,9 @@ func InsertData(t *testing.T) {
 			ID:      _data.ID,
 			Created: _data.Created,
 		}
-		newsService.Delete(_data)
-		elasticRepo.Delete(elData.ID)
-		cacheRepo.Delete(_data)
+		newsService.Delete(ctx, _data)
+		elasticRepo.Delete(ctx, elData.ID)
+		cacheRepo.Delete(ctx, _data)
 	}
 	time.Sleep(time.Second * 1)
 
@@ -188,7 +199,7 @@ func InsertData(t *testing.T) {
 					t.Errorf("%s %s ", tt.errMsg, e.Error())
 				}
 
-				res, e := newsService.GetById(_data.ID)
+				res, e := newsService.GetById(ctx, _data.ID)
 				if e != nil || res.ID == 0 {
 					t.Errorf("[ERROR] - Failed to get data")
 				}
@@ -198,7 +209,7 @@ func InsertData(t *testing.T) {
 					Limit:  10,
 				}
 
-				if elRes, e := elasticRepo.GetBy(payload); e != nil || len(elRes) == 0 {
+				if elRes, e := elasticRepo.GetBy(ctx, payload); e != nil || len(elRes) == 0 {
 					t.Errorf("[ERROR] - Failed to get data from elastic search")
 				}
 			})
@@ -48,3 +48,35 @@ func (u *News) EncodeGetData(input []m.News) ([]byte, error) {
 	}
 	return rawMsg, nil
 }
+
+func (u *News) DecodeMany(input []byte) ([]m.News, error) {
+	res := []m.News{}
+	if e := msgpack.Unmarshal(input, &res); e != nil {
+		return res, errors.Wrap(e, "serializer.Logic.DecodeMany")
+	}
+	return res, nil
+}
+
+func (u *News) EncodeBulkResult(input []m.BulkResult) ([]byte, error) {
+	rawMsg, e := msgpack.Marshal(input)
+	if e != nil {
+		return nil, errors.Wrap(e, "serializer.Logic.EncodeBulkResult")
+	}
+	return rawMsg, nil
+}
+
+func (u *News) EncodeSearchResult(input *m.SearchResult) ([]byte, error) {
+	rawMsg, e := msgpack.Marshal(input)
+	if e != nil {
+		return nil, errors.Wrap(e, "serializer.Logic.EncodeSearchResult")
+	}
+	return rawMsg, nil
+}
+
+func (u *News) EncodeStats(input *m.NewsStats) ([]byte, error) {
+	rawMsg, e := msgpack.Marshal(input)
+	if e != nil {
+		return nil, errors.Wrap(e, "serializer.Logic.EncodeStats")
+	}
+	return rawMsg, nil
+}
@@ -10,4 +10,8 @@ type UserSerializer interface {
 	DecodeMap(input []byte) (map[string]interface{}, error)
 	EncodeMap(input map[string]interface{}) ([]byte, error)
 	EncodeGetData(input []m.News) ([]byte, error)
+	DecodeMany(input []byte) ([]m.News, error)
+	EncodeBulkResult(input []m.BulkResult) ([]byte, error)
+	EncodeSearchResult(input *m.SearchResult) ([]byte, error)
+	EncodeStats(input *m.NewsStats) ([]byte, error)
 }
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/rinosukmandityo/maknews/config"
+	rh "github.com/rinosukmandityo/maknews/repositories/helper"
+	svc "github.com/rinosukmandityo/maknews/services"
+	"github.com/rinosukmandityo/maknews/services/logic"
+
+	"github.com/pkg/errors"
+)
+
+// App wires the repositories chosen by config.Config into the services, every entry point in cmd starts from it.
+// It owns the repositories it opens and the background workers it starts, Close releases all of them.
+type App struct {
+	config  *config.Config
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+	repos   []io.Closer
+}
+
+func New(c *config.Config) *App {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &App{
+		config: c,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// own closes repos when the app is closed, a repository that is not configured is nil and skipped
+func (a *App) own(repos ...io.Closer) {
+	for _, repo := range repos {
+		if repo != nil {
+			a.repos = append(a.repos, repo)
+		}
+	}
+}
+
+// Go runs fn in background, ctx of fn is cancelled when the app is closed
+func (a *App) Go(fn func(ctx context.Context)) {
+	a.workers.Add(1)
+	go func() {
+		defer a.workers.Done()
+		fn(a.ctx)
+	}()
+}
+
+// NewsService serves the news API
+func (a *App) NewsService() (svc.NewsService, error) {
+	newsRepo, e := rh.ChooseRepo(a.config)
+	if e != nil {
+		return nil, errors.Wrap(e, "app.NewsService")
+	}
+	a.own(newsRepo)
+	elasticRepo, e := rh.ElasticRepo(a.config)
+	if e != nil {
+		return nil, errors.Wrap(e, "app.NewsService")
+	}
+	a.own(elasticRepo)
+	redisRepo, e := rh.RedisRepo(a.config)
+	if e != nil {
+		return nil, errors.Wrap(e, "app.NewsService")
+	}
+	a.own(redisRepo)
+	return logic.NewNewsService(newsRepo, redisRepo, elasticRepo), nil
+}
+
+// MaintenanceService backs the reindex, warm-cache and seed commands, with dryRun the elasticsearch index is never created
+func (a *App) MaintenanceService(dryRun bool) (svc.MaintenanceService, error) {
+	newsRepo, e := rh.ChooseRepo(a.config)
+	if e != nil {
+		return nil, errors.Wrap(e, "app.MaintenanceService")
+	}
+	a.own(newsRepo)
+	chooseElastic := rh.ElasticRepo
+	if dryRun {
+		chooseElastic = rh.ReadOnlyElasticRepo
+	}
+	elasticRepo, e := chooseElastic(a.config)
+	if e != nil {
+		return nil, errors.Wrap(e, "app.MaintenanceService")
+	}
+	a.own(elasticRepo)
+	redisRepo, e := rh.RedisRepo(a.config)
+	if e != nil {
+		return nil, errors.Wrap(e, "app.MaintenanceService")
+	}
+	a.own(redisRepo)
+	outboxRepo, e := rh.OutboxRepo(a.config)
+	if e != nil {
+		return nil, errors.Wrap(e, "app.MaintenanceService")
+	}
+	a.own(outboxRepo)
+	return logic.NewMaintenanceService(newsRepo, outboxRepo, redisRepo, elasticRepo), nil
+}
+
+// StartOutboxRelay publishes the outbox to kafka in background
+func (a *App) StartOutboxRelay() error {
+	outboxRepo, e := rh.OutboxRepo(a.config)
+	if e != nil {
+		return errors.Wrap(e, "app.StartOutboxRelay")
+	}
+	a.own(outboxRepo)
+	kafkaRepo, e := rh.KafkaConnection(a.config)
+	if e != nil {
+		return errors.Wrap(e, "app.StartOutboxRelay")
+	}
+	a.own(kafkaRepo)
+
+	outboxSvc := logic.NewOutboxService(outboxRepo, kafkaRepo, a.config.Outbox.BatchSize, a.config.Outbox.Lease)
+	a.Go(func(ctx context.Context) {
+		outboxSvc.Run(ctx, a.config.Outbox.Interval)
+	})
+	return nil
+}
+
+// StartConsumer applies the news events from kafka to elasticsearch in background
+func (a *App) StartConsumer() error {
+	newsRepo, e := rh.ChooseRepo(a.config)
+	if e != nil {
+		return errors.Wrap(e, "app.StartConsumer")
+	}
+	a.own(newsRepo)
+	elasticRepo, e := rh.ElasticRepo(a.config)
+	if e != nil {
+		return errors.Wrap(e, "app.StartConsumer")
+	}
+	a.own(elasticRepo)
+	kafkaRepo, e := rh.KafkaConnection(a.config)
+	if e != nil {
+		return errors.Wrap(e, "app.StartConsumer")
+	}
+	a.own(kafkaRepo)
+	deadLetterRepo, e := rh.DeadLetterConnection(a.config)
+	if e != nil {
+		return errors.Wrap(e, "app.StartConsumer")
+	}
+	a.own(deadLetterRepo)
+	processedRepo, e := rh.ProcessedEventRepo(a.config)
+	if e != nil {
+		return errors.Wrap(e, "app.StartConsumer")
+	}
+	a.own(processedRepo)
+
+	kafkaSvc := logic.NewKafkaService(kafkaRepo, deadLetterRepo, processedRepo, retryPolicy(a.config.Consumer), a.config.Consumer.Workers)
+	a.Go(func(ctx context.Context) {
+		kafkaSvc.ReadMessage(ctx, newsRepo, elasticRepo)
+	})
+	return nil
+}
+
+func retryPolicy(c config.Consumer) logic.RetryPolicy {
+	return logic.RetryPolicy{
+		MaxRetries: c.MaxRetries,
+		Backoff:    c.RetryBackoff,
+		MaxBackoff: c.RetryMaxBackoff,
+	}
+}
+
+// Close stops the background workers, waiting until the message being handled is committed,
+// then it closes every repository. When ctx is done first the repositories are closed anyway.
+func (a *App) Close(ctx context.Context) error {
+	a.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(stopped)
+	}()
+
+	var e error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		e = errors.Wrap(ctx.Err(), "app.Close background workers did not stop")
+	}
+	for _, repo := range a.repos {
+		if err := repo.Close(); err != nil && e == nil {
+			e = errors.Wrap(err, "app.Close")
+		}
+	}
+	return e
+}
+
+// WaitForSignal blocks until SIGINT or SIGTERM arrives or errs receives an error, the received error is returned
+func WaitForSignal(errs <-chan error) error {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(c)
+	select {
+	case e := <-errs:
+		log.Printf("Terminated %s", e)
+		return e
+	case sig := <-c:
+		log.Printf("Received %s, shutting down", sig)
+		return nil
+	}
+}
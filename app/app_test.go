@@ -0,0 +1,38 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/rinosukmandityo/maknews/app"
+	"github.com/rinosukmandityo/maknews/config"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v -run TestAppClose
+*/
+
+func TestAppClose(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Driver = "memory"
+
+	a := New(cfg)
+	if _, e := a.NewsService(); e != nil {
+		t.Fatalf("[ERROR] - Failed to create news service %s", e.Error())
+	}
+	if e := a.StartOutboxRelay(); e != nil {
+		t.Fatalf("[ERROR] - Failed to start outbox relay %s", e.Error())
+	}
+	if e := a.StartConsumer(); e != nil {
+		t.Fatalf("[ERROR] - Failed to start consumer %s", e.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	if e := a.Close(ctx); e != nil {
+		t.Errorf("[ERROR] - Background workers should stop before the deadline %s", e.Error())
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rinosukmandityo/maknews/config"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go build -o maknews ./cmd/maknews
+	maknews serve
+	maknews consume
+	maknews migrate up
+	maknews migrate -dry-run up
+	maknews reindex -dry-run
+	maknews warm-cache -limit 100
+	maknews seed -count 50
+	maknews redrive -idle 10s
+	maknews help reindex
+
+	Every command takes the config file, environment variables and flags described in config.example.yaml
+*/
+
+// exit codes of every command
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2 // bad flags, arguments or config
+)
+
+// runner executes a command once its flags and config are parsed, args are the positional arguments
+type runner func(cfg *config.Config, args []string) int
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	// setup registers the flags of the command on fs
+	setup func(fs *flag.FlagSet) runner
+}
+
+var commands = []command{
+	{"serve", "", "serve the HTTP API and relay the outbox to kafka", serveCommand},
+	{"consume", "", "apply the news events from kafka to elasticsearch", consumeCommand},
+	{"migrate", "up|down|status", "migrate the schema of SQL persistence database", migrateCommand},
+	{"reindex", "", "build a new elasticsearch index from persistence database and swap the alias to it", reindexCommand},
+	{"warm-cache", "", "cache the latest news in redis", warmCacheCommand},
+	{"seed", "", "store fixture news", seedCommand},
+	{"redrive", "", "move dead-lettered messages back to the main topic", redriveCommand},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) == 1 {
+			usage(os.Stdout)
+			return exitOK
+		}
+		if _, ok := findCommand(args[1]); !ok {
+			return unknownCommand(args[1])
+		}
+		return run([]string{args[1], "-help"})
+	}
+
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return unknownCommand(args[0])
+	}
+	fs := newFlagSet(cmd)
+	exec := cmd.setup(fs)
+	cfg, e := config.Parse(fs, args[1:])
+	if e == flag.ErrHelp {
+		return exitOK
+	}
+	if e != nil {
+		// the flag package already printed its own errors
+		if _, ok := e.(config.Errors); ok {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		return exitUsage
+	}
+	return exec(cfg, fs.Args())
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func unknownCommand(name string) int {
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage(os.Stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: maknews <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "maknews help <command>" or "maknews <command> --help" to list its flags.`)
+	fmt.Fprintln(w, "Exit code is 0 on success, 1 on failure and 2 on bad flags, arguments or config.")
+}
+
+// newFlagSet lists the flags of cmd together with the config flags added by config.Parse
+func newFlagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: maknews %s\n\n%s\n\nflags:\n", strings.TrimSpace(cmd.name+" [flags] "+cmd.args), cmd.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// usageError reports bad positional arguments or flag values of a command
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) int {
+	fmt.Fprintf(fs.Output(), format+"\n", args...)
+	fmt.Fprintf(fs.Output(), "Run \"maknews help %s\" for usage.\n", fs.Name())
+	return exitUsage
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v
+*/
+
+func TestExitCode(t *testing.T) {
+	driver, ok := os.LookupEnv("MAKNEWS_DATABASE_DRIVER")
+	os.Setenv("MAKNEWS_DATABASE_DRIVER", "memory")
+	defer func() {
+		if ok {
+			os.Setenv("MAKNEWS_DATABASE_DRIVER", driver)
+		} else {
+			os.Unsetenv("MAKNEWS_DATABASE_DRIVER")
+		}
+	}()
+
+	tts := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"No Command", []string{}, exitUsage},
+		{"Unknown Command", []string{"publish"}, exitUsage},
+		{"Help", []string{"help"}, exitOK},
+		{"Command Help", []string{"seed", "--help"}, exitOK},
+		{"Help Of Command", []string{"help", "reindex"}, exitOK},
+		{"Unknown Flag", []string{"reindex", "-force"}, exitUsage},
+		{"Invalid Config", []string{"seed", "-http.port", "0"}, exitUsage},
+		{"Unexpected Argument", []string{"warm-cache", "now"}, exitUsage},
+		{"Missing Migrate Command", []string{"migrate"}, exitUsage},
+		{"Migrate Without SQL", []string{"migrate", "up"}, exitFailure},
+		{"Seed Dry Run", []string{"seed", "-count", "3", "-dry-run"}, exitOK},
+		{"Seed", []string{"seed", "-count", "3"}, exitOK},
+		{"Seed Existing News", []string{"seed", "-count", "3"}, exitFailure},
+		{"Reindex", []string{"reindex", "-batch-size", "2"}, exitOK},
+		{"Warm Cache", []string{"warm-cache", "-limit", "2", "-dry-run"}, exitOK},
+	}
+
+	for _, tc := range tts {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := run(tc.args); code != tc.expected {
+				t.Errorf("[ERROR] - Expected exit code %d but got %d", tc.expected, code)
+			}
+		})
+	}
+}
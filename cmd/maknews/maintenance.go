@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/app"
+	"github.com/rinosukmandityo/maknews/config"
+	m "github.com/rinosukmandityo/maknews/models"
+	svc "github.com/rinosukmandityo/maknews/services"
+)
+
+// withMaintenance runs fn with the maintenance service and closes its repositories afterwards,
+// a dry run never creates the elasticsearch index
+func withMaintenance(cfg *config.Config, dryRun bool, fn func(ctx context.Context, maintenanceSvc svc.MaintenanceService) int) int {
+	a := app.New(cfg)
+	defer a.Close(context.Background())
+	maintenanceSvc, e := a.MaintenanceService(dryRun)
+	if e != nil {
+		log.Println(e)
+		return exitFailure
+	}
+	return fn(context.Background(), maintenanceSvc)
+}
+
+// reindexCommand builds a new elasticsearch index and swaps the alias to it, the API and the consumer keep running meanwhile.
+// Run it after changing the index mapping, restoring persistence database or losing the index.
+func reindexCommand(fs *flag.FlagSet) runner {
+	batchSize := fs.Int("batch-size", 500, "news read and indexed at a time")
+	deleteOld := fs.Bool("delete-old", false, "delete the indices the alias pointed to before, they are kept for rollback otherwise")
+	dryRun := fs.Bool("dry-run", false, "count the news without creating an index")
+	return func(cfg *config.Config, args []string) int {
+		if len(args) > 0 {
+			return usageError(fs, "reindex takes no argument")
+		}
+		if *batchSize <= 0 {
+			return usageError(fs, "-batch-size must be positive")
+		}
+		return withMaintenance(cfg, *dryRun, func(ctx context.Context, maintenanceSvc svc.MaintenanceService) int {
+			res, e := maintenanceSvc.Reindex(ctx, *batchSize, *deleteOld, *dryRun)
+			if res != nil {
+				printReindex(cfg.Elastic.Index, res, *dryRun)
+			}
+			if e != nil {
+				log.Println(e)
+				return exitFailure
+			}
+			return exitOK
+		})
+	}
+}
+
+func printReindex(alias string, res *m.ReindexResult, dryRun bool) {
+	switch {
+	case dryRun:
+		fmt.Printf("Would index %d news into a new index behind alias %s\n", res.Indexed, alias)
+		return
+	case res.Index == "":
+		return
+	}
+	fmt.Printf("Indexed %d news into %s\n", res.Indexed, res.Index)
+	if res.Previous == nil {
+		return
+	}
+	fmt.Printf("Alias %s points to %s\n", alias, res.Index)
+	if res.Changed > 0 {
+		fmt.Printf("Caught up %d news changed during the build\n", res.Changed)
+	}
+	if len(res.Previous) == 0 {
+		return
+	}
+	if res.Deleted {
+		fmt.Printf("Deleted %s\n", strings.Join(res.Previous, ", "))
+	} else {
+		fmt.Printf("Kept %s for rollback\n", strings.Join(res.Previous, ", "))
+	}
+}
+
+func warmCacheCommand(fs *flag.FlagSet) runner {
+	limit := fs.Int("limit", 100, "number of latest news to cache")
+	dryRun := fs.Bool("dry-run", false, "count the news without caching them")
+	return func(cfg *config.Config, args []string) int {
+		if len(args) > 0 {
+			return usageError(fs, "warm-cache takes no argument")
+		}
+		if *limit <= 0 {
+			return usageError(fs, "-limit must be positive")
+		}
+		return withMaintenance(cfg, *dryRun, func(ctx context.Context, maintenanceSvc svc.MaintenanceService) int {
+			n, e := maintenanceSvc.WarmCache(ctx, *limit, *dryRun)
+			if e != nil {
+				log.Println(e)
+				return exitFailure
+			}
+			if *dryRun {
+				fmt.Printf("Would cache %d news\n", n)
+			} else {
+				fmt.Printf("Cached %d news\n", n)
+			}
+			return exitOK
+		})
+	}
+}
+
+// seedCommand stores news through the outbox like the API, so the consumer indexes them
+func seedCommand(fs *flag.FlagSet) runner {
+	file := fs.String("file", "", "JSON array of news to store, generated news are stored when empty")
+	count := fs.Int("count", 10, "number of generated news")
+	startID := fs.Int("start-id", 1, "ID of the first generated news")
+	dryRun := fs.Bool("dry-run", false, "report which news would be stored without storing them")
+	return func(cfg *config.Config, args []string) int {
+		if len(args) > 0 {
+			return usageError(fs, "seed takes no argument")
+		}
+		if *file == "" && *count <= 0 {
+			return usageError(fs, "-count must be positive")
+		}
+		data := fixtures(*count, *startID)
+		if *file != "" {
+			var e error
+			if data, e = readFixtures(*file); e != nil {
+				log.Println(e)
+				return exitFailure
+			}
+		}
+
+		return withMaintenance(cfg, *dryRun, func(ctx context.Context, maintenanceSvc svc.MaintenanceService) int {
+			results, e := maintenanceSvc.Seed(ctx, data, *dryRun)
+			if e != nil {
+				log.Println(e)
+				if *dryRun {
+					return exitFailure
+				}
+				// the news stored before the failure are listed below
+			}
+			stored := 0
+			for _, v := range results {
+				if !v.Success {
+					fmt.Printf("News %d failed: %s\n", v.ID, v.Error)
+					continue
+				}
+				stored++
+			}
+			if *dryRun {
+				fmt.Printf("Would store %d of %d news\n", stored, len(results))
+			} else {
+				fmt.Printf("Stored %d of %d news\n", stored, len(results))
+			}
+			if e != nil || stored < len(results) {
+				return exitFailure
+			}
+			return exitOK
+		})
+	}
+}
+
+func readFixtures(path string) ([]m.News, error) {
+	content, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, e
+	}
+	data := []m.News{}
+	if e := json.Unmarshal(content, &data); e != nil {
+		return nil, fmt.Errorf("%s: %s", path, e)
+	}
+	return data, nil
+}
+
+// fixtures generates count news from startID, the newest one has the highest ID
+func fixtures(count, startID int) []m.News {
+	authors := []string{"Alex", "Bacca", "Chicarito"}
+	now := time.Now().UTC()
+	data := make([]m.News, count)
+	for i := range data {
+		author := authors[i%len(authors)]
+		data[i] = m.News{
+			ID:      startID + i,
+			Author:  author,
+			Body:    fmt.Sprintf("Hello this is news %d from %s", startID+i, author),
+			Created: now.Add(time.Duration(i-count) * time.Second),
+		}
+	}
+	return data
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/rinosukmandityo/maknews/config"
+	rh "github.com/rinosukmandityo/maknews/repositories/helper"
+	"github.com/rinosukmandityo/maknews/repositories/migrations"
+
+	"github.com/pkg/errors"
+)
+
+func migrateCommand(fs *flag.FlagSet) runner {
+	dryRun := fs.Bool("dry-run", false, "print the migrations up or down would run without running them")
+	return func(cfg *config.Config, args []string) int {
+		if len(args) != 1 {
+			return usageError(fs, "migrate takes one of up, down or status")
+		}
+		switch args[0] {
+		case "up", "down", "status":
+		default:
+			return usageError(fs, "unknown migrate command %q", args[0])
+		}
+
+		// a dry run only reads the status, it must not create the database or schema_migrations
+		choose := rh.ChooseMigrator
+		if *dryRun && args[0] != "status" {
+			choose = rh.ChooseReadOnlyMigrator
+		}
+		migrator, e := choose(cfg)
+		if e != nil {
+			log.Println(e)
+			return exitFailure
+		}
+		defer migrator.Close()
+
+		if *dryRun && args[0] != "status" {
+			e = plan(migrator, args[0])
+		} else {
+			e = migrate(migrator, args[0])
+		}
+		if e != nil {
+			log.Println(e)
+			return exitFailure
+		}
+		return exitOK
+	}
+}
+
+func migrate(migrator *migrations.Migrator, command string) error {
+	switch command {
+	case "up":
+		applied, e := migrator.Up()
+		for _, v := range applied {
+			fmt.Printf("Applied %d %s\n", v.Version, v.Name)
+		}
+		if e != nil {
+			return e
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "down":
+		reverted, e := migrator.Down()
+		if errors.Cause(e) == migrations.ErrNoMigration {
+			fmt.Println(migrations.ErrNoMigration.Error())
+			return nil
+		}
+		if e != nil {
+			return e
+		}
+		fmt.Printf("Reverted %d %s\n", reverted.Version, reverted.Name)
+	case "status":
+		status, e := migrator.Status()
+		if e != nil {
+			return e
+		}
+		for _, v := range status {
+			appliedAt := "pending"
+			if v.Applied {
+				appliedAt = v.AppliedAt.Format("2006-01-02T15:04:05Z07:00")
+			}
+			fmt.Printf("%d %s %s\n", v.Version, v.Name, appliedAt)
+		}
+	}
+	return nil
+}
+
+// plan prints what migrate would do from the migration status, up applies every pending version and down reverts the latest applied one
+func plan(migrator *migrations.Migrator, command string) error {
+	status, e := migrator.ReadStatus()
+	if e != nil {
+		return e
+	}
+	switch command {
+	case "up":
+		pending := 0
+		for _, v := range status {
+			if !v.Applied {
+				pending++
+				fmt.Printf("Would apply %d %s\n", v.Version, v.Name)
+			}
+		}
+		if pending == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "down":
+		for i := len(status) - 1; i >= 0; i-- {
+			if status[i].Applied {
+				fmt.Printf("Would revert %d %s\n", status[i].Version, status[i].Name)
+				return nil
+			}
+		}
+		fmt.Println(migrations.ErrNoMigration.Error())
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/config"
+	rh "github.com/rinosukmandityo/maknews/repositories/helper"
+	"github.com/rinosukmandityo/maknews/services/logic"
+)
+
+// redriveCommand keeps the original key and payload of every message in kafka.dlq_topic,
+// fix the cause of the failure first, otherwise the messages are dead-lettered again
+func redriveCommand(fs *flag.FlagSet) runner {
+	idle := fs.Duration("idle", 5*time.Second, "stop when no dead letter arrives within this duration")
+	return func(cfg *config.Config, args []string) int {
+		if len(args) > 0 {
+			return usageError(fs, "redrive takes no argument")
+		}
+		kafkaRepo, e := rh.KafkaConnection(cfg)
+		if e != nil {
+			log.Println(e)
+			return exitFailure
+		}
+		deadLetterRepo, e := rh.DeadLetterConnection(cfg)
+		if e != nil {
+			kafkaRepo.Close()
+			log.Println(e)
+			return exitFailure
+		}
+		kafkaSvc := logic.NewKafkaService(kafkaRepo, deadLetterRepo, nil, logic.DefaultRetryPolicy, 0)
+		n, e := kafkaSvc.Redrive(context.Background(), *idle)
+		fmt.Printf("Redriven %d message(s)\n", n)
+		// closing the main topic flushes the redriven messages
+		deadLetterRepo.Close()
+		if err := kafkaRepo.Close(); err != nil && e == nil {
+			e = err
+		}
+		if e != nil {
+			log.Println(e)
+			return exitFailure
+		}
+		return exitOK
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	h "github.com/rinosukmandityo/maknews/api"
+	"github.com/rinosukmandityo/maknews/app"
+	"github.com/rinosukmandityo/maknews/config"
+)
+
+func serveCommand(fs *flag.FlagSet) runner {
+	return func(cfg *config.Config, args []string) int {
+		if len(args) > 0 {
+			return usageError(fs, "serve takes no argument")
+		}
+		a := app.New(cfg)
+		newsSvc, e := a.NewsService()
+		if e == nil {
+			e = a.StartOutboxRelay()
+		}
+		if e != nil {
+			log.Println(e)
+			a.Close(context.Background())
+			return exitFailure
+		}
+		addr := fmt.Sprintf(":%d", cfg.HTTP.Port)
+		srv := &http.Server{
+			Addr:    addr,
+			Handler: h.RegisterHandler(newsSvc),
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			log.Printf("Listening on port %s\n", addr)
+			errs <- srv.ListenAndServe()
+		}()
+		code := exitOK
+		if e := app.WaitForSignal(errs); e != nil {
+			code = exitFailure
+		}
+
+		// in-flight requests and the outbox relay share one deadline
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if e := srv.Shutdown(ctx); e != nil {
+			log.Printf("Failed to drain requests %s", e)
+			code = exitFailure
+		}
+		return stop(ctx, a, code)
+	}
+}
+
+// consumeCommand can run as many times as kafka.topic has partitions, they share the work through the consumer group kafka.group_id
+func consumeCommand(fs *flag.FlagSet) runner {
+	return func(cfg *config.Config, args []string) int {
+		if len(args) > 0 {
+			return usageError(fs, "consume takes no argument")
+		}
+		a := app.New(cfg)
+		if e := a.StartConsumer(); e != nil {
+			log.Println(e)
+			a.Close(context.Background())
+			return exitFailure
+		}
+		log.Println("Consuming news events")
+		app.WaitForSignal(nil)
+
+		// the message being handled is committed before the deadline
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		return stop(ctx, a, exitOK)
+	}
+}
+
+// stop closes a and returns code, or exitFailure when a does not stop cleanly
+func stop(ctx context.Context, a *app.App, code int) int {
+	if e := a.Close(ctx); e != nil {
+		log.Printf("Failed to stop %s", e)
+		code = exitFailure
+	}
+	log.Println("Stopped")
+	return code
+}
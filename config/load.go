@@ -0,0 +1,188 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix starts the name of every environment variable, database.url is read from MAKNEWS_DATABASE_URL
+const EnvPrefix = "MAKNEWS_"
+
+// FileEnv names the environment variable holding the YAML file path when -config flag is not given
+const FileEnv = EnvPrefix + "CONFIG"
+
+// field is one setting, value points to its Config field and is *string, *int, *bool or *time.Duration
+type field struct {
+	name  string
+	usage string
+	value interface{}
+}
+
+func (f field) env() string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(f.name, ".", "_", -1))
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{"http.port", "HTTP port of the API", &c.HTTP.Port},
+		{"shutdown_timeout", "deadline to finish in-flight work on SIGINT or SIGTERM", &c.ShutdownTimeout},
+		{"database.driver", "persistence database: " + strings.Join(Drivers, ", "), &c.Database.Driver},
+		{"database.url", "persistence database URL, default depends on driver", &c.Database.URL},
+		{"database.name", "persistence database name", &c.Database.Name},
+		{"database.timeout", "upper limit of one persistence database call, default depends on driver", &c.Database.Timeout},
+		{"database.max_open_conns", "MySQL open connections", &c.Database.MaxOpenConns},
+		{"database.max_idle_conns", "MySQL idle connections", &c.Database.MaxIdleConns},
+		{"database.conn_max_lifetime", "MySQL connection lifetime", &c.Database.ConnMaxLifetime},
+		{"redis.url", "redis URL", &c.Redis.URL},
+		{"redis.expired", "how long a news is cached", &c.Redis.Expired},
+		{"elastic.enabled", "list news from elasticsearch", &c.Elastic.Enabled},
+		{"elastic.url", "elasticsearch URL", &c.Elastic.URL},
+		{"elastic.index", "elasticsearch alias, news are stored in its versioned indices <index>_v1, <index>_v2 and so on", &c.Elastic.Index},
+		{"elastic.timeout", "upper limit of one elasticsearch call", &c.Elastic.Timeout},
+		{"kafka.url", "kafka broker address", &c.Kafka.URL},
+		{"kafka.topic", "topic of news events", &c.Kafka.Topic},
+		{"kafka.dlq_topic", "topic of events the consumer failed to apply", &c.Kafka.DLQTopic},
+		{"kafka.group_id", "consumer group", &c.Kafka.GroupID},
+		{"kafka.timeout", "upper limit of one kafka call", &c.Kafka.Timeout},
+		{"consumer.workers", "events applied at the same time", &c.Consumer.Workers},
+		{"consumer.max_retries", "retries before an event is dead-lettered", &c.Consumer.MaxRetries},
+		{"consumer.retry_backoff", "wait before the first retry, doubled on every retry", &c.Consumer.RetryBackoff},
+		{"consumer.retry_max_backoff", "upper limit of the wait between retries", &c.Consumer.RetryMaxBackoff},
+		{"consumer.processed_event_ttl", "how long an applied event ID is remembered", &c.Consumer.ProcessedEventTTL},
+		{"outbox.interval", "wait between outbox relays", &c.Outbox.Interval},
+		{"outbox.batch_size", "outbox messages relayed at once", &c.Outbox.BatchSize},
+		{"outbox.lease", "how long a relayed batch is hidden from the relays of other replicas", &c.Outbox.Lease},
+	}
+}
+
+// Load reads defaults, the YAML file at path and environment variables, then validates the result.
+// An empty path falls back to MAKNEWS_CONFIG, without both no file is read.
+func Load(path string) (*Config, error) {
+	return load(path, nil)
+}
+
+// Parse registers a flag for every field and -config on fs, then loads like Load with the flags given in args on top.
+// Positional arguments are left in fs.Args().
+func Parse(fs *flag.FlagSet, args []string) (*Config, error) {
+	path := fs.String("config", "", "YAML config file, default is $"+FileEnv)
+	flags := Default()
+	for _, f := range flags.fields() {
+		usage := fmt.Sprintf("%s ($%s)", f.usage, f.env())
+		switch v := f.value.(type) {
+		case *string:
+			fs.StringVar(v, f.name, *v, usage)
+		case *int:
+			fs.IntVar(v, f.name, *v, usage)
+		case *bool:
+			fs.BoolVar(v, f.name, *v, usage)
+		case *time.Duration:
+			fs.DurationVar(v, f.name, *v, usage)
+		}
+	}
+	if e := fs.Parse(args); e != nil {
+		return nil, e
+	}
+
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	return load(*path, func(c *Config) {
+		src := flags.fields()
+		for i, f := range c.fields() {
+			if given[f.name] {
+				copyValue(f.value, src[i].value)
+			}
+		}
+	})
+}
+
+func load(path string, override func(c *Config)) (*Config, error) {
+	c := Default()
+	var errs Errors
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	if path != "" {
+		if e := c.readFile(path); e != nil {
+			errs = append(errs, e.Error())
+		}
+	}
+	c.readEnv(&errs)
+	if override != nil {
+		override(c)
+	}
+
+	c.fillDriverDefaults()
+	if e := c.Validate(); e != nil {
+		errs = append(errs, e.(Errors)...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return c, nil
+}
+
+// readFile rejects unknown keys so a misspelled setting is not silently ignored
+func (c *Config) readFile(path string) error {
+	data, e := ioutil.ReadFile(path)
+	if e != nil {
+		return e
+	}
+	if e := yaml.UnmarshalStrict(data, c); e != nil {
+		return fmt.Errorf("%s: %s", path, e)
+	}
+	return nil
+}
+
+func (c *Config) readEnv(errs *Errors) {
+	for _, f := range c.fields() {
+		value, ok := os.LookupEnv(f.env())
+		if !ok {
+			continue
+		}
+		var e error
+		switch v := f.value.(type) {
+		case *string:
+			*v = value
+		case *int:
+			var n int
+			if n, e = strconv.Atoi(value); e == nil {
+				*v = n
+			}
+		case *bool:
+			var b bool
+			if b, e = strconv.ParseBool(value); e == nil {
+				*v = b
+			}
+		case *time.Duration:
+			var d time.Duration
+			if d, e = time.ParseDuration(value); e == nil {
+				*v = d
+			}
+		}
+		if e != nil {
+			errs.add(f.env(), "cannot parse %q", value)
+		}
+	}
+}
+
+func copyValue(dst, src interface{}) {
+	switch v := dst.(type) {
+	case *string:
+		*v = *src.(*string)
+	case *int:
+		*v = *src.(*int)
+	case *bool:
+		*v = *src.(*bool)
+	case *time.Duration:
+		*v = *src.(*time.Duration)
+	}
+}
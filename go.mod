@@ -7,9 +7,14 @@ require (
 	github.com/go-redis/redis v6.15.7+incompatible
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/jmoiron/sqlx v1.2.0
+	github.com/lib/pq v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/olivere/elastic/v7 v7.0.12
 	github.com/pkg/errors v0.9.1
 	github.com/segmentio/kafka-go v0.3.5
 	github.com/vmihailenco/msgpack v4.0.4+incompatible
+	go.mongodb.org/mongo-driver v1.17.6
 	gopkg.in/dealancer/validate.v2 v2.1.0
+	gopkg.in/mgo.v2 v2.0.0-20190816093944-a6b53ec6cb22
+	gopkg.in/yaml.v2 v2.4.0
 )
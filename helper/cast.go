@@ -2,6 +2,8 @@ package helper
 
 import (
 	"reflect"
+	"strconv"
+	"time"
 )
 
 func Value(o interface{}) reflect.Value {
@@ -11,3 +13,49 @@ func Value(o interface{}) reflect.Value {
 func Kind(o interface{}) reflect.Kind {
 	return Value(o).Kind()
 }
+
+// ToInt converts numeric values decoded from JSON, msgpack or query strings into int
+func ToInt(o interface{}) (int, error) {
+	switch v := o.(type) {
+	case int:
+		return v, nil
+	case int8:
+		return int(v), nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case uint8:
+		return int(v), nil
+	case uint16:
+		return int(v), nil
+	case uint32:
+		return int(v), nil
+	case uint64:
+		return int(v), nil
+	case float32:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
+	}
+	return 0, ErrDataInvalid
+}
+
+// ToTime converts time values decoded from JSON or msgpack into time.Time
+func ToTime(o interface{}) (time.Time, error) {
+	switch v := o.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	}
+	return time.Time{}, ErrDataInvalid
+}
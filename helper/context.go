@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"context"
+	"time"
+)
+
+type detachedContext struct {
+	context.Context
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+type detachedKey struct{}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	if key == (detachedKey{}) {
+		return c.parent
+	}
+	return c.Context.Value(key)
+}
+
+// Detach keeps the values of ctx but is never cancelled, it lets work that has started
+// finish after ctx is cancelled, like the message being handled when the consumer stops
+func Detach(ctx context.Context) context.Context {
+	return detachedContext{ctx, ctx}
+}
+
+// Stopping returns the Done channel of the context given to Detach, or ctx.Done() when ctx is not detached.
+// Work running on a detached context checks it between steps, like before a retry, so a stop never waits for
+// every retry while the step that has started is still finished.
+func Stopping(ctx context.Context) <-chan struct{} {
+	if parent, ok := ctx.Value(detachedKey{}).(context.Context); ok {
+		return parent.Done()
+	}
+	return ctx.Done()
+}
@@ -2,9 +2,24 @@ package helper
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
 	ErrDataNotFound = errors.New("Data Not Found")
 	ErrDataInvalid  = errors.New("Data Invalid")
+	ErrDataExists   = errors.New("Data Already Exists")
+	// ErrSearchUnavailable is returned by search when elasticsearch is not enabled
+	ErrSearchUnavailable = errors.New("Search Unavailable")
+	// ErrIndexInUse is returned when deleting the elasticsearch index the alias points to
+	ErrIndexInUse = errors.New("Index In Use")
 )
+
+// PartialDataError is returned together with the found data when some of requested ID do not exist
+type PartialDataError struct {
+	Missing []int
+}
+
+func (e *PartialDataError) Error() string {
+	return fmt.Sprintf("%s for id %v", ErrDataNotFound.Error(), e.Missing)
+}
@@ -0,0 +1,8 @@
+package models
+
+// BulkResult reports whether one news in bulk request has been stored
+type BulkResult struct {
+	ID      int    `json:"id" bson:"id" msgpack:"id"`
+	Success bool   `json:"success" bson:"success" msgpack:"success"`
+	Error   string `json:"error,omitempty" bson:"error,omitempty" msgpack:"error,omitempty"`
+}
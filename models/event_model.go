@@ -0,0 +1,87 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type EventType string
+
+const (
+	NewsCreated EventType = "news.created"
+	NewsUpdated EventType = "news.updated"
+	NewsDeleted EventType = "news.deleted"
+)
+
+// EventSchemaVersion is increased on every incompatible change of Event or its payload
+const EventSchemaVersion = 1
+
+// Event is the envelope of every change of news published to kafka.
+// ID is the idempotency key, it stays the same when the event is published again.
+// Payload is the news after the change, a deleted news carries its last state.
+type Event struct {
+	ID            string    `json:"id"`
+	Type          EventType `json:"type"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	SchemaVersion int       `json:"schema_version"`
+	Payload       News      `json:"payload"`
+}
+
+// NewEvent returns event of eventType with a random ID
+func NewEvent(eventType EventType, data *News) (*Event, error) {
+	id := make([]byte, 16)
+	if _, e := rand.Read(id); e != nil {
+		return nil, e
+	}
+	return &Event{
+		ID:            hex.EncodeToString(id),
+		Type:          eventType,
+		OccurredAt:    time.Now().UTC(),
+		SchemaVersion: EventSchemaVersion,
+		Payload:       *data,
+	}, nil
+}
+
+// DecodeEvent reads an event published by any schema version.
+// Messages published before the envelope existed are plain news and are read as NewsCreated,
+// their ID is derived from the message so the same message always gets the same idempotency key.
+func DecodeEvent(value []byte) (*Event, error) {
+	version := struct {
+		SchemaVersion int `json:"schema_version"`
+	}{}
+	if e := json.Unmarshal(value, &version); e != nil {
+		return nil, e
+	}
+	switch version.SchemaVersion {
+	case 0:
+		news := News{}
+		if e := json.Unmarshal(value, &news); e != nil {
+			return nil, e
+		}
+		sum := sha256.Sum256(value)
+		return &Event{
+			ID:            hex.EncodeToString(sum[:16]),
+			Type:          NewsCreated,
+			OccurredAt:    news.Created,
+			SchemaVersion: EventSchemaVersion,
+			Payload:       news,
+		}, nil
+	case EventSchemaVersion:
+	default:
+		return nil, fmt.Errorf("unsupported event schema version %d", version.SchemaVersion)
+	}
+	event := new(Event)
+	if e := json.Unmarshal(value, event); e != nil {
+		return nil, e
+	}
+	switch event.Type {
+	case NewsCreated, NewsUpdated, NewsDeleted:
+	default:
+		return nil, fmt.Errorf("unknown event type %q", event.Type)
+	}
+	return event, nil
+}
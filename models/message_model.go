@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Message is one kafka message, Topic, Partition and Offset are filled only for a message that is read
+type Message struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Key       []byte `json:"key"`
+	Value     []byte `json:"value"`
+}
+
+// DeadLetter is published to the dead-letter topic when a message still fails after every retry,
+// Message keeps the original key and payload so it can be re-driven to the main topic
+type DeadLetter struct {
+	Message  Message   `json:"message"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
@@ -5,30 +5,62 @@ import (
 	"time"
 )
 
+// News is versioned by the repository, Version starts from 1 and is increased on every update
 type News struct {
 	ID      int       `json:"id" bson:"_id" msgpack:"_id" db:"id"`
 	Author  string    `json:"author" bson:"author" msgpack:"author" db:"author"`
 	Body    string    `json:"body" bson:"body" msgpack:"body" db:"body"`
 	Created time.Time `json:"created" bson:"created" msgpack:"created" db:"created"`
+	Version int       `json:"version" bson:"version" msgpack:"version" db:"version"`
 }
 
 func (m *News) TableName() string {
 	return "news"
 }
 
+// ElasticNews is the indexed part of News, Author and Body are analyzed for full-text search
 type ElasticNews struct {
 	ID      int       `json:"id" bson:"id" msgpack:"id"`
+	Author  string    `json:"author" bson:"author" msgpack:"author"`
+	Body    string    `json:"body" bson:"body" msgpack:"body"`
 	Created time.Time `json:"created" bson:"created" msgpack:"created"`
 }
 
+func NewElasticNews(data News) ElasticNews {
+	return ElasticNews{
+		ID:      data.ID,
+		Author:  data.Author,
+		Body:    data.Body,
+		Created: data.Created,
+	}
+}
+
 func (m *ElasticNews) TableName() string {
 	return "news"
 }
 
-func (m News) UnmarshalBinary(data []byte) error {
+func (m *News) UnmarshalBinary(data []byte) error {
 	return json.Unmarshal(data, m)
 }
 
 func (m *News) MarshalBinary() ([]byte, error) {
 	return json.Marshal(m)
 }
+
+// SortByIDs orders data following ids and returns the ids that are not found in data
+func SortByIDs(ids []int, data []News) ([]News, []int) {
+	dataMap := map[int]News{}
+	for _, v := range data {
+		dataMap[v.ID] = v
+	}
+	res := []News{}
+	missing := []int{}
+	for _, id := range ids {
+		if v, ok := dataMap[id]; ok {
+			res = append(res, v)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return res, missing
+}
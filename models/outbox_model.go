@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Outbox is an event waiting to be published to kafka, it is written in the same transaction as the change of news
+type Outbox struct {
+	ID      int64     `json:"id" bson:"_id" db:"id"`
+	NewsID  int       `json:"news_id" bson:"news_id" db:"news_id"`
+	Payload []byte    `json:"payload" bson:"payload" db:"payload"`
+	Created time.Time `json:"created" bson:"created" db:"created"`
+}
+
+func (m *Outbox) TableName() string {
+	return "outbox"
+}
+
+// NewOutbox returns outbox message carrying event as kafka payload, ID is given by the database
+func NewOutbox(event *Event) (*Outbox, error) {
+	payload, e := json.Marshal(event)
+	if e != nil {
+		return nil, e
+	}
+	return &Outbox{
+		NewsID:  event.Payload.ID,
+		Payload: payload,
+		Created: time.Now().UTC(),
+	}, nil
+}
@@ -5,6 +5,9 @@ type GetPayload struct {
 	Offset int                    `json:"offset" bson:"offset" msgpack:"offset"`
 	Limit  int                    `json:"limit" bson:"limit" msgpack:"limit"`
 	Order  map[string]bool        `json:"order" bson:"order" msgpack:"order"`
+	// AfterID keeps only news with a greater ID, NewsRepository.List pages by key with it and Order{"id": true}.
+	// Unlike Offset a page is not shifted by news deleted meanwhile.
+	AfterID *int `json:"after_id,omitempty" bson:"after_id,omitempty" msgpack:"after_id,omitempty"`
 }
 
 func (m *GetPayload) String() string {
@@ -0,0 +1,12 @@
+package models
+
+// ReindexResult tells which physical index the alias points to after reindexing and which ones it pointed to before
+type ReindexResult struct {
+	Index    string
+	Indexed  int
+	Previous []string
+	// Changed is the number of news changed or deleted during the build, indexed again after the swap
+	Changed int
+	// Deleted is true when Previous indices have been deleted
+	Deleted bool
+}
@@ -0,0 +1,47 @@
+package models
+
+// SearchPayload is a full-text query over author and body of news
+type SearchPayload struct {
+	Query     string    `json:"q" bson:"q" msgpack:"q"`
+	Offset    int       `json:"offset" bson:"offset" msgpack:"offset"`
+	Limit     int       `json:"limit" bson:"limit" msgpack:"limit"`
+	Highlight Highlight `json:"highlight" bson:"highlight" msgpack:"highlight"`
+}
+
+// HighlightTags are the HTML elements Highlight accepts, PreTag and PostTag open and close the same one
+var HighlightTags = []string{"em", "strong", "mark", "b", "i", "u"}
+
+// Highlight wraps every matched term of a fragment with PreTag and PostTag, the rest of the fragment is HTML-escaped.
+// Body is split into fragments of about FragmentSize characters while author is always one fragment.
+type Highlight struct {
+	FragmentSize int    `json:"fragment_size" bson:"fragment_size" msgpack:"fragment_size"`
+	PreTag       string `json:"pre_tag" bson:"pre_tag" msgpack:"pre_tag"`
+	PostTag      string `json:"post_tag" bson:"post_tag" msgpack:"post_tag"`
+}
+
+// ElasticHit is one indexed news matching a search, a higher Score is more relevant.
+// Highlights holds the matching fragments by field name.
+type ElasticHit struct {
+	News       ElasticNews
+	Score      float64
+	Highlights map[string][]string
+}
+
+// ElasticSearchResult holds one page of hits ordered by relevance, Total counts every matching news
+type ElasticSearchResult struct {
+	Total int64
+	Hits  []ElasticHit
+}
+
+// SearchHit tells why News matched with the highlighted fragments of its author and body, a field without match is left out
+type SearchHit struct {
+	News       News                `json:"news" bson:"news" msgpack:"news"`
+	Score      float64             `json:"score" bson:"score" msgpack:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty" bson:"highlights,omitempty" msgpack:"highlights,omitempty"`
+}
+
+// SearchResult is returned by search endpoint, Total counts every matching news and not only the returned page
+type SearchResult struct {
+	Total int64       `json:"total" bson:"total" msgpack:"total"`
+	Hits  []SearchHit `json:"hits" bson:"hits" msgpack:"hits"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// StatsIntervals are the calendar intervals the created histogram of stats accepts
+var StatsIntervals = []string{"minute", "hour", "day", "week", "month", "quarter", "year"}
+
+// MaxStatsBuckets is the number of author and histogram buckets stats may return together,
+// like the default search.max_buckets of elasticsearch
+const MaxStatsBuckets = 10000
+
+// StatsPayload selects the news counted by stats, From is inclusive, To is exclusive and a zero one leaves that side open.
+// Histogram buckets are one Interval long and start at midnight of TimeZone, an IANA name like Asia/Jakarta,
+// a week starts on Monday.
+type StatsPayload struct {
+	Interval string
+	TimeZone string
+	// Authors is the number of authors with the most news to count
+	Authors int
+	From    time.Time
+	To      time.Time
+}
+
+type AuthorCount struct {
+	Author string `json:"author" bson:"author" msgpack:"author"`
+	Count  int64  `json:"count" bson:"count" msgpack:"count"`
+}
+
+// DateCount counts the news created from Date until the next bucket
+type DateCount struct {
+	Date  time.Time `json:"date" bson:"date" msgpack:"date"`
+	Count int64     `json:"count" bson:"count" msgpack:"count"`
+}
+
+// NewsStats is returned by stats endpoint. Authors are ordered by most news first and OtherAuthors counts the news
+// of the authors left out. Histogram is ordered by date and includes the empty buckets between the first and last news.
+type NewsStats struct {
+	Total        int64         `json:"total" bson:"total" msgpack:"total"`
+	Authors      []AuthorCount `json:"authors" bson:"authors" msgpack:"authors"`
+	OtherAuthors int64         `json:"other_authors" bson:"other_authors" msgpack:"other_authors"`
+	Histogram    []DateCount   `json:"histogram" bson:"histogram" msgpack:"histogram"`
+}
+
+// StatsBucketStart truncates t to the start of its calendar interval in loc like date_histogram of elasticsearch
+func StatsBucketStart(t time.Time, interval string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	year, month, day := t.Date()
+	switch interval {
+	case "minute":
+		return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, loc)
+	case "hour":
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, loc)
+	case "week":
+		// weeks start on Monday
+		return time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	case "quarter":
+		return time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, loc)
+	case "year":
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// NextStatsBucket returns the start of the bucket following the one starting at start
+func NextStatsBucket(start time.Time, interval string) time.Time {
+	switch interval {
+	case "minute":
+		return start.Add(time.Minute)
+	case "hour":
+		return start.Add(time.Hour)
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	case "quarter":
+		return start.AddDate(0, 3, 0)
+	case "year":
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
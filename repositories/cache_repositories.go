@@ -1,12 +1,15 @@
 package repositories
 
 import (
+	"context"
+
 	m "github.com/rinosukmandityo/maknews/models"
 )
 
 type CacheRepository interface {
-	GetBy(param m.GetPayload) ([]m.News, error)
-	Store(data []m.News) error
-	Update(data m.News) error
-	Delete(data m.News) error
+	GetBy(ctx context.Context, param m.GetPayload) ([]m.News, error)
+	Store(ctx context.Context, data []m.News) error
+	Update(ctx context.Context, data m.News) error
+	Delete(ctx context.Context, data m.News) error
+	Close() error
 }
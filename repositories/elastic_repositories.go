@@ -1,12 +1,32 @@
 package repositories
 
 import (
+	"context"
+
 	m "github.com/rinosukmandityo/maknews/models"
 )
 
+// ElasticRepository reads and writes news through an alias over versioned physical indices,
+// the index management methods let reindexing build a new physical index and swap the alias to it
 type ElasticRepository interface {
-	GetBy(param m.GetPayload) ([]m.ElasticNews, error)
-	Store(data m.ElasticNews) error
-	Update(data m.ElasticNews, id int) error
-	Delete(id int) error
+	GetBy(ctx context.Context, param m.GetPayload) ([]m.ElasticNews, error)
+	Store(ctx context.Context, data m.ElasticNews) error
+	// StoreMany indexes data in one bulk request and returns one error per data (nil when indexed)
+	StoreMany(ctx context.Context, data []m.ElasticNews) ([]error, error)
+	Update(ctx context.Context, data m.ElasticNews, id int) error
+	Delete(ctx context.Context, id int) error
+	// Search matches param.Query against author and body, hits are ordered by relevance
+	Search(ctx context.Context, param m.SearchPayload) (*m.ElasticSearchResult, error)
+	// Stats counts news by author and by param.Interval of created, param is expected to be complete
+	Stats(ctx context.Context, param m.StatsPayload) (*m.NewsStats, error)
+	// CreateIndex creates the next physical index with the current mapping and returns its name,
+	// it is neither searched nor written through the alias until SwapIndex
+	CreateIndex(ctx context.Context) (string, error)
+	// StoreManyIn is StoreMany into the physical index instead of the alias
+	StoreManyIn(ctx context.Context, index string, data []m.ElasticNews) ([]error, error)
+	// SwapIndex points the alias to index alone in one atomic action and returns the indices it pointed to before
+	SwapIndex(ctx context.Context, index string) ([]string, error)
+	// DeleteIndex refuses to delete the index the alias points to
+	DeleteIndex(ctx context.Context, index string) error
+	Close() error
 }
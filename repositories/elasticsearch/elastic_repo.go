@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rinosukmandityo/maknews/helper"
@@ -15,6 +18,38 @@ import (
 	"github.com/pkg/errors"
 )
 
+// mappingVersion is kept in the _meta of every physical index, bump it whenever indexBody changes
+// and run maknews reindex to move the alias to an index with the new mapping
+const mappingVersion = 2
+
+// maxBodyFragments is the number of highlighted body fragments returned per hit
+const maxBodyFragments = 3
+
+// indexBody defines the settings and mapping of a physical index. Author and body are analyzed text for full-text search,
+// author keeps a keyword sub-field for exact match and body keeps term offsets to highlight it without analyzing it again.
+// One shard keeps relevance scores consistent for the size of this index.
+func indexBody() map[string]interface{} {
+	return map[string]interface{}{
+		"settings": map[string]interface{}{
+			"number_of_shards": 1,
+		},
+		"mappings": map[string]interface{}{
+			"dynamic": "strict",
+			"_meta":   map[string]interface{}{"version": mappingVersion},
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{"type": "integer"},
+				"author": map[string]interface{}{
+					"type":   "text",
+					"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256}},
+				},
+				"body":    map[string]interface{}{"type": "text", "index_options": "offsets"},
+				"created": map[string]interface{}{"type": "date"},
+			},
+		},
+	}
+}
+
+// newsElasticRepository reads and writes through the alias index, its physical indices are named index_v1, index_v2 and so on
 type newsElasticRepository struct {
 	client  *elasticapi.Client
 	index   string
@@ -32,6 +67,7 @@ func newNewsClient(URL string) (*elasticapi.Client, error) {
 	return client, e
 }
 
+// NewNewsRepository creates the first physical index behind alias index when neither of them exists
 func NewNewsRepository(URL, index string, timeout int) (repo.ElasticRepository, error) {
 	repo := &newsElasticRepository{
 		index:   index,
@@ -44,6 +80,40 @@ func NewNewsRepository(URL, index string, timeout int) (repo.ElasticRepository,
 	}
 	repo.client = client
 
+	ctx, cancel := context.WithTimeout(context.Background(), repo.timeout)
+	defer cancel()
+	if e := repo.ensureAlias(ctx); e != nil {
+		client.Stop()
+		return nil, errors.Wrap(e, "repository.NewNewsRepository")
+	}
+
+	return repo, nil
+}
+
+// NewReadOnlyNewsRepository is NewNewsRepository for dry runs, it only resolves an existing alias and never creates an index
+func NewReadOnlyNewsRepository(URL, index string, timeout int) (repo.ElasticRepository, error) {
+	repo := &newsElasticRepository{
+		index:   index,
+		timeout: time.Duration(timeout) * time.Second,
+	}
+
+	client, e := newNewsClient(URL)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewReadOnlyNewsRepository")
+	}
+	repo.client = client
+
+	ctx, cancel := context.WithTimeout(context.Background(), repo.timeout)
+	defer cancel()
+	found, _, e := repo.findAlias(ctx)
+	if e != nil {
+		client.Stop()
+		return nil, errors.Wrap(e, "repository.NewReadOnlyNewsRepository")
+	}
+	if !found {
+		log.Printf("elasticsearch index %s does not exist yet, it is created by maknews serve or reindex\n", index)
+	}
+
 	return repo, nil
 }
 
@@ -62,33 +132,88 @@ func ping(ctx context.Context, client *elasticapi.Client, url string) error {
 	return errors.New("elastic client is nil")
 }
 
-func CreateIndexIfDoesNotExist(ctx context.Context, client *elasticapi.Client, indexName string) error {
-	exists, e := client.IndexExists(indexName).Do(ctx)
+// findAlias reports whether the alias, or a plain index of the same name created before the alias was introduced, exists.
+// It only reads the cluster and returns the physical indices it has seen.
+func (r *newsElasticRepository) findAlias(ctx context.Context) (bool, map[string]*elasticapi.IndicesGetResponse, error) {
+	indices, e := r.physicalIndices(ctx)
 	if e != nil {
-		return e
+		return false, nil, e
+	}
+	for name, v := range indices {
+		if _, ok := v.Aliases[r.index]; ok {
+			if version := indexMappingVersion(v); version < mappingVersion {
+				log.Printf("elasticsearch index %s has mapping version %d, run maknews reindex to upgrade it to version %d\n", name, version, mappingVersion)
+			}
+			return true, indices, nil
+		}
+	}
+	exists, e := r.client.IndexExists(r.index).Do(ctx)
+	if e != nil {
+		return false, indices, e
 	}
-
 	if exists {
-		return nil
+		log.Printf("elasticsearch index %s is not versioned, run maknews reindex to put it behind an alias\n", r.index)
 	}
+	return exists, indices, nil
+}
 
-	res, e := client.CreateIndex(indexName).Do(ctx)
+// ensureAlias keeps an existing alias, or a plain index of the same name which is replaced on the next reindex,
+// otherwise it creates the first physical index behind the alias
+func (r *newsElasticRepository) ensureAlias(ctx context.Context) error {
+	found, indices, e := r.findAlias(ctx)
+	if e != nil || found {
+		return e
+	}
 
-	if e != nil {
+	body := indexBody()
+	body["aliases"] = map[string]interface{}{r.index: map[string]interface{}{}}
+	if e := r.createIndex(ctx, r.nextIndex(indices), body); e != nil {
+		// another process may have created it meanwhile
+		if exists, _ := r.client.IndexExists(r.index).Do(ctx); exists {
+			return nil
+		}
 		return e
 	}
+	return nil
+}
 
+func (r *newsElasticRepository) createIndex(ctx context.Context, name string, body map[string]interface{}) error {
+	res, e := r.client.CreateIndex(name).BodyJson(body).Do(ctx)
+	if e != nil {
+		return e
+	}
 	if !res.Acknowledged {
 		return errors.New("CreateIndex was not acknowledged. Check that timeout value is correct.")
 	}
-
 	return nil
 }
 
+// physicalIndices returns every index_vN index, whether the alias points to it or not
+func (r *newsElasticRepository) physicalIndices(ctx context.Context) (map[string]*elasticapi.IndicesGetResponse, error) {
+	return r.client.IndexGet(r.index + "_v*").Do(ctx)
+}
+
+// nextIndex names the index after the highest existing one, so a name is never reused
+func (r *newsElasticRepository) nextIndex(indices map[string]*elasticapi.IndicesGetResponse) string {
+	last := 0
+	for name := range indices {
+		if n, e := strconv.Atoi(strings.TrimPrefix(name, r.index+"_v")); e == nil && n > last {
+			last = n
+		}
+	}
+	return fmt.Sprintf("%s_v%d", r.index, last+1)
+}
+
+func indexMappingVersion(index *elasticapi.IndicesGetResponse) int {
+	meta, _ := index.Mappings["_meta"].(map[string]interface{})
+	version, _ := meta["version"].(float64)
+	return int(version)
+}
+
 func getResult(searchResult *elasticapi.SearchResult) ([]m.ElasticNews, error) {
 	res := []m.ElasticNews{}
 	if searchResult.TotalHits() == 0 {
-		return res, errors.Wrap(errors.New("Data Not Found"), "repository.News.GetBy")
+		return res, errors.Wrap(helper.ErrDataNotFound, "repository.News.GetBy")
 	}
 	for _, hit := range searchResult.Hits.Hits {
 		_res := m.ElasticNews{}
@@ -101,8 +226,14 @@ func getResult(searchResult *elasticapi.SearchResult) ([]m.ElasticNews, error) {
 	return res, nil
 }
 
-func (r *newsElasticRepository) GetBy(param m.GetPayload) ([]m.ElasticNews, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+// Close stops the background health check of the client
+func (r *newsElasticRepository) Close() error {
+	r.client.Stop()
+	return nil
+}
+
+func (r *newsElasticRepository) GetBy(ctx context.Context, param m.GetPayload) ([]m.ElasticNews, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	q := constructGetBy(param)
@@ -135,11 +266,11 @@ func (r *newsElasticRepository) GetBy(param m.GetPayload) ([]m.ElasticNews, erro
 	return res, nil
 }
 
-func (r *newsElasticRepository) Store(data m.ElasticNews) error {
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+func (r *newsElasticRepository) Store(ctx context.Context, data m.ElasticNews) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
-	_, e := r.client.Index().Index(r.index).Type(data.TableName()).Id(strconv.Itoa(data.ID)).BodyJson(data).Do(ctx)
+	_, e := r.client.Index().Index(r.index).Id(strconv.Itoa(data.ID)).BodyJson(data).Do(ctx)
 	if e != nil {
 		return errors.Wrap(e, "repository.News.Store")
 	}
@@ -148,36 +279,72 @@ func (r *newsElasticRepository) Store(data m.ElasticNews) error {
 
 }
 
-func (r *newsElasticRepository) Update(data m.ElasticNews, id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+func (r *newsElasticRepository) StoreMany(ctx context.Context, data []m.ElasticNews) ([]error, error) {
+	return r.storeMany(ctx, r.index, data, "repository.News.StoreMany")
+}
+
+func (r *newsElasticRepository) StoreManyIn(ctx context.Context, index string, data []m.ElasticNews) ([]error, error) {
+	return r.storeMany(ctx, index, data, "repository.News.StoreManyIn")
+}
+
+func (r *newsElasticRepository) storeMany(ctx context.Context, index string, data []m.ElasticNews, op string) ([]error, error) {
+	res := make([]error, len(data))
+	if len(data) == 0 {
+		return res, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	bulk := r.client.Bulk().Index(index)
+	for _, v := range data {
+		bulk.Add(elasticapi.NewBulkIndexRequest().Id(strconv.Itoa(v.ID)).Doc(v))
+	}
+	bulkRes, e := bulk.Do(ctx)
+	if e != nil {
+		return res, errors.Wrap(e, op)
+	}
+	// bulk response items follow request order
+	for i, item := range bulkRes.Items {
+		for _, v := range item {
+			if i < len(res) && v.Error != nil {
+				res[i] = errors.Wrap(errors.New(v.Error.Reason), op)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (r *newsElasticRepository) Update(ctx context.Context, data m.ElasticNews, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	idString := strconv.Itoa(id)
 
-	res, e := r.client.Update().Index(r.index).
-		Type(data.TableName()).Id(idString).Doc(data).Do(ctx)
+	res, e := r.client.Update().Index(r.index).Id(idString).Doc(data).Do(ctx)
 	if e != nil {
 		return errors.Wrap(e, "repository.News.Update")
 	}
 
-	if res.Result != "updated" {
+	// noop means the indexed news already has data
+	if res.Result != "updated" && res.Result != "noop" {
 		return errors.Wrap(helper.ErrDataNotFound, "repository.News.Update")
 	}
 
 	return nil
 
 }
-func (r *newsElasticRepository) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+func (r *newsElasticRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	q := constructDeleteQuery(map[string]interface{}{"id": id})
-	res, e := r.client.DeleteByQuery(r.index).Type(new(m.News).TableName()).Query(q).Do(ctx)
+	res, e := r.client.DeleteByQuery(r.index).Query(q).Do(ctx)
 	if e != nil {
 		return errors.Wrap(e, "repository.News.Delete")
 	}
 	if res.Total == 0 {
-		return errors.Wrap(errors.New("Data Not Found"), "repository.News.Delete")
+		return errors.Wrap(helper.ErrDataNotFound, "repository.News.Delete")
 	}
 
 	// Flush data (need for refreshing data in index) after this command possible to do get.
@@ -186,3 +353,189 @@ func (r *newsElasticRepository) Delete(id int) error {
 	return nil
 
 }
+
+func (r *newsElasticRepository) Search(ctx context.Context, param m.SearchPayload) (*m.ElasticSearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	searchService := r.client.Search().
+		Index(r.index).
+		Query(constructSearch(param.Query)).
+		From(param.Offset).
+		TrackTotalHits(true).
+		Highlight(constructHighlight(param.Highlight))
+	if param.Limit > 0 {
+		searchService.Size(param.Limit)
+	}
+	searchResult, e := searchService.Do(ctx)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.News.Search")
+	}
+
+	res := &m.ElasticSearchResult{
+		Total: searchResult.TotalHits(),
+		Hits:  []m.ElasticHit{},
+	}
+	for _, hit := range searchResult.Hits.Hits {
+		_res := m.ElasticHit{}
+		if e := json.Unmarshal(hit.Source, &_res.News); e != nil {
+			return res, errors.Wrap(e, "repository.News.Search")
+		}
+		if hit.Score != nil {
+			_res.Score = *hit.Score
+		}
+		_res.Highlights = hit.Highlight
+		res.Hits = append(res.Hits, _res)
+	}
+	return res, nil
+}
+
+// Stats aggregates author on its keyword sub-field, so an author longer than 256 characters is not counted
+func (r *newsElasticRepository) Stats(ctx context.Context, param m.StatsPayload) (*m.NewsStats, error) {
+	loc, e := time.LoadLocation(param.TimeZone)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.News.Stats")
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	searchResult, e := r.client.Search().
+		Index(r.index).
+		Query(constructStats(param)).
+		Size(0).
+		TrackTotalHits(true).
+		Aggregation("authors", elasticapi.NewTermsAggregation().Field("author.keyword").Size(param.Authors)).
+		Aggregation("created", elasticapi.NewDateHistogramAggregation().
+			Field("created").
+			CalendarInterval(param.Interval).
+			TimeZone(param.TimeZone)).
+		Do(ctx)
+	if tooManyBuckets(e) {
+		return nil, errors.Wrapf(helper.ErrDataInvalid, "repository.News.Stats %s", e.Error())
+	}
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.News.Stats")
+	}
+
+	res := &m.NewsStats{
+		Total:     searchResult.TotalHits(),
+		Authors:   []m.AuthorCount{},
+		Histogram: []m.DateCount{},
+	}
+	if authors, ok := searchResult.Aggregations.Terms("authors"); ok {
+		res.OtherAuthors = authors.SumOfOtherDocCount
+		for _, v := range authors.Buckets {
+			author, _ := v.Key.(string)
+			res.Authors = append(res.Authors, m.AuthorCount{Author: author, Count: v.DocCount})
+		}
+	}
+	if created, ok := searchResult.Aggregations.DateHistogram("created"); ok {
+		for _, v := range created.Buckets {
+			// the key is the bucket start in epoch milliseconds
+			date := time.Unix(0, int64(v.Key)*int64(time.Millisecond)).In(loc)
+			res.Histogram = append(res.Histogram, m.DateCount{Date: date, Count: v.DocCount})
+		}
+	}
+	return res, nil
+}
+
+// tooManyBuckets tells whether e is the rejection of an aggregation returning more than search.max_buckets
+func tooManyBuckets(e error) bool {
+	ee, ok := e.(*elasticapi.Error)
+	if !ok || ee.Details == nil {
+		return false
+	}
+	if ee.Details.Type == "too_many_buckets_exception" || ee.Details.CausedBy["type"] == "too_many_buckets_exception" {
+		return true
+	}
+	for _, v := range ee.Details.RootCause {
+		if v.Type == "too_many_buckets_exception" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *newsElasticRepository) CreateIndex(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	indices, e := r.physicalIndices(ctx)
+	if e != nil {
+		return "", errors.Wrap(e, "repository.News.CreateIndex")
+	}
+	name := r.nextIndex(indices)
+	if e := r.createIndex(ctx, name, indexBody()); e != nil {
+		return "", errors.Wrap(e, "repository.News.CreateIndex")
+	}
+	return name, nil
+}
+
+// SwapIndex also deletes the plain index named like the alias, if any, in the same action since both can not coexist.
+// That index is not returned as it no longer exists.
+func (r *newsElasticRepository) SwapIndex(ctx context.Context, index string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	indices, e := r.physicalIndices(ctx)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.News.SwapIndex")
+	}
+	if _, ok := indices[index]; !ok {
+		return nil, errors.Wrapf(helper.ErrDataNotFound, "repository.News.SwapIndex index %s", index)
+	}
+	// documents stored in index are searchable as soon as the alias points to it
+	if _, e := r.client.Refresh(index).Do(ctx); e != nil {
+		return nil, errors.Wrap(e, "repository.News.SwapIndex")
+	}
+
+	previous := []string{}
+	actions := []elasticapi.AliasAction{elasticapi.NewAliasAddAction(r.index).Index(index)}
+	for name, v := range indices {
+		if _, ok := v.Aliases[r.index]; ok && name != index {
+			previous = append(previous, name)
+			actions = append(actions, elasticapi.NewAliasRemoveAction(r.index).Index(name))
+		}
+	}
+	if len(previous) == 0 {
+		exists, e := r.client.IndexExists(r.index).Do(ctx)
+		if e != nil {
+			return nil, errors.Wrap(e, "repository.News.SwapIndex")
+		}
+		if _, aliased := indices[index].Aliases[r.index]; exists && !aliased {
+			log.Printf("elasticsearch index %s is replaced by alias %s to %s\n", r.index, r.index, index)
+			actions = append(actions, elasticapi.NewAliasRemoveIndexAction(r.index))
+		}
+	}
+	sort.Strings(previous)
+
+	res, e := r.client.Alias().Action(actions...).Do(ctx)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.News.SwapIndex")
+	}
+	if !res.Acknowledged {
+		return nil, errors.New("repository.News.SwapIndex alias update was not acknowledged")
+	}
+	return previous, nil
+}
+
+func (r *newsElasticRepository) DeleteIndex(ctx context.Context, index string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	indices, e := r.physicalIndices(ctx)
+	if e != nil {
+		return errors.Wrap(e, "repository.News.DeleteIndex")
+	}
+	v, ok := indices[index]
+	if !ok {
+		return errors.Wrapf(helper.ErrDataNotFound, "repository.News.DeleteIndex index %s", index)
+	}
+	if _, ok := v.Aliases[r.index]; ok {
+		return errors.Wrapf(helper.ErrIndexInUse, "repository.News.DeleteIndex index %s", index)
+	}
+	if _, e := r.client.DeleteIndex(index).Do(ctx); e != nil {
+		return errors.Wrap(e, "repository.News.DeleteIndex")
+	}
+	return nil
+}
@@ -3,14 +3,19 @@
 package elastic_test
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
 
 	_ "github.com/rinosukmandityo/maknews/api"
+	"github.com/rinosukmandityo/maknews/config"
+	"github.com/rinosukmandityo/maknews/helper"
 	m "github.com/rinosukmandityo/maknews/models"
 	. "github.com/rinosukmandityo/maknews/repositories"
 	rh "github.com/rinosukmandityo/maknews/repositories/helper"
+
+	"github.com/pkg/errors"
 )
 
 /*
@@ -21,6 +26,7 @@ import (
 */
 
 var (
+	ctx  = context.Background()
 	repo ElasticRepository
 )
 
@@ -38,15 +44,23 @@ func ListTestData() []m.ElasticNews {
 }
 
 func init() {
-	repo = rh.ElasticRepo()
+	cfg, e := config.Load("")
+	if e != nil {
+		panic(e)
+	}
+	if repo, e = rh.ElasticRepo(cfg); e != nil {
+		panic(e)
+	}
 }
 
 func TestService(t *testing.T) {
 	t.Run("Insert Data", InsertData)
 	t.Run("Get All", GetAll)
+	t.Run("Stats", Stats)
 	t.Run("Update Data", UpdateData)
 	t.Run("Delete Data", DeleteData)
 	t.Run("Get Data", GetData)
+	t.Run("Swap Index", SwapIndex)
 	// t.Run("Delete All", DeleteAll)
 }
 
@@ -58,7 +72,7 @@ func InsertData(t *testing.T) {
 	for _, data := range testdata {
 		wg.Add(1)
 		go func(_data m.ElasticNews) {
-			repo.Delete(_data.ID)
+			repo.Delete(ctx, _data.ID)
 			wg.Done()
 		}(data)
 	}
@@ -68,7 +82,7 @@ func InsertData(t *testing.T) {
 		for _, data := range testdata {
 			wg.Add(1)
 			go func(_data m.ElasticNews) {
-				if e := repo.Store(_data); e != nil {
+				if e := repo.Store(ctx, _data); e != nil {
 					t.Errorf("[ERROR] - Failed to save data %s ", e.Error())
 				}
 				wg.Done()
@@ -85,7 +99,7 @@ func InsertData(t *testing.T) {
 				Offset: 0,
 				Limit:  10,
 			}
-			if res, e := repo.GetBy(param); e != nil || len(res) == 0 {
+			if res, e := repo.GetBy(ctx, param); e != nil || len(res) == 0 {
 				t.Errorf("[ERROR] - Failed to get data")
 			}
 		}
@@ -98,13 +112,13 @@ func UpdateData(t *testing.T) {
 		_data := testdata[0]
 		_data.Created = time.Time{}
 
-		if e := repo.Update(_data, _data.ID); e != nil {
+		if e := repo.Update(ctx, _data, _data.ID); e != nil {
 			t.Errorf("[ERROR] - Failed to update data %s ", e.Error())
 		}
 	})
 	t.Run("Case 2: Negative Test", func(t *testing.T) {
 		_data := m.ElasticNews{ID: -9999}
-		if e := repo.Update(_data, _data.ID); e == nil {
+		if e := repo.Update(ctx, _data, _data.ID); e == nil {
 			t.Error("[ERROR] - It should be error 'Data Not Found'")
 		}
 	})
@@ -114,13 +128,13 @@ func DeleteData(t *testing.T) {
 	testdata := ListTestData()
 	t.Run("Case 1: Delete data", func(t *testing.T) {
 		_data := testdata[1]
-		if e := repo.Delete(_data.ID); e != nil {
+		if e := repo.Delete(ctx, _data.ID); e != nil {
 			t.Errorf("[ERROR] - Failed to delete data %s ", e.Error())
 		}
 	})
 	t.Run("Case 2: Negative Test", func(t *testing.T) {
 		_data := testdata[1]
-		if e := repo.Delete(_data.ID); e == nil {
+		if e := repo.Delete(ctx, _data.ID); e == nil {
 			t.Error("[ERROR] - It should be error 'Data Not Found'")
 		}
 	})
@@ -140,7 +154,7 @@ func GetData(t *testing.T) {
 			Offset: 0,
 			Limit:  10,
 		}
-		if res, e := repo.GetBy(param); e != nil || len(res) == 0 {
+		if res, e := repo.GetBy(ctx, param); e != nil || len(res) == 0 {
 			t.Errorf("[ERROR] - Failed to get data")
 		}
 	})
@@ -155,7 +169,7 @@ func GetData(t *testing.T) {
 			Offset: 0,
 			Limit:  10,
 		}
-		if _, e := repo.GetBy(param); e == nil {
+		if _, e := repo.GetBy(ctx, param); e == nil {
 			t.Error("[ERROR] - It should be error 'Data Not Found'")
 		}
 	})
@@ -171,7 +185,7 @@ func GetAll(t *testing.T) {
 			Offset: 0,
 			Limit:  10,
 		}
-		res, e := repo.GetBy(param)
+		res, e := repo.GetBy(ctx, param)
 		if e != nil || len(res) == 0 {
 			t.Errorf("[ERROR] - Failed to get data")
 		}
@@ -185,11 +199,50 @@ func GetAll(t *testing.T) {
 	})
 }
 
+func SwapIndex(t *testing.T) {
+	testdata := ListTestData()
+	index, e := repo.CreateIndex(ctx)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to create index %s", e.Error())
+	}
+	if itemErrs, e := repo.StoreManyIn(ctx, index, testdata); e != nil || itemErrs[0] != nil {
+		t.Fatalf("[ERROR] - Failed to save data %v %v", itemErrs, e)
+	}
+	previous, e := repo.SwapIndex(ctx, index)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to swap index %s", e.Error())
+	}
+	if res, e := repo.GetBy(ctx, m.GetPayload{Limit: 10}); e != nil || len(res) != len(testdata) {
+		t.Errorf("[ERROR] - Alias should read %s %v %v", index, res, e)
+	}
+	if e := repo.DeleteIndex(ctx, index); errors.Cause(e) != helper.ErrIndexInUse {
+		t.Errorf("[ERROR] - It should be error '%s' %v", helper.ErrIndexInUse.Error(), e)
+	}
+	for _, v := range previous {
+		if e := repo.DeleteIndex(ctx, v); e != nil {
+			t.Errorf("[ERROR] - Failed to delete index %s %s", v, e.Error())
+		}
+	}
+}
+
+func Stats(t *testing.T) {
+	res, e := repo.Stats(ctx, m.StatsPayload{Interval: "day", TimeZone: "Asia/Jakarta", Authors: 10})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get stats %s", e.Error())
+	}
+	if res.Total < int64(len(ListTestData())) || len(res.Authors) == 0 || len(res.Histogram) == 0 {
+		t.Errorf("[ERROR] - Expected every test data counted but got %+v", res)
+	}
+	if _, offset := res.Histogram[0].Date.Zone(); offset != 7*60*60 {
+		t.Errorf("[ERROR] - Expected buckets in Asia/Jakarta but got %s", res.Histogram[0].Date)
+	}
+}
+
 func DeleteAll(t *testing.T) {
 	testdata := ListTestData()
 	t.Run("Case 1: Delete data", func(t *testing.T) {
 		for _, _data := range testdata {
-			if e := repo.Delete(_data.ID); e != nil {
+			if e := repo.Delete(ctx, _data.ID); e != nil {
 				t.Errorf("[ERROR] - Failed to delete data %s ", e.Error())
 			}
 		}
@@ -27,3 +27,35 @@ func constructGetBy(payload m.GetPayload) *elasticapi.BoolQuery {
 
 	return q
 }
+
+// constructSearch scores news by how well query matches author or body, the best matching field counts
+func constructSearch(query string) *elasticapi.MultiMatchQuery {
+	return elasticapi.NewMultiMatchQuery(query, "author", "body").Type("best_fields")
+}
+
+// constructHighlight escapes the text around matched terms with the html encoder, author is highlighted whole
+func constructHighlight(param m.Highlight) *elasticapi.Highlight {
+	return elasticapi.NewHighlight().
+		Encoder("html").
+		PreTags(param.PreTag).
+		PostTags(param.PostTag).
+		Fields(
+			elasticapi.NewHighlighterField("author").NumOfFragments(0),
+			elasticapi.NewHighlighterField("body").FragmentSize(param.FragmentSize).NumOfFragments(maxBodyFragments),
+		)
+}
+
+// constructStats limits stats to news created in [From, To)
+func constructStats(payload m.StatsPayload) elasticapi.Query {
+	if payload.From.IsZero() && payload.To.IsZero() {
+		return elasticapi.NewMatchAllQuery()
+	}
+	q := elasticapi.NewRangeQuery("created")
+	if !payload.From.IsZero() {
+		q = q.Gte(payload.From)
+	}
+	if !payload.To.IsZero() {
+		q = q.Lt(payload.To)
+	}
+	return q
+}
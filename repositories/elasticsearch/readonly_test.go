@@ -0,0 +1,93 @@
+package elastic_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	m "github.com/rinosukmandityo/maknews/models"
+	es "github.com/rinosukmandityo/maknews/repositories/elasticsearch"
+	mem "github.com/rinosukmandityo/maknews/repositories/memory"
+	"github.com/rinosukmandityo/maknews/services/logic"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v -run ReadOnly
+*/
+
+// emptyCluster answers like elasticsearch without any index and records every request that would change it
+type emptyCluster struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (c *emptyCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/":
+		fmt.Fprint(w, `{"name":"fake","cluster_name":"fake","version":{"number":"7.10.0"},"tagline":"You Know, for Search"}`)
+		return
+	case r.URL.Path == "/_nodes/http":
+		fmt.Fprintf(w, `{"nodes":{"fake":{"name":"fake","http":{"publish_address":"%s"}}}}`, r.Host)
+		return
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "_v*"):
+		fmt.Fprint(w, `{}`)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && !strings.HasSuffix(r.URL.Path, "/_search") {
+		c.mu.Lock()
+		c.writes = append(c.writes, r.Method+" "+r.URL.Path)
+		c.mu.Unlock()
+	}
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, `{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`)
+}
+
+func (c *emptyCluster) written() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.writes...)
+}
+
+func TestReadOnlyNewsRepository(t *testing.T) {
+	cluster := &emptyCluster{}
+	server := httptest.NewServer(cluster)
+	defer server.Close()
+
+	elasticRepo, e := es.NewReadOnlyNewsRepository(server.URL, "news", 10)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to connect to empty cluster %s", e.Error())
+	}
+	defer elasticRepo.Close()
+
+	newsRepo := mem.NewNewsRepository()
+	data := m.News{ID: 1, Author: "Alex", Body: "Hello this is news from Alex"}
+	if e := newsRepo.Store(context.Background(), &data); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+	maintenanceService := logic.NewMaintenanceService(newsRepo, mem.NewOutboxRepository(newsRepo), mem.NewCacheRepository(10), elasticRepo)
+	if res, e := maintenanceService.Reindex(context.Background(), 10, false, true); e != nil || res.Indexed != 1 {
+		t.Errorf("[ERROR] - Expected dry run to count 1 news but got %+v %v", res, e)
+	}
+	if n, e := maintenanceService.WarmCache(context.Background(), 10, true); e != nil || n != 1 {
+		t.Errorf("[ERROR] - Expected dry run to count 1 news but got %d %v", n, e)
+	}
+	if writes := cluster.written(); len(writes) != 0 {
+		t.Errorf("[ERROR] - Dry run should not change the cluster but sent %v", writes)
+	}
+
+	// the repository of serve and reindex creates the index on the same cluster
+	if writable, e := es.NewNewsRepository(server.URL, "news", 10); e == nil {
+		writable.Close()
+	}
+	if writes := cluster.written(); len(writes) == 0 {
+		t.Errorf("[ERROR] - NewNewsRepository should create the index of an empty cluster")
+	}
+}
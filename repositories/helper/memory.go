@@ -0,0 +1,57 @@
+package repohelper
+
+import (
+	"sync"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	mem "github.com/rinosukmandityo/maknews/repositories/memory"
+)
+
+// memoryRepositories holds the in-memory adapters shared by the whole process,
+// so every caller of ChooseRepo, OutboxRepo, ElasticRepo, KafkaConnection, DeadLetterConnection, RedisRepo
+// and ProcessedEventRepo sees the same data just like they would with real servers.
+type memoryRepositories struct {
+	news       repo.NewsRepository
+	outbox     repo.OutboxRepository
+	elastic    repo.ElasticRepository
+	kafka      repo.KafkaRepository
+	deadLetter repo.KafkaRepository
+	cacheOnce  sync.Once
+	cacheRepo  repo.CacheRepository
+
+	processedOnce sync.Once
+	processedRepo repo.ProcessedEventRepository
+}
+
+var (
+	memOnce sync.Once
+	memRepo *memoryRepositories
+)
+
+func memoryRepo() *memoryRepositories {
+	memOnce.Do(func() {
+		news := mem.NewNewsRepository()
+		memRepo = &memoryRepositories{
+			news:       news,
+			outbox:     mem.NewOutboxRepository(news),
+			elastic:    mem.NewElasticRepository(),
+			kafka:      mem.NewKafkaConnection(),
+			deadLetter: mem.NewKafkaConnection(),
+		}
+	})
+	return memRepo
+}
+
+func (r *memoryRepositories) cache(expiration int) repo.CacheRepository {
+	r.cacheOnce.Do(func() {
+		r.cacheRepo = mem.NewCacheRepository(expiration)
+	})
+	return r.cacheRepo
+}
+
+func (r *memoryRepositories) processedEvent(ttl int) repo.ProcessedEventRepository {
+	r.processedOnce.Do(func() {
+		r.processedRepo = mem.NewProcessedEventRepository(ttl)
+	})
+	return r.processedRepo
+}
@@ -0,0 +1,41 @@
+package repohelper
+
+import (
+	"fmt"
+
+	"github.com/rinosukmandityo/maknews/config"
+	"github.com/rinosukmandityo/maknews/repositories/migrations"
+	mr "github.com/rinosukmandityo/maknews/repositories/mysql"
+	pg "github.com/rinosukmandityo/maknews/repositories/postgres"
+	sl "github.com/rinosukmandityo/maknews/repositories/sqlite"
+)
+
+// ChooseMigrator returns schema migrator for the SQL persistence database chosen by driver
+func ChooseMigrator(c *config.Config) (*migrations.Migrator, error) {
+	db := c.Database
+	switch db.Driver {
+	case "postgres":
+		return pg.NewMigrator(db.URL, db.Name, seconds(db.Timeout))
+	case "sqlite":
+		return sl.NewMigrator(db.URL, seconds(db.Timeout))
+	case "mongo", "memory":
+		return nil, fmt.Errorf("driver %s does not use schema migration", db.Driver)
+	default:
+		return mr.NewMigrator(db.URL, db.Name, seconds(db.Timeout))
+	}
+}
+
+// ChooseReadOnlyMigrator is ChooseMigrator for reading the migration status, it never creates the database
+func ChooseReadOnlyMigrator(c *config.Config) (*migrations.Migrator, error) {
+	db := c.Database
+	switch db.Driver {
+	case "postgres":
+		return pg.NewReadOnlyMigrator(db.URL, seconds(db.Timeout))
+	case "sqlite":
+		return sl.NewReadOnlyMigrator(db.URL, seconds(db.Timeout))
+	case "mongo", "memory":
+		return nil, fmt.Errorf("driver %s does not use schema migration", db.Driver)
+	default:
+		return mr.NewReadOnlyMigrator(db.URL, seconds(db.Timeout))
+	}
+}
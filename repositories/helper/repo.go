@@ -1,110 +1,116 @@
 package repohelper
 
 import (
-	"log"
-	"os"
-	"strconv"
+	"time"
 
+	"github.com/rinosukmandityo/maknews/config"
 	repo "github.com/rinosukmandityo/maknews/repositories"
 	es "github.com/rinosukmandityo/maknews/repositories/elasticsearch"
 	kf "github.com/rinosukmandityo/maknews/repositories/kafka"
 	mg "github.com/rinosukmandityo/maknews/repositories/mongodb"
 	mr "github.com/rinosukmandityo/maknews/repositories/mysql"
+	pg "github.com/rinosukmandityo/maknews/repositories/postgres"
 	rr "github.com/rinosukmandityo/maknews/repositories/redis"
+	sl "github.com/rinosukmandityo/maknews/repositories/sqlite"
 )
 
-func ChooseRepo() repo.NewsRepository {
-	url := os.Getenv("url")
-	db := os.Getenv("db")
-	timeout, _ := strconv.Atoi(os.Getenv("timeout"))
-	switch os.Getenv("driver") {
-	case "mongo":
-		if url == "" {
-			url = "mongodb://localhost:27017/local"
-		}
-		if db == "" {
-			db = "local"
-		}
-		if timeout == 0 {
-			timeout = 30
-		}
-		repo, e := mg.NewNewsRepository(url, db, timeout)
-		if e != nil {
-			log.Fatal(e)
-		}
-		return repo
-	default:
-		if url == "" {
-			url = "root:root@tcp(127.0.0.1:3306)/news"
-		}
-		if db == "" {
-			db = "news"
-		}
-		if timeout == 0 {
-			timeout = 10
-		}
+// seconds converts durations of the config to the whole seconds taken by the adapters
+func seconds(d time.Duration) int {
+	return int(d / time.Second)
+}
 
-		repo, e := mr.NewNewsRepository(url, db, timeout)
-		if e != nil {
-			log.Fatal(e)
-		}
-		return repo
+// poolConfig is the MySQL connection pool of database config
+func poolConfig(c config.Database) mr.PoolConfig {
+	return mr.PoolConfig{
+		MaxOpenConns:    c.MaxOpenConns,
+		MaxIdleConns:    c.MaxIdleConns,
+		ConnMaxLifetime: c.ConnMaxLifetime,
 	}
-	return nil
 }
 
-func ElasticRepo() repo.ElasticRepository {
-	timeout, _ := strconv.Atoi(os.Getenv("elastic_timeout"))
-	if timeout == 0 {
-		timeout = 10
+func ChooseRepo(c *config.Config) (repo.NewsRepository, error) {
+	db := c.Database
+	switch db.Driver {
+	case "mongo":
+		return mg.NewNewsRepository(db.URL, db.Name, seconds(db.Timeout))
+	case "postgres":
+		return pg.NewNewsRepository(db.URL, db.Name, seconds(db.Timeout))
+	case "sqlite":
+		return sl.NewNewsRepository(db.URL, seconds(db.Timeout))
+	case "memory":
+		return memoryRepo().news, nil
+	default:
+		return mr.NewNewsRepository(db.URL, db.Name, seconds(db.Timeout), poolConfig(db))
 	}
-	url := os.Getenv("elastic_url")
-	if url == "" {
-		url = "http://localhost:9200"
+}
+
+// OutboxRepo connects to the same persistence database as ChooseRepo
+func OutboxRepo(c *config.Config) (repo.OutboxRepository, error) {
+	db := c.Database
+	switch db.Driver {
+	case "mongo":
+		return mg.NewOutboxRepository(db.URL, db.Name, seconds(db.Timeout))
+	case "postgres":
+		return pg.NewOutboxRepository(db.URL, db.Name, seconds(db.Timeout))
+	case "sqlite":
+		return sl.NewOutboxRepository(db.URL, seconds(db.Timeout))
+	case "memory":
+		return memoryRepo().outbox, nil
+	default:
+		return mr.NewOutboxRepository(db.URL, db.Name, seconds(db.Timeout), poolConfig(db))
 	}
-	index := os.Getenv("elastic_index")
-	if index == "" {
-		index = "news"
+}
+
+// ElasticRepo returns nil when elastic is not enabled, news will be listed from persistence database instead
+func ElasticRepo(c *config.Config) (repo.ElasticRepository, error) {
+	if !c.Elastic.Enabled {
+		return nil, nil
 	}
-	repo, e := es.NewNewsRepository(url, index, timeout)
-	if e != nil {
-		log.Fatal(e)
+	if c.Database.Driver == "memory" {
+		return memoryRepo().elastic, nil
 	}
-	return repo
+	return es.NewNewsRepository(c.Elastic.URL, c.Elastic.Index, seconds(c.Elastic.Timeout))
 }
 
-func KafkaConnection() repo.KafkaRepository {
-	timeout, _ := strconv.Atoi(os.Getenv("kafka_timeout"))
-	if timeout == 0 {
-		timeout = 10
-	}
-	url := os.Getenv("kafka_url")
-	if url == "" {
-		url = "localhost:9092"
+// ReadOnlyElasticRepo is ElasticRepo for dry runs, it never creates the elasticsearch index
+func ReadOnlyElasticRepo(c *config.Config) (repo.ElasticRepository, error) {
+	if !c.Elastic.Enabled {
+		return nil, nil
 	}
-	topic := os.Getenv("kafka_topic")
-	if topic == "" {
-		topic = "news"
+	if c.Database.Driver == "memory" {
+		return memoryRepo().elastic, nil
 	}
-	repo, e := kf.NewKafkaConnection(url, topic, timeout)
-	if e != nil {
-		log.Fatal(e)
+	return es.NewReadOnlyNewsRepository(c.Elastic.URL, c.Elastic.Index, seconds(c.Elastic.Timeout))
+}
+
+func KafkaConnection(c *config.Config) (repo.KafkaRepository, error) {
+	if c.Database.Driver == "memory" {
+		return memoryRepo().kafka, nil
 	}
-	return repo
+	return kf.NewKafkaConnection(c.Kafka.URL, c.Kafka.Topic, c.Kafka.GroupID, seconds(c.Kafka.Timeout))
 }
 
-func RedisRepo() repo.CacheRepository {
-	timeout, _ := strconv.Atoi(os.Getenv("redis_expired"))
-	if timeout == 0 {
-		timeout = 10
+// DeadLetterConnection connects to the topic receiving messages the consumer failed to process,
+// it is read by its own consumer group when the messages are re-driven
+func DeadLetterConnection(c *config.Config) (repo.KafkaRepository, error) {
+	if c.Database.Driver == "memory" {
+		return memoryRepo().deadLetter, nil
 	}
-	url := os.Getenv("redis_url")
-	if url == "" {
-		url = "redis://:@localhost:6379/0"
+	return kf.NewKafkaConnection(c.Kafka.URL, c.Kafka.DLQTopic, c.Kafka.GroupID+"-redrive", seconds(c.Kafka.Timeout))
+}
+
+func RedisRepo(c *config.Config) (repo.CacheRepository, error) {
+	if c.Database.Driver == "memory" {
+		return memoryRepo().cache(seconds(c.Redis.Expired)), nil
 	}
-	repo, e := rr.NewNewsRepository(url, timeout)
-	if e != nil {
-		log.Fatal(e)
+	return rr.NewNewsRepository(c.Redis.URL, seconds(c.Redis.Expired))
+}
+
+// ProcessedEventRepo keeps the idempotency key of handled events in redis
+func ProcessedEventRepo(c *config.Config) (repo.ProcessedEventRepository, error) {
+	ttl := seconds(c.Consumer.ProcessedEventTTL)
+	if c.Database.Driver == "memory" {
+		return memoryRepo().processedEvent(ttl), nil
 	}
-	return repo
+	return rr.NewProcessedEventRepository(c.Redis.URL, ttl)
 }
@@ -3,9 +3,11 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/rinosukmandityo/maknews/helper"
 	m "github.com/rinosukmandityo/maknews/models"
 	repo "github.com/rinosukmandityo/maknews/repositories"
 
@@ -14,72 +16,180 @@ import (
 )
 
 type kafkaRepository struct {
-	conn    *kafka.Conn
-	url     string
+	writer  *kafka.Writer
+	brokers []string
 	topic   string
+	groupID string
 	timeout time.Duration
 }
 
-func newKafkaConnection(URL, topic string, timeout int) (*kafka.Conn, error) {
-	kafkaConn, e := kafka.DialLeader(context.Background(), "tcp", URL, topic, 0)
+func newKafkaConnection(brokers []string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, e := kafka.DialContext(ctx, "tcp", brokers[0])
 	if e != nil {
-		return nil, errors.Wrap(e, "repository.newKafkaConnection")
+		return errors.Wrap(e, "repository.newKafkaConnection")
 	}
-	return kafkaConn, e
+	return conn.Close()
 }
 
-func NewKafkaConnection(URL, topic string, timeout int) (repo.KafkaRepository, error) {
+// NewKafkaConnection connects to comma separated broker list in URL, news are read by consumer group groupID
+func NewKafkaConnection(URL, topic, groupID string, timeout int) (repo.KafkaRepository, error) {
 	repo := &kafkaRepository{
+		brokers: strings.Split(URL, ","),
 		topic:   topic,
-		url:     URL,
+		groupID: groupID,
 		timeout: time.Duration(timeout) * time.Second,
 	}
-
-	conn, e := newKafkaConnection(URL, topic, timeout)
-	if e != nil {
+	if e := newKafkaConnection(repo.brokers, repo.timeout); e != nil {
 		return nil, errors.Wrap(e, "repository.NewKafkaConnection")
 	}
-	repo.conn = conn
+	repo.writer = kafka.NewWriter(kafka.WriterConfig{
+		Brokers: repo.brokers,
+		Topic:   topic,
+		// events of the same news always go to the same partition to keep their order
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 10 * time.Millisecond,
+		WriteTimeout: repo.timeout,
+	})
 
 	return repo, nil
 }
 
-func (k kafkaRepository) WriteMessage(data *m.News) error {
-	msgs, e := json.Marshal(data)
+// Close flushes the pending writes, every reader is closed by the read that opened it
+func (k kafkaRepository) Close() error {
+	return k.writer.Close()
+}
+
+func newMessage(event *m.Event) (kafka.Message, error) {
+	value, e := json.Marshal(event)
 	if e != nil {
-		return e
+		return kafka.Message{}, e
 	}
+	return kafka.Message{
+		Key:   []byte(strconv.Itoa(event.Payload.ID)),
+		Value: value,
+	}, nil
+}
 
-	k.conn.SetWriteDeadline(time.Now().Add(k.timeout))
+// WriteEvents sends every event in one batch
+func (k kafkaRepository) WriteEvents(ctx context.Context, events []m.Event) error {
+	msgs := make([]kafka.Message, 0, len(events))
+	for i := range events {
+		msg, e := newMessage(&events[i])
+		if e != nil {
+			return e
+		}
+		msgs = append(msgs, msg)
+	}
 
-	if _, e = k.conn.WriteMessages(
-		kafka.Message{Value: msgs},
-	); e != nil {
-		return e
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	if e := k.writer.WriteMessages(ctx, msgs...); e != nil {
+		return errors.Wrap(e, "repository.Kafka.WriteEvents")
 	}
 	return nil
 }
 
-func (k kafkaRepository) ReadMessage(res chan<- []byte) {
+// WriteRawMessages sends msgs in one batch to this topic, the original topic, partition and offset are ignored
+func (k kafkaRepository) WriteRawMessages(ctx context.Context, msgs []m.Message) error {
+	kafkaMsgs := make([]kafka.Message, 0, len(msgs))
+	for _, v := range msgs {
+		kafkaMsgs = append(kafkaMsgs, kafka.Message{
+			Key:   v.Key,
+			Value: v.Value,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	if e := k.writer.WriteMessages(ctx, kafkaMsgs...); e != nil {
+		return errors.Wrap(e, "repository.Kafka.WriteRawMessages")
+	}
+	return nil
+}
+
+// batchWait is how long ReadBatch waits for one more message before handling a batch that is not full
+const batchWait = 10 * time.Millisecond
+
+// ReadMessage reads every partition assigned to this member of the consumer group,
+// a partition without committed offset is read from the first offset
+func (k kafkaRepository) ReadMessage(ctx context.Context, handle repo.MessageHandler) error {
+	return k.ReadBatch(ctx, 1, func(ctx context.Context, msgs []m.Message) error {
+		return handle(ctx, msgs[0])
+	})
+}
+
+// fetch blocks until the first message of the batch arrives, then it takes messages
+// that arrive within batchWait of each other until the batch is full
+func fetch(ctx context.Context, r *kafka.Reader, size int) ([]kafka.Message, error) {
+	msg, e := r.FetchMessage(ctx)
+	if e != nil {
+		return nil, e
+	}
+	msgs := []kafka.Message{msg}
+	for len(msgs) < size {
+		waitCtx, cancel := context.WithTimeout(ctx, batchWait)
+		msg, e := r.FetchMessage(waitCtx)
+		cancel()
+		if e != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if waitCtx.Err() != nil {
+				break
+			}
+			return nil, e
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// commit commits the highest offset of every partition in msgs, it waits at most timeout after ctx is cancelled
+func commit(ctx context.Context, r *kafka.Reader, msgs []kafka.Message, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(helper.Detach(ctx), timeout)
+	defer cancel()
+	return r.CommitMessages(ctx, msgs...)
+}
+
+func (k kafkaRepository) ReadBatch(ctx context.Context, size int, handle repo.BatchHandler) error {
+	if size <= 0 {
+		size = 1
+	}
 	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:   []string{k.url},
-		Topic:     k.topic,
-		Partition: 0,
-		MinBytes:  10,
-		MaxBytes:  10e3,
+		Brokers:  k.brokers,
+		GroupID:  k.groupID,
+		Topic:    k.topic,
+		MinBytes: 10,
+		MaxBytes: 10e6,
 	})
-	ctx := context.Background()
-	lastOffset, _ := k.conn.ReadLastOffset() // get latest offset
-	r.SetOffset(lastOffset)                  // set latest offset
+	defer r.Close()
 
 	for {
-		m, e := r.ReadMessage(ctx)
+		msgs, e := fetch(ctx, r, size)
 		if e != nil {
-			log.Println("kafka-repo ReadMessage", e.Error())
-			break
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(e, "repository.Kafka.ReadMessage")
+		}
+		data := make([]m.Message, len(msgs))
+		for i, msg := range msgs {
+			data[i] = m.Message{
+				Topic:     msg.Topic,
+				Partition: msg.Partition,
+				Offset:    msg.Offset,
+				Key:       msg.Key,
+				Value:     msg.Value,
+			}
+		}
+		// a batch that is fetched is finished and committed even when ctx is cancelled meanwhile
+		if e := handle(helper.Detach(ctx), data); e != nil {
+			return errors.Wrapf(e, "repository.Kafka.ReadMessage partition %d offset %d", msgs[0].Partition, msgs[0].Offset)
+		}
+		if e := commit(ctx, r, msgs, k.timeout); e != nil {
+			return errors.Wrap(e, "repository.Kafka.ReadMessage")
 		}
-		// fmt.Printf("message at offset %d: %s = %s at %v\n", m.Offset, string(m.Key), string(m.Value), m.Time)
-		res <- m.Value
 	}
-
 }
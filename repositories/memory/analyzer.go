@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// span is the byte range of one term in text
+type span struct {
+	start, end int
+}
+
+func isTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// analyze splits text into lower cased terms like the standard analyzer of elasticsearch
+func analyze(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isTermRune(r)
+	})
+}
+
+// spans returns the terms of text in order, they are the terms of analyze before lower casing
+func spans(text string) []span {
+	res := []span{}
+	start := -1
+	for i, r := range text {
+		switch {
+		case isTermRune(r) && start < 0:
+			start = i
+		case !isTermRune(r) && start >= 0:
+			res = append(res, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		res = append(res, span{start, len(text)})
+	}
+	return res
+}
+
+// score counts how many times the terms of query occur in text, zero means text does not match
+func score(query []string, text string) float64 {
+	terms := map[string]int{}
+	for _, v := range analyze(text) {
+		terms[v]++
+	}
+	res := 0
+	for _, v := range query {
+		res += terms[v]
+	}
+	return float64(res)
+}
+
+// highlight wraps the terms of query found in text with preTag and postTag and HTML-escapes the rest like the html encoder.
+// A positive fragmentSize splits text before the term that would make a fragment longer than fragmentSize characters,
+// then at most maxFragments fragments with a match are returned in text order. Otherwise text is one fragment.
+func highlight(query []string, text string, fragmentSize, maxFragments int, preTag, postTag string) []string {
+	terms := map[string]bool{}
+	for _, v := range query {
+		terms[v] = true
+	}
+
+	res := []string{}
+	var b strings.Builder
+	start, last, matched := 0, 0, false
+	flush := func(end int) {
+		if matched && (maxFragments <= 0 || len(res) < maxFragments) {
+			b.WriteString(html.EscapeString(text[last:end]))
+			res = append(res, strings.TrimSpace(b.String()))
+		}
+		b.Reset()
+		start, last, matched = end, end, false
+	}
+	for _, v := range spans(text) {
+		if fragmentSize > 0 && v.start > start && utf8.RuneCountInString(text[start:v.end]) > fragmentSize {
+			flush(v.start)
+		}
+		b.WriteString(html.EscapeString(text[last:v.start]))
+		term := html.EscapeString(text[v.start:v.end])
+		if terms[strings.ToLower(text[v.start:v.end])] {
+			term = preTag + term + postTag
+			matched = true
+		}
+		b.WriteString(term)
+		last = v.end
+	}
+	flush(len(text))
+	return res
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+
+	"github.com/pkg/errors"
+)
+
+type cacheItem struct {
+	data     m.News
+	expireAt time.Time
+}
+
+func (c cacheItem) expired(now time.Time) bool {
+	return !c.expireAt.IsZero() && !now.Before(c.expireAt)
+}
+
+type newsMemoryCacheRepository struct {
+	mu         sync.RWMutex
+	items      map[int]cacheItem
+	members    map[int]time.Time // sorted set of news ID scored by creation time
+	expiration time.Duration
+}
+
+func NewCacheRepository(expiration int) repo.CacheRepository {
+	return &newsMemoryCacheRepository{
+		items:      map[int]cacheItem{},
+		members:    map[int]time.Time{},
+		expiration: time.Duration(expiration) * time.Second,
+	}
+}
+
+func (r *newsMemoryCacheRepository) Close() error {
+	return nil
+}
+
+func (r *newsMemoryCacheRepository) newItem(data m.News) cacheItem {
+	item := cacheItem{data: data}
+	if r.expiration > 0 {
+		item.expireAt = time.Now().Add(r.expiration)
+	}
+	return item
+}
+
+func (r *newsMemoryCacheRepository) GetBy(ctx context.Context, param m.GetPayload) ([]m.News, error) {
+	res := []m.News{}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]int, 0, len(r.members))
+	for id := range r.members {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if r.members[ids[i]].Equal(r.members[ids[j]]) {
+			return ids[i] > ids[j]
+		}
+		return r.members[ids[i]].After(r.members[ids[j]])
+	})
+	start, end := paginate(len(ids), param.Offset, param.Limit)
+
+	now := time.Now()
+	for _, id := range ids[start:end] {
+		item, ok := r.items[id]
+		if !ok || item.expired(now) {
+			return res, errors.Wrap(helper.ErrDataNotFound, "repository.News.GetBy")
+		}
+		res = append(res, item.data)
+	}
+	return res, nil
+}
+
+func (r *newsMemoryCacheRepository) Store(ctx context.Context, data []m.News) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range data {
+		r.items[v.ID] = r.newItem(v)
+		r.members[v.ID] = v.Created
+	}
+	return nil
+}
+
+func (r *newsMemoryCacheRepository) Update(ctx context.Context, data m.News) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.items[data.ID] = r.newItem(data)
+	return nil
+}
+
+func (r *newsMemoryCacheRepository) Delete(ctx context.Context, data m.News) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.items, data.ID)
+	delete(r.members, data.ID)
+	return nil
+}
@@ -0,0 +1,300 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+
+	"github.com/pkg/errors"
+)
+
+// maxBodyFragments is the number of highlighted body fragments returned per hit like the elasticsearch repository
+const maxBodyFragments = 3
+
+// memoryAlias names the physical indices like the elasticsearch repository does with its default index
+const memoryAlias = "news"
+
+// newsMemoryElasticRepository keeps every physical index, data is the one the alias points to
+type newsMemoryElasticRepository struct {
+	mu         sync.RWMutex
+	data       map[int]m.ElasticNews
+	current    string
+	indices    map[string]map[int]m.ElasticNews
+	generation int
+}
+
+func NewElasticRepository() repo.ElasticRepository {
+	r := &newsMemoryElasticRepository{
+		indices: map[string]map[int]m.ElasticNews{},
+	}
+	r.current = r.nextIndex()
+	r.data = r.indices[r.current]
+	return r
+}
+
+func (r *newsMemoryElasticRepository) nextIndex() string {
+	r.generation++
+	name := fmt.Sprintf("%s_v%d", memoryAlias, r.generation)
+	r.indices[name] = map[int]m.ElasticNews{}
+	return name
+}
+
+func (r *newsMemoryElasticRepository) Close() error {
+	return nil
+}
+
+func sortElasticNews(data []m.ElasticNews, order map[string]bool) {
+	ascending := true
+	if v, ok := order["created"]; ok {
+		ascending = v
+	}
+	sort.SliceStable(data, func(i, j int) bool {
+		if data[i].Created.Equal(data[j].Created) {
+			return data[i].ID < data[j].ID
+		}
+		if ascending {
+			return data[i].Created.Before(data[j].Created)
+		}
+		return data[i].Created.After(data[j].Created)
+	})
+}
+
+func (r *newsMemoryElasticRepository) GetBy(ctx context.Context, param m.GetPayload) ([]m.ElasticNews, error) {
+	res := []m.ElasticNews{}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, v := range r.data {
+		ok, e := matchNews(m.News{ID: v.ID, Author: v.Author, Body: v.Body, Created: v.Created}, param.Filter)
+		if e != nil {
+			return res, errors.Wrap(e, "repository.News.GetBy")
+		}
+		if ok {
+			res = append(res, v)
+		}
+	}
+	if len(res) == 0 {
+		return res, errors.Wrap(helper.ErrDataNotFound, "repository.News.GetBy")
+	}
+	sortElasticNews(res, param.Order)
+
+	// Elasticsearch returns 10 hits when size is not set
+	limit := param.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	start, end := paginate(len(res), param.Offset, limit)
+
+	return res[start:end], nil
+}
+
+func (r *newsMemoryElasticRepository) Store(ctx context.Context, data m.ElasticNews) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.data[data.ID] = data
+
+	return nil
+}
+
+func (r *newsMemoryElasticRepository) StoreManyIn(ctx context.Context, index string, data []m.ElasticNews) ([]error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dst, ok := r.indices[index]
+	if !ok {
+		return nil, errors.Wrapf(helper.ErrDataNotFound, "repository.News.StoreManyIn index %s", index)
+	}
+	for _, v := range data {
+		dst[v.ID] = v
+	}
+	return make([]error, len(data)), nil
+}
+
+func (r *newsMemoryElasticRepository) StoreMany(ctx context.Context, data []m.ElasticNews) ([]error, error) {
+	res := make([]error, len(data))
+	for i := range data {
+		res[i] = r.Store(ctx, data[i])
+	}
+	return res, nil
+}
+
+func (r *newsMemoryElasticRepository) Update(ctx context.Context, data m.ElasticNews, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.data[id]; !ok {
+		return errors.Wrap(helper.ErrDataNotFound, "repository.News.Update")
+	}
+	data.ID = id
+	r.data[id] = data
+
+	return nil
+}
+
+func (r *newsMemoryElasticRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.data[id]; !ok {
+		return errors.Wrap(helper.ErrDataNotFound, "repository.News.Delete")
+	}
+	delete(r.data, id)
+
+	return nil
+}
+
+// Search scores author and body separately and keeps the best one like best_fields multi-match,
+// equal scores are ordered by the newest news first
+func (r *newsMemoryElasticRepository) Search(ctx context.Context, param m.SearchPayload) (*m.ElasticSearchResult, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, errors.Wrap(e, "repository.News.Search")
+	}
+	query := analyze(param.Query)
+	hits := []m.ElasticHit{}
+	r.mu.RLock()
+	for _, v := range r.data {
+		best := score(query, v.Author)
+		if body := score(query, v.Body); body > best {
+			best = body
+		}
+		if best > 0 {
+			hits = append(hits, m.ElasticHit{News: v, Score: best})
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if !hits[i].News.Created.Equal(hits[j].News.Created) {
+			return hits[i].News.Created.After(hits[j].News.Created)
+		}
+		return hits[i].News.ID < hits[j].News.ID
+	})
+	limit := param.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	start, end := paginate(len(hits), param.Offset, limit)
+	h := param.Highlight
+	for i := start; i < end; i++ {
+		news := hits[i].News
+		highlights := map[string][]string{}
+		if res := highlight(query, news.Author, 0, 0, h.PreTag, h.PostTag); len(res) > 0 {
+			highlights["author"] = res
+		}
+		if res := highlight(query, news.Body, h.FragmentSize, maxBodyFragments, h.PreTag, h.PostTag); len(res) > 0 {
+			highlights["body"] = res
+		}
+		hits[i].Highlights = highlights
+	}
+
+	return &m.ElasticSearchResult{
+		Total: int64(len(hits)),
+		Hits:  hits[start:end],
+	}, nil
+}
+
+func (r *newsMemoryElasticRepository) CreateIndex(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.nextIndex(), nil
+}
+
+func (r *newsMemoryElasticRepository) SwapIndex(ctx context.Context, index string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, ok := r.indices[index]
+	if !ok {
+		return nil, errors.Wrapf(helper.ErrDataNotFound, "repository.News.SwapIndex index %s", index)
+	}
+	previous := []string{}
+	if r.current != index {
+		previous = append(previous, r.current)
+	}
+	r.current, r.data = index, data
+	return previous, nil
+}
+
+func (r *newsMemoryElasticRepository) DeleteIndex(ctx context.Context, index string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.indices[index]; !ok {
+		return errors.Wrapf(helper.ErrDataNotFound, "repository.News.DeleteIndex index %s", index)
+	}
+	if index == r.current {
+		return errors.Wrapf(helper.ErrIndexInUse, "repository.News.DeleteIndex index %s", index)
+	}
+	delete(r.indices, index)
+	return nil
+}
+
+// Stats counts authors by their exact name like the keyword sub-field, equal counts are ordered by author.
+// Like elasticsearch it fails with helper.ErrDataInvalid when the buckets are more than m.MaxStatsBuckets.
+func (r *newsMemoryElasticRepository) Stats(ctx context.Context, param m.StatsPayload) (*m.NewsStats, error) {
+	loc, e := time.LoadLocation(param.TimeZone)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.News.Stats")
+	}
+	res := &m.NewsStats{
+		Authors:   []m.AuthorCount{},
+		Histogram: []m.DateCount{},
+	}
+	authors := map[string]int64{}
+	buckets := map[int64]int64{}
+	var first, last time.Time
+	r.mu.RLock()
+	for _, v := range r.data {
+		if !param.From.IsZero() && v.Created.Before(param.From) || !param.To.IsZero() && !v.Created.Before(param.To) {
+			continue
+		}
+		res.Total++
+		authors[v.Author]++
+		start := m.StatsBucketStart(v.Created, param.Interval, loc)
+		buckets[start.UnixNano()]++
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if last.IsZero() || start.After(last) {
+			last = start
+		}
+	}
+	r.mu.RUnlock()
+
+	for k, v := range authors {
+		res.Authors = append(res.Authors, m.AuthorCount{Author: k, Count: v})
+	}
+	sort.Slice(res.Authors, func(i, j int) bool {
+		if res.Authors[i].Count != res.Authors[j].Count {
+			return res.Authors[i].Count > res.Authors[j].Count
+		}
+		return res.Authors[i].Author < res.Authors[j].Author
+	})
+	if len(res.Authors) > param.Authors {
+		for _, v := range res.Authors[param.Authors:] {
+			res.OtherAuthors += v.Count
+		}
+		res.Authors = res.Authors[:param.Authors]
+	}
+
+	if res.Total > 0 {
+		for start := first; !start.After(last); start = m.NextStatsBucket(start, param.Interval) {
+			if len(res.Authors)+len(res.Histogram) >= m.MaxStatsBuckets {
+				return nil, errors.Wrapf(helper.ErrDataInvalid, "repository.News.Stats more than %d buckets", m.MaxStatsBuckets)
+			}
+			res.Histogram = append(res.Histogram, m.DateCount{Date: start, Count: buckets[start.UnixNano()]})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+)
+
+const defaultLimit = 10
+
+func matchNews(data m.News, filter map[string]interface{}) (bool, error) {
+	for k, v := range filter {
+		switch k {
+		case "id", "_id":
+			id, e := helper.ToInt(v)
+			if e != nil {
+				return false, helper.ErrDataInvalid
+			}
+			if data.ID != id {
+				return false, nil
+			}
+		case "author":
+			if s, ok := v.(string); !ok || data.Author != s {
+				return false, nil
+			}
+		case "body":
+			if s, ok := v.(string); !ok || data.Body != s {
+				return false, nil
+			}
+		case "created":
+			created, e := helper.ToTime(v)
+			if e != nil {
+				return false, helper.ErrDataInvalid
+			}
+			if !data.Created.Equal(created) {
+				return false, nil
+			}
+		default:
+			return false, helper.ErrDataInvalid
+		}
+	}
+	return true, nil
+}
+
+// applyUpdate ignores id and version like the other adapters, an update without any other field is ErrDataInvalid
+func applyUpdate(data *m.News, update map[string]interface{}) error {
+	changed := 0
+	for k, v := range update {
+		switch k {
+		case "id", "_id", "version":
+			// the identity comes from the URL and the version is kept by the repository, the body may repeat them
+			continue
+		case "author":
+			s, ok := v.(string)
+			if !ok {
+				return helper.ErrDataInvalid
+			}
+			data.Author = s
+		case "body":
+			s, ok := v.(string)
+			if !ok {
+				return helper.ErrDataInvalid
+			}
+			data.Body = s
+		case "created":
+			created, e := helper.ToTime(v)
+			if e != nil {
+				return helper.ErrDataInvalid
+			}
+			data.Created = created
+		default:
+			return helper.ErrDataInvalid
+		}
+		changed++
+	}
+	if changed == 0 {
+		return helper.ErrDataInvalid
+	}
+	return nil
+}
+
+func paginate(length, offset, limit int) (int, int) {
+	if offset > length {
+		offset = length
+	}
+	end := offset + limit
+	if limit <= 0 || end > length {
+		end = length
+	}
+	return offset, end
+}
+
+func compareNews(a, b m.News, column string) int {
+	switch column {
+	case "id":
+		return a.ID - b.ID
+	case "author":
+		return strings.Compare(a.Author, b.Author)
+	case "body":
+		return strings.Compare(a.Body, b.Body)
+	case "created":
+		if a.Created.Before(b.Created) {
+			return -1
+		}
+		if a.Created.After(b.Created) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// sortNews orders data by every column in order (true means ascending) sorted by column name, then by id
+func sortNews(data []m.News, order map[string]bool) error {
+	columns := []string{}
+	for k := range order {
+		switch k {
+		case "id", "author", "body", "created":
+			columns = append(columns, k)
+		default:
+			return helper.ErrDataInvalid
+		}
+	}
+	sort.Strings(columns)
+	sort.SliceStable(data, func(i, j int) bool {
+		for _, c := range columns {
+			cmp := compareNews(data[i], data[j], c)
+			if cmp == 0 {
+				continue
+			}
+			if order[c] {
+				return cmp < 0
+			}
+			return cmp > 0
+		}
+		return data[i].ID < data[j].ID
+	})
+	return nil
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+
+	"github.com/pkg/errors"
+)
+
+// kafkaMemoryRepository keeps one partition read by one consumer group
+type kafkaMemoryRepository struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	messages  []m.Message
+	committed int
+}
+
+func NewKafkaConnection() repo.KafkaRepository {
+	k := &kafkaMemoryRepository{}
+	k.cond = sync.NewCond(&k.mu)
+	return k
+}
+
+func (k *kafkaMemoryRepository) Close() error {
+	return nil
+}
+
+func (k *kafkaMemoryRepository) WriteEvents(ctx context.Context, events []m.Event) error {
+	msgs := make([]m.Message, 0, len(events))
+	for _, v := range events {
+		value, e := json.Marshal(v)
+		if e != nil {
+			return e
+		}
+		msgs = append(msgs, m.Message{Key: []byte(strconv.Itoa(v.Payload.ID)), Value: value})
+	}
+	return k.WriteRawMessages(ctx, msgs)
+}
+
+func (k *kafkaMemoryRepository) WriteRawMessages(ctx context.Context, msgs []m.Message) error {
+	k.mu.Lock()
+	for _, v := range msgs {
+		k.messages = append(k.messages, m.Message{
+			Offset: int64(len(k.messages)),
+			Key:    v.Key,
+			Value:  v.Value,
+		})
+	}
+	k.mu.Unlock()
+	k.cond.Broadcast()
+
+	return nil
+}
+
+// ReadMessage starts from the committed offset, it is the first offset for a new consumer group
+func (k *kafkaMemoryRepository) ReadMessage(ctx context.Context, handle repo.MessageHandler) error {
+	return k.ReadBatch(ctx, 1, func(ctx context.Context, msgs []m.Message) error {
+		return handle(ctx, msgs[0])
+	})
+}
+
+func (k *kafkaMemoryRepository) ReadBatch(ctx context.Context, size int, handle repo.BatchHandler) error {
+	if size <= 0 {
+		size = 1
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			k.mu.Lock()
+			k.cond.Broadcast()
+			k.mu.Unlock()
+		case <-done:
+		}
+	}()
+
+	k.mu.Lock()
+	offset := k.committed
+	for {
+		for offset >= len(k.messages) && ctx.Err() == nil {
+			k.cond.Wait()
+		}
+		if ctx.Err() != nil {
+			k.mu.Unlock()
+			return nil
+		}
+		end := offset + size
+		if end > len(k.messages) {
+			end = len(k.messages)
+		}
+		msgs := append([]m.Message{}, k.messages[offset:end]...)
+		k.mu.Unlock()
+		// a batch that is fetched is finished and committed even when ctx is cancelled meanwhile
+		if e := handle(helper.Detach(ctx), msgs); e != nil {
+			return errors.Wrapf(e, "repository.Kafka.ReadMessage offset %d", offset)
+		}
+		offset = end
+		k.mu.Lock()
+		k.committed = offset
+	}
+}
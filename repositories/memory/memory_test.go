@@ -0,0 +1,451 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	mem "github.com/rinosukmandityo/maknews/repositories/memory"
+	"github.com/rinosukmandityo/maknews/repositories/repotest"
+
+	"github.com/pkg/errors"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v
+*/
+
+var ctx = context.Background()
+
+type TestTable struct {
+	name        string
+	expectedErr error
+	errMsg      string
+	filter      map[string]interface{}
+	updatedData map[string]interface{}
+	data        m.News
+}
+
+func ListTestData() []m.News {
+	now := time.Now().UTC()
+	return []m.News{{
+		ID:      1,
+		Author:  "Alex",
+		Body:    "Hello this is news from Alex",
+		Created: now,
+	}, {
+		ID:      2,
+		Author:  "Bacca",
+		Body:    "Hello this is news from Bacca",
+		Created: now.Add(time.Second * 3),
+	}, {
+		ID:      3,
+		Author:  "Chicarito",
+		Body:    "Hello this is news from Chicarito",
+		Created: now.Add(time.Second * 5),
+	}}
+}
+
+func TestNewsRepositoryContract(t *testing.T) {
+	repotest.RunNewsRepositoryContract(t, func(t *testing.T) repo.NewsRepository {
+		return mem.NewNewsRepository()
+	})
+}
+
+func TestOutboxContract(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	repotest.RunOutboxContract(t, newsRepo, mem.NewOutboxRepository(newsRepo))
+}
+
+func TestNewsRepository(t *testing.T) {
+	repo := mem.NewNewsRepository()
+	for _, data := range ListTestData() {
+		_data := data
+		if e := repo.Store(ctx, &_data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+
+	tts := []TestTable{
+		{
+			name:        "Case: Positive Test",
+			expectedErr: nil,
+			errMsg:      "[ERROR] - Failed to update data",
+			data:        ListTestData()[0],
+			updatedData: map[string]interface{}{"id": float64(1), "author": "AlexUPDATED"},
+		},
+		{
+			name:        "Case: Negative Test",
+			expectedErr: helper.ErrDataNotFound,
+			errMsg:      "[ERROR] - It should be error 'Data Not Found'",
+			data:        m.News{ID: -9999},
+			updatedData: map[string]interface{}{"author": "Data Not Exists"},
+		},
+		{
+			name:        "Case: Unknown Field",
+			expectedErr: helper.ErrDataInvalid,
+			errMsg:      "[ERROR] - It should be error 'Data Invalid'",
+			data:        ListTestData()[0],
+			updatedData: map[string]interface{}{"title": "Unknown"},
+		},
+	}
+	for _, tt := range tts {
+		t.Run(tt.name, func(t *testing.T) {
+			res, e := repo.Update(ctx, tt.updatedData, tt.data.ID)
+			if errors.Cause(e) != tt.expectedErr {
+				t.Errorf("%s %v", tt.errMsg, e)
+			}
+			if e == nil && res.Author != tt.updatedData["author"] {
+				t.Errorf("%s got author %s", tt.errMsg, res.Author)
+			}
+		})
+	}
+
+	t.Run("Case: Duplicate ID", func(t *testing.T) {
+		_data := ListTestData()[1]
+		if e := repo.Store(ctx, &_data); errors.Cause(e) != helper.ErrDataExists {
+			t.Errorf("[ERROR] - It should be error 'Data Already Exists' %v", e)
+		}
+	})
+	t.Run("Case: Get By Author", func(t *testing.T) {
+		res, e := repo.GetBy(ctx, map[string]interface{}{"author": "Bacca"})
+		if e != nil || res.ID != 2 {
+			t.Errorf("[ERROR] - Failed to get data %v", e)
+		}
+	})
+	t.Run("Case: Get Oldest Match", func(t *testing.T) {
+		// more matches than one so a random pick would be caught sooner or later
+		for id := 10; id < 20; id++ {
+			data := m.News{ID: id, Author: "Dembele", Created: ListTestData()[0].Created.Add(time.Duration(-id) * time.Second)}
+			if e := repo.Store(ctx, &data); e != nil {
+				t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+			}
+		}
+		for i := 0; i < 10; i++ {
+			res, e := repo.GetBy(ctx, map[string]interface{}{"author": "Dembele"})
+			if e != nil || res.ID != 19 {
+				t.Fatalf("[ERROR] - It should return the oldest news 19 got %d %v", res.ID, e)
+			}
+		}
+	})
+	t.Run("Case: Delete Data", func(t *testing.T) {
+		if e := repo.Delete(ctx, 2); e != nil {
+			t.Errorf("[ERROR] - Failed to delete data %s", e.Error())
+		}
+		if e := repo.Delete(ctx, 2); errors.Cause(e) != helper.ErrDataNotFound {
+			t.Errorf("[ERROR] - It should be error 'Data Not Found' %v", e)
+		}
+		if _, e := repo.GetBy(ctx, map[string]interface{}{"id": 2}); errors.Cause(e) != helper.ErrDataNotFound {
+			t.Errorf("[ERROR] - It should be error 'Data Not Found' %v", e)
+		}
+	})
+}
+
+func TestElasticRepository(t *testing.T) {
+	repo := mem.NewElasticRepository()
+	if _, e := repo.GetBy(ctx, m.GetPayload{}); errors.Cause(e) != helper.ErrDataNotFound {
+		t.Errorf("[ERROR] - It should be error 'Data Not Found' %v", e)
+	}
+	for _, data := range ListTestData() {
+		if e := repo.Store(ctx, m.NewElasticNews(data)); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+
+	t.Run("Case: Order By Created Descending", func(t *testing.T) {
+		res, e := repo.GetBy(ctx, m.GetPayload{Offset: 1, Limit: 1, Order: map[string]bool{"created": false}})
+		if e != nil || len(res) != 1 || res[0].ID != 2 {
+			t.Errorf("[ERROR] - Incorrect order data %v %v", res, e)
+		}
+	})
+	t.Run("Case: Filter By ID", func(t *testing.T) {
+		res, e := repo.GetBy(ctx, m.GetPayload{Filter: map[string]interface{}{"id": 3}})
+		if e != nil || len(res) != 1 || res[0].ID != 3 {
+			t.Errorf("[ERROR] - Failed to get data %v %v", res, e)
+		}
+	})
+	t.Run("Case: Search Is Case Insensitive", func(t *testing.T) {
+		res, e := repo.Search(ctx, m.SearchPayload{Query: "BACCA"})
+		if e != nil || res.Total != 1 || res.Hits[0].News.ID != 2 || res.Hits[0].Score <= 0 {
+			t.Errorf("[ERROR] - Failed to search data %+v %v", res, e)
+		}
+	})
+	t.Run("Case: Search Without Match", func(t *testing.T) {
+		res, e := repo.Search(ctx, m.SearchPayload{Query: "weather"})
+		if e != nil || res.Total != 0 || len(res.Hits) != 0 {
+			t.Errorf("[ERROR] - Expected no hits %+v %v", res, e)
+		}
+	})
+	t.Run("Case: Negative Test", func(t *testing.T) {
+		if e := repo.Update(ctx, m.ElasticNews{}, -9999); errors.Cause(e) != helper.ErrDataNotFound {
+			t.Errorf("[ERROR] - It should be error 'Data Not Found' %v", e)
+		}
+		if e := repo.Delete(ctx, -9999); errors.Cause(e) != helper.ErrDataNotFound {
+			t.Errorf("[ERROR] - It should be error 'Data Not Found' %v", e)
+		}
+	})
+}
+
+func TestElasticSearchHighlight(t *testing.T) {
+	repo := mem.NewElasticRepository()
+	data := m.ElasticNews{
+		ID:     1,
+		Author: "Alex <Editor>",
+		Body:   "Alex & Bacca meet. Nothing else happens here at all. Then <b>alex</b> leaves",
+	}
+	if e := repo.Store(ctx, data); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+
+	res, e := repo.Search(ctx, m.SearchPayload{Query: "alex", Highlight: m.Highlight{FragmentSize: 20, PreTag: "[", PostTag: "]"}})
+	if e != nil || len(res.Hits) != 1 {
+		t.Fatalf("[ERROR] - Failed to search data %+v %v", res, e)
+	}
+	highlights := res.Hits[0].Highlights
+	if author := highlights["author"]; len(author) != 1 || author[0] != "[Alex] &lt;Editor&gt;" {
+		t.Errorf("[ERROR] - Expected escaped author fragment but got %q", author)
+	}
+	expected := []string{"[Alex] &amp; Bacca meet.", "[alex]&lt;/b&gt; leaves"}
+	body := highlights["body"]
+	if len(body) != len(expected) {
+		t.Fatalf("[ERROR] - Expected %q but got %q", expected, body)
+	}
+	for i, v := range expected {
+		if body[i] != v {
+			t.Errorf("[ERROR] - Expected fragment %q but got %q", v, body[i])
+		}
+	}
+}
+
+func TestElasticIndexSwap(t *testing.T) {
+	repo := mem.NewElasticRepository()
+	old := m.NewElasticNews(ListTestData()[0])
+	if e := repo.Store(ctx, old); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+
+	index, e := repo.CreateIndex(ctx)
+	if e != nil || index != "news_v2" {
+		t.Fatalf("[ERROR] - Expected index news_v2 but got %s %v", index, e)
+	}
+	if _, e := repo.StoreManyIn(ctx, index, []m.ElasticNews{m.NewElasticNews(ListTestData()[1])}); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+	if res, e := repo.GetBy(ctx, m.GetPayload{}); e != nil || len(res) != 1 || res[0].ID != old.ID {
+		t.Errorf("[ERROR] - New index should not be read before swap %v %v", res, e)
+	}
+
+	previous, e := repo.SwapIndex(ctx, index)
+	if e != nil || len(previous) != 1 || previous[0] != "news_v1" {
+		t.Fatalf("[ERROR] - Expected alias moved from news_v1 but got %v %v", previous, e)
+	}
+	if res, e := repo.GetBy(ctx, m.GetPayload{}); e != nil || len(res) != 1 || res[0].ID != 2 {
+		t.Errorf("[ERROR] - Alias should read news_v2 %v %v", res, e)
+	}
+	if e := repo.DeleteIndex(ctx, index); errors.Cause(e) != helper.ErrIndexInUse {
+		t.Errorf("[ERROR] - It should be error '%s' %v", helper.ErrIndexInUse.Error(), e)
+	}
+	if e := repo.DeleteIndex(ctx, "news_v1"); e != nil {
+		t.Errorf("[ERROR] - Failed to delete news_v1 %v", e)
+	}
+	if _, e := repo.SwapIndex(ctx, "news_v1"); errors.Cause(e) != helper.ErrDataNotFound {
+		t.Errorf("[ERROR] - It should be error 'Data Not Found' %v", e)
+	}
+}
+
+func TestElasticStats(t *testing.T) {
+	repo := mem.NewElasticRepository()
+	// Sunday 2020-05-31 and Monday 2020-06-01 fall into different weeks but the same quarter
+	for i, created := range []time.Time{
+		time.Date(2020, time.May, 31, 12, 0, 0, 0, time.UTC),
+		time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2020, time.July, 1, 12, 0, 0, 0, time.UTC),
+	} {
+		if e := repo.Store(ctx, m.ElasticNews{ID: i + 1, Author: "Alex", Created: created}); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+
+	tts := []struct {
+		interval string
+		starts   []time.Time
+	}{
+		{"week", []time.Time{
+			time.Date(2020, time.May, 25, 0, 0, 0, 0, time.UTC),
+			time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2020, time.June, 8, 0, 0, 0, 0, time.UTC),
+			time.Date(2020, time.June, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2020, time.June, 22, 0, 0, 0, 0, time.UTC),
+			time.Date(2020, time.June, 29, 0, 0, 0, 0, time.UTC),
+		}},
+		{"quarter", []time.Time{
+			time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2020, time.July, 1, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+	for _, tt := range tts {
+		t.Run("Case: "+tt.interval, func(t *testing.T) {
+			res, e := repo.Stats(ctx, m.StatsPayload{Interval: tt.interval, TimeZone: "UTC", Authors: 10})
+			if e != nil || len(res.Histogram) != len(tt.starts) {
+				t.Fatalf("[ERROR] - Expected %d buckets but got %+v %v", len(tt.starts), res, e)
+			}
+			for i, v := range tt.starts {
+				if !res.Histogram[i].Date.Equal(v) {
+					t.Errorf("[ERROR] - Expected bucket %d to start at %s but got %s", i, v, res.Histogram[i].Date)
+				}
+			}
+		})
+	}
+	t.Run("Case: Too Many Buckets", func(t *testing.T) {
+		// a month of minutes is more than m.MaxStatsBuckets
+		_, e := repo.Stats(ctx, m.StatsPayload{Interval: "minute", TimeZone: "UTC", Authors: 10})
+		if errors.Cause(e) != helper.ErrDataInvalid {
+			t.Errorf("[ERROR] - It should be error '%s' but got %v", helper.ErrDataInvalid.Error(), e)
+		}
+	})
+}
+
+func TestCacheRepository(t *testing.T) {
+	repo := mem.NewCacheRepository(10)
+	if e := repo.Store(ctx, ListTestData()); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+
+	t.Run("Case: Newest First", func(t *testing.T) {
+		res, e := repo.GetBy(ctx, m.GetPayload{Offset: 0, Limit: 2})
+		if e != nil || len(res) != 2 || res[0].ID != 3 || res[1].ID != 2 {
+			t.Errorf("[ERROR] - Incorrect order data %v %v", res, e)
+		}
+	})
+	t.Run("Case: Delete Data", func(t *testing.T) {
+		if e := repo.Delete(ctx, ListTestData()[2]); e != nil {
+			t.Errorf("[ERROR] - Failed to delete data %s", e.Error())
+		}
+		res, e := repo.GetBy(ctx, m.GetPayload{Offset: 0, Limit: 10})
+		if e != nil || len(res) != 2 {
+			t.Errorf("[ERROR] - Failed to get data %v %v", res, e)
+		}
+	})
+}
+
+// newsEvents returns created event of every data
+func newsEvents(t *testing.T, data []m.News) []m.Event {
+	res := make([]m.Event, len(data))
+	for i := range data {
+		event, e := m.NewEvent(m.NewsCreated, &data[i])
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to create event %s", e.Error())
+		}
+		res[i] = *event
+	}
+	return res
+}
+
+func TestKafkaRepository(t *testing.T) {
+	repo := mem.NewKafkaConnection()
+	if e := repo.WriteEvents(ctx, newsEvents(t, ListTestData()[:2])); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+
+	// read reads until count messages are handled, failing the message at failAt
+	read := func(count, failAt int) ([]int, error) {
+		readCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		ids := []int{}
+		e := repo.ReadMessage(readCtx, func(ctx context.Context, msg m.Message) error {
+			event, e := m.DecodeEvent(msg.Value)
+			if e != nil {
+				return e
+			}
+			data := event.Payload
+			if len(ids) == failAt {
+				return errors.New("failed to store")
+			}
+			ids = append(ids, data.ID)
+			if len(ids) == count {
+				cancel()
+			}
+			return nil
+		})
+		return ids, e
+	}
+
+	t.Run("Case: Failed Message Is Not Committed", func(t *testing.T) {
+		ids, e := read(2, 1)
+		if e == nil || len(ids) != 1 || ids[0] != 1 {
+			t.Errorf("[ERROR] - Expected error after news 1 but got %v %v", ids, e)
+		}
+	})
+	t.Run("Case: Read From Committed Offset", func(t *testing.T) {
+		ids, e := read(1, -1)
+		if e != nil || len(ids) != 1 || ids[0] != 2 {
+			t.Errorf("[ERROR] - Expected news 2 delivered again but got %v %v", ids, e)
+		}
+	})
+	t.Run("Case: Wait For New Message", func(t *testing.T) {
+		events := newsEvents(t, ListTestData()[2:3])
+		go func() {
+			time.Sleep(time.Millisecond * 50)
+			repo.WriteEvents(ctx, events)
+		}()
+		ids, e := read(1, -1)
+		if e != nil || len(ids) != 1 || ids[0] != 3 {
+			t.Errorf("[ERROR] - Expected news 3 but got %v %v", ids, e)
+		}
+	})
+}
+
+func TestKafkaReadBatch(t *testing.T) {
+	repo := mem.NewKafkaConnection()
+	if e := repo.WriteEvents(ctx, newsEvents(t, ListTestData())); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	batches := [][]int64{}
+	e := repo.ReadBatch(readCtx, 2, func(ctx context.Context, msgs []m.Message) error {
+		offsets := []int64{}
+		for _, v := range msgs {
+			offsets = append(offsets, v.Offset)
+		}
+		batches = append(batches, offsets)
+		if len(batches) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	// the last batch is not full since only one message is left
+	if e != nil || fmt.Sprint(batches) != "[[0 1] [2]]" {
+		t.Errorf("[ERROR] - Expected batches [[0 1] [2]] but got %v %v", batches, e)
+	}
+}
+
+func TestProcessedEventRepository(t *testing.T) {
+	repo := mem.NewProcessedEventRepository(0)
+	if e := repo.MarkProcessed(ctx, "event-1"); e != nil {
+		t.Fatalf("[ERROR] - Failed to mark event %s", e.Error())
+	}
+	tts := []struct {
+		key      string
+		expected bool
+	}{
+		{"event-1", true},
+		{"event-2", false},
+	}
+	for _, tc := range tts {
+		t.Run(tc.key, func(t *testing.T) {
+			processed, e := repo.IsProcessed(ctx, tc.key)
+			if e != nil || processed != tc.expected {
+				t.Errorf("[ERROR] - Expected %v but got %v %v", tc.expected, processed, e)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+
+	"github.com/pkg/errors"
+)
+
+type newsMemoryRepository struct {
+	mu     sync.RWMutex
+	data   map[int]m.News
+	outbox []outboxItem
+}
+
+func NewNewsRepository() repo.NewsRepository {
+	return &newsMemoryRepository{
+		data: map[int]m.News{},
+	}
+}
+
+// Close does nothing, the data lives as long as the process
+func (r *newsMemoryRepository) Close() error {
+	return nil
+}
+
+func (r *newsMemoryRepository) GetBy(ctx context.Context, filter map[string]interface{}) (*m.News, error) {
+	res := new(m.News)
+	if e := ctx.Err(); e != nil {
+		return res, errors.Wrap(e, "repository.News.GetBy")
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := []m.News{}
+	for _, v := range r.data {
+		ok, e := matchNews(v, filter)
+		if e != nil {
+			return res, errors.Wrap(e, "repository.News.GetBy")
+		}
+		if ok {
+			found = append(found, v)
+		}
+	}
+	if len(found) == 0 {
+		return res, errors.Wrap(helper.ErrDataNotFound, "repository.News.GetBy")
+	}
+	// map iteration order is random, the oldest news is picked so the same filter always returns the same news
+	sortNews(found, map[string]bool{"created": true})
+	*res = found[0]
+	return res, nil
+}
+
+func (r *newsMemoryRepository) List(ctx context.Context, param m.GetPayload) ([]m.News, error) {
+	res := []m.News{}
+	if e := ctx.Err(); e != nil {
+		return res, errors.Wrap(e, "repository.News.List")
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, v := range r.data {
+		ok, e := matchNews(v, param.Filter)
+		if e != nil {
+			return res, errors.Wrap(e, "repository.News.List")
+		}
+		if ok && (param.AfterID == nil || v.ID > *param.AfterID) {
+			res = append(res, v)
+		}
+	}
+	if e := sortNews(res, param.Order); e != nil {
+		return []m.News{}, errors.Wrap(e, "repository.News.List")
+	}
+
+	limit := param.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	start, end := paginate(len(res), param.Offset, limit)
+
+	return res[start:end], nil
+}
+
+// GetByIDs keeps the order of ids, missing data is reported with helper.PartialDataError
+func (r *newsMemoryRepository) GetByIDs(ctx context.Context, ids []int) ([]m.News, error) {
+	res := []m.News{}
+	if e := ctx.Err(); e != nil {
+		return res, errors.Wrap(e, "repository.News.GetByIDs")
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, id := range ids {
+		if v, ok := r.data[id]; ok {
+			res = append(res, v)
+		}
+	}
+	res, missing := m.SortByIDs(ids, res)
+	if len(missing) > 0 {
+		return res, errors.Wrap(&helper.PartialDataError{Missing: missing}, "repository.News.GetByIDs")
+	}
+	return res, nil
+}
+
+func (r *newsMemoryRepository) Store(ctx context.Context, data *m.News) error {
+	if e := ctx.Err(); e != nil {
+		return errors.Wrap(e, "repository.News.Store")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.data[data.ID]; ok {
+		return errors.Wrap(helper.ErrDataExists, "repository.News.Store")
+	}
+	data.Version = 1
+	if e := r.writeOutbox(m.NewsCreated, data); e != nil {
+		return errors.Wrap(e, "repository.News.Store")
+	}
+	r.data[data.ID] = *data
+
+	return nil
+}
+
+// writeOutbox appends the event of data to the outbox, the caller holds the lock
+func (r *newsMemoryRepository) writeOutbox(eventType m.EventType, data *m.News) error {
+	event, e := m.NewEvent(eventType, data)
+	if e != nil {
+		return e
+	}
+	outbox, e := m.NewOutbox(event)
+	if e != nil {
+		return e
+	}
+	outbox.ID = int64(len(r.outbox) + 1)
+	r.outbox = append(r.outbox, outboxItem{Outbox: *outbox})
+	return nil
+}
+
+func (r *newsMemoryRepository) StoreMany(ctx context.Context, data []m.News) ([]error, error) {
+	res := make([]error, len(data))
+	if e := ctx.Err(); e != nil {
+		e = errors.Wrap(e, "repository.News.StoreMany")
+		for i := range res {
+			res[i] = e
+		}
+		return res, e
+	}
+	for i := range data {
+		res[i] = r.Store(ctx, &data[i])
+	}
+	return res, nil
+}
+
+func (r *newsMemoryRepository) Update(ctx context.Context, data map[string]interface{}, id int) (*m.News, error) {
+	news := new(m.News)
+	if e := ctx.Err(); e != nil {
+		return news, errors.Wrap(e, "repository.News.Update")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.data[id]
+	if !ok {
+		return news, errors.Wrap(helper.ErrDataNotFound, "repository.News.Update")
+	}
+	if e := applyUpdate(&existing, data); e != nil {
+		return news, errors.Wrap(e, "repository.News.Update")
+	}
+	existing.Version++
+	if e := r.writeOutbox(m.NewsUpdated, &existing); e != nil {
+		return news, errors.Wrap(e, "repository.News.Update")
+	}
+	r.data[id] = existing
+	*news = existing
+
+	return news, nil
+}
+
+func (r *newsMemoryRepository) Delete(ctx context.Context, id int) error {
+	if e := ctx.Err(); e != nil {
+		return errors.Wrap(e, "repository.News.Delete")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.data[id]
+	if !ok {
+		return errors.Wrap(helper.ErrDataNotFound, "repository.News.Delete")
+	}
+	if e := r.writeOutbox(m.NewsDeleted, &existing); e != nil {
+		return errors.Wrap(e, "repository.News.Delete")
+	}
+	delete(r.data, id)
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+
+	"github.com/pkg/errors"
+)
+
+type outboxItem struct {
+	m.Outbox
+	sent         bool
+	claimedUntil time.Time
+}
+
+type outboxMemoryRepository struct {
+	news *newsMemoryRepository
+}
+
+// NewOutboxRepository reads the outbox kept by newsRepo, newsRepo must be created by NewNewsRepository
+func NewOutboxRepository(newsRepo repo.NewsRepository) repo.OutboxRepository {
+	return &outboxMemoryRepository{newsRepo.(*newsMemoryRepository)}
+}
+
+func (r *outboxMemoryRepository) Close() error {
+	return nil
+}
+
+func (r *outboxMemoryRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]m.Outbox, error) {
+	res := []m.Outbox{}
+	if e := ctx.Err(); e != nil {
+		return res, errors.Wrap(e, "repository.Outbox.Claim")
+	}
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	r.news.mu.Lock()
+	defer r.news.mu.Unlock()
+
+	now := time.Now()
+	for i, v := range r.news.outbox {
+		if len(res) == limit {
+			break
+		}
+		if !v.sent && !v.claimedUntil.After(now) {
+			r.news.outbox[i].claimedUntil = now.Add(lease)
+			res = append(res, v.Outbox)
+		}
+	}
+	return res, nil
+}
+
+func (r *outboxMemoryRepository) MarkSent(ctx context.Context, ids []int64) error {
+	if e := ctx.Err(); e != nil {
+		return errors.Wrap(e, "repository.Outbox.MarkSent")
+	}
+	r.news.mu.Lock()
+	defer r.news.mu.Unlock()
+
+	for _, id := range ids {
+		// ID is the position in outbox starting from 1
+		if id > 0 && int(id) <= len(r.news.outbox) {
+			r.news.outbox[id-1].sent = true
+		}
+	}
+	return nil
+}
+
+func (r *outboxMemoryRepository) ChangedSince(ctx context.Context, since time.Time) ([]int, error) {
+	res := []int{}
+	if e := ctx.Err(); e != nil {
+		return res, errors.Wrap(e, "repository.Outbox.ChangedSince")
+	}
+	r.news.mu.RLock()
+	defer r.news.mu.RUnlock()
+
+	seen := map[int]bool{}
+	for _, v := range r.news.outbox {
+		if !v.Created.Before(since) && !seen[v.NewsID] {
+			seen[v.NewsID] = true
+			res = append(res, v.NewsID)
+		}
+	}
+	sort.Ints(res)
+	return res, nil
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+
+	"github.com/pkg/errors"
+)
+
+type processedEventMemoryRepository struct {
+	mu   sync.Mutex
+	keys map[string]time.Time // expiry of every key
+	ttl  time.Duration
+}
+
+// NewProcessedEventRepository forgets a key ttl seconds after it is marked, zero ttl never forgets
+func NewProcessedEventRepository(ttl int) repo.ProcessedEventRepository {
+	return &processedEventMemoryRepository{
+		keys: map[string]time.Time{},
+		ttl:  time.Duration(ttl) * time.Second,
+	}
+}
+
+func (r *processedEventMemoryRepository) Close() error {
+	return nil
+}
+
+func (r *processedEventMemoryRepository) IsProcessed(ctx context.Context, key string) (bool, error) {
+	if e := ctx.Err(); e != nil {
+		return false, errors.Wrap(e, "repository.ProcessedEvent.IsProcessed")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expireAt, ok := r.keys[key]
+	if ok && !expireAt.IsZero() && !time.Now().Before(expireAt) {
+		delete(r.keys, key)
+		return false, nil
+	}
+	return ok, nil
+}
+
+func (r *processedEventMemoryRepository) MarkProcessed(ctx context.Context, key string) error {
+	if e := ctx.Err(); e != nil {
+		return errors.Wrap(e, "repository.ProcessedEvent.MarkProcessed")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expireAt := time.Time{}
+	if r.ttl > 0 {
+		expireAt = time.Now().Add(r.ttl)
+	}
+	r.keys[key] = expireAt
+	return nil
+}
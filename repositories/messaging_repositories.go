@@ -1,10 +1,29 @@
 package repositories
 
 import (
+	"context"
+
 	m "github.com/rinosukmandityo/maknews/models"
 )
 
+// MessageHandler processes one message read from kafka, the message offset is committed only when it returns nil
+type MessageHandler func(ctx context.Context, msg m.Message) error
+
+// BatchHandler processes messages read from kafka in their order, their offsets are committed only when it returns nil
+type BatchHandler func(ctx context.Context, msgs []m.Message) error
+
 type KafkaRepository interface {
-	WriteMessage(data *m.News) error
-	ReadMessage(res chan<- []byte)
+	// WriteEvents sends events in one batch, every event is keyed by its news ID
+	WriteEvents(ctx context.Context, events []m.Event) error
+	// WriteRawMessages sends key and value of msgs as they are, it is used to move messages between topics
+	WriteRawMessages(ctx context.Context, msgs []m.Message) error
+	// ReadMessage joins the consumer group and calls handle for every message until ctx is cancelled.
+	// When handle fails it stops and returns the error without committing, so the message is delivered again.
+	// The message being handled when ctx is cancelled gets a context that is not cancelled, it is finished and committed.
+	// helper.Stopping of that context is closed with ctx, so handle can stop between retries and fail to have it delivered again.
+	ReadMessage(ctx context.Context, handle MessageHandler) error
+	// ReadBatch is ReadMessage for up to size messages at once, it does not wait for a full batch when fewer messages are available.
+	// The next batch is fetched only after handle returns, and every message of the batch is committed only when it returns nil.
+	ReadBatch(ctx context.Context, size int, handle BatchHandler) error
+	Close() error
 }
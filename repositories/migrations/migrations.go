@@ -0,0 +1,243 @@
+// Package migrations keeps the schema of SQL persistence databases in versioned, ordered steps.
+// Every applied step is recorded in schema_migrations so the same database can be upgraded
+// (or rolled back) one version at a time.
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const tableName = "schema_migrations"
+
+var (
+	ErrSchemaOutdated = errors.New("Schema is not migrated, run 'migrate up' first")
+	ErrNoMigration    = errors.New("No migration to roll back")
+	ErrNoDatabase     = errors.New("Database does not exist, run 'migrate up' first")
+)
+
+// Migration is one schema version, Up upgrades into this version and Down reverts it
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+	timeout    time.Duration
+}
+
+// NewMigrator sorts migrations by version, db placeholders are rebound based on its driver.
+// db is nil for a database that does not exist yet, such migrator only reads the status and the other methods return ErrNoDatabase.
+func NewMigrator(db *sqlx.DB, migrations []Migration, timeout int) *Migrator {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return &Migrator{
+		db:         db,
+		migrations: sorted,
+		timeout:    time.Duration(timeout) * time.Second,
+	}
+}
+
+// Close closes the underlying database
+func (mg *Migrator) Close() error {
+	if mg.db == nil {
+		return nil
+	}
+	return mg.db.Close()
+}
+
+func (mg *Migrator) createTable(ctx context.Context) error {
+	if mg.db == nil {
+		return errors.Wrap(ErrNoDatabase, "migrations.CreateTable")
+	}
+	schema := `CREATE TABLE IF NOT EXISTS ` + tableName + ` (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`
+	if _, e := mg.db.ExecContext(ctx, schema); e != nil {
+		return errors.Wrap(e, "migrations.CreateTable")
+	}
+	return nil
+}
+
+func (mg *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, e := mg.db.QueryxContext(ctx, "SELECT version, applied_at FROM "+tableName)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	res := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if e := rows.Scan(&version, &appliedAt); e != nil {
+			return nil, e
+		}
+		res[version] = appliedAt
+	}
+	return res, rows.Err()
+}
+
+func (mg *Migrator) exec(ctx context.Context, statements []string, record string, args ...interface{}) error {
+	tx, e := mg.db.BeginTxx(ctx, nil)
+	if e != nil {
+		return e
+	}
+	for _, stmt := range statements {
+		if _, e := tx.ExecContext(ctx, stmt); e != nil {
+			tx.Rollback()
+			return e
+		}
+	}
+	if _, e := tx.ExecContext(ctx, mg.db.Rebind(record), args...); e != nil {
+		tx.Rollback()
+		return e
+	}
+	return tx.Commit()
+}
+
+// Up applies every pending migration in order and returns the applied ones
+func (mg *Migrator) Up() ([]Migration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mg.timeout)
+	defer cancel()
+	res := []Migration{}
+	if e := mg.createTable(ctx); e != nil {
+		return res, e
+	}
+	applied, e := mg.applied(ctx)
+	if e != nil {
+		return res, errors.Wrap(e, "migrations.Up")
+	}
+
+	for _, v := range mg.migrations {
+		if _, ok := applied[v.Version]; ok {
+			continue
+		}
+		record := "INSERT INTO " + tableName + " (version, name, applied_at) VALUES (?, ?, ?)"
+		if e := mg.exec(ctx, v.Up, record, v.Version, v.Name, time.Now().UTC()); e != nil {
+			return res, errors.Wrap(e, fmt.Sprintf("migrations.Up version %d", v.Version))
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// Down reverts the latest applied migration
+func (mg *Migrator) Down() (Migration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mg.timeout)
+	defer cancel()
+	if e := mg.createTable(ctx); e != nil {
+		return Migration{}, e
+	}
+	applied, e := mg.applied(ctx)
+	if e != nil {
+		return Migration{}, errors.Wrap(e, "migrations.Down")
+	}
+
+	for i := len(mg.migrations) - 1; i >= 0; i-- {
+		v := mg.migrations[i]
+		if _, ok := applied[v.Version]; !ok {
+			continue
+		}
+		record := "DELETE FROM " + tableName + " WHERE version = ?"
+		if e := mg.exec(ctx, v.Down, record, v.Version); e != nil {
+			return v, errors.Wrap(e, fmt.Sprintf("migrations.Down version %d", v.Version))
+		}
+		return v, nil
+	}
+	return Migration{}, errors.Wrap(ErrNoMigration, "migrations.Down")
+}
+
+// Status lists every known migration and whether it has been applied
+func (mg *Migrator) Status() ([]Status, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mg.timeout)
+	defer cancel()
+	res := []Status{}
+	if e := mg.createTable(ctx); e != nil {
+		return res, e
+	}
+	applied, e := mg.applied(ctx)
+	if e != nil {
+		return res, errors.Wrap(e, "migrations.Status")
+	}
+	return mg.status(applied), nil
+}
+
+// ReadStatus is Status without creating schema_migrations, so it never writes to the database.
+// Nothing is applied yet when the database or schema_migrations does not exist.
+func (mg *Migrator) ReadStatus() ([]Status, error) {
+	if mg.db == nil {
+		return mg.status(map[int]time.Time{}), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mg.timeout)
+	defer cancel()
+	applied, e := mg.applied(ctx)
+	if e != nil {
+		// schema_migrations does not exist yet when the database itself still answers
+		if pe := mg.db.PingContext(ctx); pe != nil {
+			return []Status{}, errors.Wrap(e, "migrations.ReadStatus")
+		}
+		applied = map[int]time.Time{}
+	}
+	return mg.status(applied), nil
+}
+
+func (mg *Migrator) status(applied map[int]time.Time) []Status {
+	res := []Status{}
+	for _, v := range mg.migrations {
+		appliedAt, ok := applied[v.Version]
+		res = append(res, Status{
+			Version:   v.Version,
+			Name:      v.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return res
+}
+
+// Check returns ErrSchemaOutdated when any migration has not been applied yet.
+// It never modifies the database so repositories can call it on start.
+// A database that does not answer is reported with its own error, not as an outdated schema.
+func (mg *Migrator) Check() error {
+	if mg.db == nil {
+		return errors.Wrap(ErrNoDatabase, "migrations.Check")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mg.timeout)
+	defer cancel()
+	if e := mg.db.PingContext(ctx); e != nil {
+		return errors.Wrap(e, "migrations.Check")
+	}
+	applied, e := mg.applied(ctx)
+	if e != nil {
+		// the database answers, so schema_migrations does not exist yet
+		return errors.Wrap(ErrSchemaOutdated, "migrations.Check")
+	}
+	for _, v := range mg.migrations {
+		if _, ok := applied[v.Version]; !ok {
+			return errors.Wrap(ErrSchemaOutdated, fmt.Sprintf("migrations.Check version %d", v.Version))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package migrations
+
+// Every SQL backend keeps its own scripts since column types differ between databases.
+// Append new versions at the end, never edit a version that has been released.
+// MySQL commits every DDL statement on its own, so a MySQL version that fails after its first
+// statement or while recording the version stays partly applied and running it again fails,
+// e.g. with a duplicate column. Revert the committed statements by hand, or record the version
+// in schema_migrations when every statement is committed, then run migrate up again.
+
+var MySQL = []Migration{
+	{
+		Version: 1,
+		Name:    "create_news",
+		Up: []string{`CREATE TABLE IF NOT EXISTS news (
+			id INT NOT NULL UNIQUE,
+			author TEXT,
+			body TEXT,
+			created TIMESTAMP
+		)`},
+		Down: []string{`DROP TABLE news`},
+	},
+	{
+		Version: 2,
+		Name:    "create_outbox",
+		Up: []string{`CREATE TABLE IF NOT EXISTS outbox (
+			id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+			news_id INT NOT NULL,
+			payload BLOB NOT NULL,
+			created TIMESTAMP NULL,
+			sent TIMESTAMP NULL
+		)`, `CREATE INDEX outbox_sent ON outbox (sent)`},
+		Down: []string{`DROP TABLE outbox`},
+	},
+	{
+		Version: 3,
+		Name:    "claim_outbox",
+		Up: []string{`ALTER TABLE outbox
+			ADD COLUMN claim VARCHAR(32) NULL,
+			ADD COLUMN claimed_until TIMESTAMP NULL`},
+		Down: []string{`ALTER TABLE outbox DROP COLUMN claim, DROP COLUMN claimed_until`},
+	},
+	{
+		Version: 4,
+		Name:    "version_news",
+		Up:      []string{`ALTER TABLE news ADD COLUMN version INT NOT NULL DEFAULT 1`},
+		Down:    []string{`ALTER TABLE news DROP COLUMN version`},
+	},
+}
+
+var Postgres = []Migration{
+	{
+		Version: 1,
+		Name:    "create_news",
+		Up: []string{`CREATE TABLE IF NOT EXISTS news (
+			id INT NOT NULL UNIQUE,
+			author TEXT,
+			body TEXT,
+			created TIMESTAMPTZ
+		)`},
+		Down: []string{`DROP TABLE news`},
+	},
+	{
+		Version: 2,
+		Name:    "create_outbox",
+		Up: []string{`CREATE TABLE IF NOT EXISTS outbox (
+			id BIGSERIAL PRIMARY KEY,
+			news_id INT NOT NULL,
+			payload BYTEA NOT NULL,
+			created TIMESTAMPTZ,
+			sent TIMESTAMPTZ
+		)`, `CREATE INDEX outbox_sent ON outbox (sent)`},
+		Down: []string{`DROP TABLE outbox`},
+	},
+	{
+		Version: 3,
+		Name:    "claim_outbox",
+		Up: []string{`ALTER TABLE outbox
+			ADD COLUMN claim VARCHAR(32),
+			ADD COLUMN claimed_until TIMESTAMPTZ`},
+		Down: []string{`ALTER TABLE outbox DROP COLUMN claim, DROP COLUMN claimed_until`},
+	},
+	{
+		Version: 4,
+		Name:    "version_news",
+		Up:      []string{`ALTER TABLE news ADD COLUMN version INT NOT NULL DEFAULT 1`},
+		Down:    []string{`ALTER TABLE news DROP COLUMN version`},
+	},
+}
+
+var SQLite = []Migration{
+	{
+		Version: 1,
+		Name:    "create_news",
+		Up: []string{`CREATE TABLE IF NOT EXISTS news (
+			id INTEGER NOT NULL UNIQUE,
+			author TEXT,
+			body TEXT,
+			created TIMESTAMP
+		)`},
+		Down: []string{`DROP TABLE news`},
+	},
+	{
+		Version: 2,
+		Name:    "create_outbox",
+		Up: []string{`CREATE TABLE IF NOT EXISTS outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			news_id INTEGER NOT NULL,
+			payload BLOB NOT NULL,
+			created TIMESTAMP,
+			sent TIMESTAMP
+		)`, `CREATE INDEX outbox_sent ON outbox (sent)`},
+		Down: []string{`DROP TABLE outbox`},
+	},
+	{
+		Version: 3,
+		Name:    "claim_outbox",
+		Up: []string{
+			`ALTER TABLE outbox ADD COLUMN claim VARCHAR(32)`,
+			`ALTER TABLE outbox ADD COLUMN claimed_until TIMESTAMP`,
+		},
+		Down: []string{
+			`ALTER TABLE outbox DROP COLUMN claimed_until`,
+			`ALTER TABLE outbox DROP COLUMN claim`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "version_news",
+		Up:      []string{`ALTER TABLE news ADD COLUMN version INT NOT NULL DEFAULT 1`},
+		Down:    []string{`ALTER TABLE news DROP COLUMN version`},
+	},
+}
@@ -2,9 +2,8 @@ package mongo
 
 import (
 	"context"
-	"fmt"
 	"gopkg.in/mgo.v2/bson"
-	"reflect"
+	"sort"
 	"time"
 
 	"github.com/rinosukmandityo/maknews/helper"
@@ -12,11 +11,15 @@ import (
 	repo "github.com/rinosukmandityo/maknews/repositories"
 
 	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
+// defaultLimit is used when payload limit is not set, same as Elasticsearch default size
+const defaultLimit = 10
+
 type newsMongoRepository struct {
 	client   *mongo.Client
 	database string
@@ -49,13 +52,25 @@ func NewNewsRepository(mongoURL, mongoDB string, mongoTimeout int) (repo.NewsRep
 	return repo, nil
 }
 
-func (r *newsMongoRepository) GetBy(filter map[string]interface{}) (*m.News, error) {
-	res := new(m.News)
+// Close disconnects the client, it waits at most timeout for the pending operations
+func (r *newsMongoRepository) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
 	defer cancel()
+	return r.client.Disconnect(ctx)
+}
+
+func (r *newsMongoRepository) GetBy(ctx context.Context, filter map[string]interface{}) (*m.News, error) {
+	res := new(m.News)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
 	c := r.client.Database(r.database).Collection(res.TableName())
-	convertID(filter)
-	if e := c.FindOne(ctx, filter).Decode(res); e != nil {
+	query, e := newsFilter(filter)
+	if e != nil {
+		return res, errors.Wrap(e, "repository.News.GetById")
+	}
+	// the oldest news is picked so the same filter always returns the same news
+	opts := options.FindOne().SetSort(primitive.D{{Key: "created", Value: 1}, {Key: "_id", Value: 1}})
+	if e := c.FindOne(ctx, query, opts).Decode(res); e != nil {
 		if e == mongo.ErrNoDocuments {
 			return res, errors.Wrap(helper.ErrDataNotFound, "repository.News.GetById")
 		}
@@ -64,36 +79,230 @@ func (r *newsMongoRepository) GetBy(filter map[string]interface{}) (*m.News, err
 	return res, nil
 
 }
-func (r *newsMongoRepository) Store(data *m.News) error {
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+func (r *newsMongoRepository) List(ctx context.Context, param m.GetPayload) ([]m.News, error) {
+	res := []m.News{}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
-	c := r.client.Database(r.database).Collection(data.TableName())
-	if _, e := c.InsertOne(ctx, data); e != nil {
+	c := r.client.Database(r.database).Collection(new(m.News).TableName())
+
+	filter, e := newsFilter(param.Filter)
+	if e != nil {
+		return res, errors.Wrap(e, "repository.News.List")
+	}
+	if param.AfterID != nil {
+		after := bson.M{"$gt": *param.AfterID}
+		if id, ok := filter["_id"]; ok {
+			after["$eq"] = id
+		}
+		filter["_id"] = after
+	}
+
+	keys := []string{}
+	for k := range param.Order {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	order := primitive.D{}
+	for _, k := range keys {
+		field := k
+		switch k {
+		case "id":
+			field = "_id"
+		case "author", "body", "created":
+		default:
+			return res, errors.Wrap(helper.ErrDataInvalid, "repository.News.List")
+		}
+		direction := -1
+		if param.Order[k] {
+			direction = 1
+		}
+		order = append(order, primitive.E{Key: field, Value: direction})
+	}
+	if _, ok := param.Order["id"]; !ok {
+		order = append(order, primitive.E{Key: "_id", Value: 1})
+	}
+
+	limit := param.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	opts := options.Find().SetSort(order).SetSkip(int64(param.Offset)).SetLimit(int64(limit))
+	cur, e := c.Find(ctx, filter, opts)
+	if e != nil {
+		return res, errors.Wrap(e, "repository.News.List")
+	}
+	if e := cur.All(ctx, &res); e != nil {
+		return res, errors.Wrap(e, "repository.News.List")
+	}
+	return res, nil
+}
+
+// GetByIDs keeps the order of ids, missing data is reported with helper.PartialDataError
+func (r *newsMongoRepository) GetByIDs(ctx context.Context, ids []int) ([]m.News, error) {
+	res := []m.News{}
+	if len(ids) == 0 {
+		return res, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	c := r.client.Database(r.database).Collection(new(m.News).TableName())
+
+	cur, e := c.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if e != nil {
+		return res, errors.Wrap(e, "repository.News.GetByIDs")
+	}
+	if e := cur.All(ctx, &res); e != nil {
+		return res, errors.Wrap(e, "repository.News.GetByIDs")
+	}
+	res, missing := m.SortByIDs(ids, res)
+	if len(missing) > 0 {
+		return res, errors.Wrap(&helper.PartialDataError{Missing: missing}, "repository.News.GetByIDs")
+	}
+	return res, nil
+}
+
+// nextOutboxID increments the outbox sequence kept in counters collection
+func (r *newsMongoRepository) nextOutboxID(ctx context.Context) (int64, error) {
+	counter := struct {
+		Seq int64 `bson:"seq"`
+	}{}
+	c := r.client.Database(r.database).Collection("counters")
+	e := c.FindOneAndUpdate(ctx, bson.M{"_id": new(m.Outbox).TableName()}, bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&counter)
+	return counter.Seq, e
+}
+
+// writeOutbox inserts the event of data into the outbox inside the transaction of sc
+func (r *newsMongoRepository) writeOutbox(sc mongo.SessionContext, eventType m.EventType, data *m.News) error {
+	event, e := m.NewEvent(eventType, data)
+	if e != nil {
+		return e
+	}
+	outbox, e := m.NewOutbox(event)
+	if e != nil {
+		return e
+	}
+	if outbox.ID, e = r.nextOutboxID(sc); e != nil {
+		return e
+	}
+	_, e = r.client.Database(r.database).Collection(outbox.TableName()).InsertOne(sc, outbox)
+	return e
+}
+
+// withTransaction runs fn in one transaction, so the change is never stored without being published.
+// MongoDB only supports transaction on replica set.
+func (r *newsMongoRepository) withTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, e := r.client.StartSession()
+	if e != nil {
+		return e
+	}
+	defer session.EndSession(ctx)
+
+	_, e = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return e
+}
+
+// insert stores data as version 1 and its created event in one transaction
+func (r *newsMongoRepository) insert(ctx context.Context, data *m.News) error {
+	data.Version = 1
+	return r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		c := r.client.Database(r.database).Collection(data.TableName())
+		if _, e := c.InsertOne(sc, data); e != nil {
+			if mongo.IsDuplicateKeyError(e) {
+				return helper.ErrDataExists
+			}
+			return e
+		}
+		return r.writeOutbox(sc, m.NewsCreated, data)
+	})
+}
+
+func (r *newsMongoRepository) Store(ctx context.Context, data *m.News) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	if e := r.insert(ctx, data); e != nil {
 		return errors.Wrap(e, "repository.News.Store")
 	}
 
 	return nil
 
 }
-func (r *newsMongoRepository) Update(data map[string]interface{}, id int) (*m.News, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+
+// storeManyChunk is the number of news StoreMany writes within one timeout
+const storeManyChunk = 100
+
+// StoreMany inserts every data in its own transaction, chunks of storeManyChunk share one timeout.
+// A news that already exists does not stop the others, any other failure stops the batch and is given to every news not stored.
+func (r *newsMongoRepository) StoreMany(ctx context.Context, data []m.News) ([]error, error) {
+	res := make([]error, len(data))
+	for start := 0; start < len(data); start += storeManyChunk {
+		end := start + storeManyChunk
+		if end > len(data) {
+			end = len(data)
+		}
+		stored, e := r.insertChunk(ctx, data[start:end], res[start:end])
+		if e != nil {
+			e = errors.Wrap(e, "repository.News.StoreMany")
+			for i := start + stored; i < len(data); i++ {
+				res[i] = e
+			}
+			return res, e
+		}
+	}
+
+	return res, nil
+}
+
+// insertChunk returns how many data are done before the failure, a duplicate news is only reported in res
+func (r *newsMongoRepository) insertChunk(ctx context.Context, data []m.News, res []error) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	for i := range data {
+		if e := r.insert(ctx, &data[i]); e != nil {
+			if e != helper.ErrDataExists {
+				return i, e
+			}
+			res[i] = errors.Wrap(e, "repository.News.StoreMany")
+		}
+	}
+	return len(data), nil
+}
+
+// Update changes the news and writes its updated event in one transaction
+func (r *newsMongoRepository) Update(ctx context.Context, data map[string]interface{}, id int) (*m.News, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 	news := new(m.News)
-	c := r.client.Database(r.database).Collection(news.TableName())
 	filter := map[string]interface{}{"_id": id}
-	convertID(data)
-	if fmt.Sprintf("%T", data["created"]) == "string" {
-		created, _ := time.Parse("2006-01-02T15:04:05Z", data["created"].(string))
-		data["created"] = created
-	}
-	if res, e := c.UpdateOne(ctx, filter, bson.M{"$set": data}, options.Update().SetUpsert(false)); e != nil {
-		return news, errors.Wrap(e, "repository.News.Update")
-	} else {
-		if res.MatchedCount == 0 && res.ModifiedCount == 0 {
-			return news, errors.Wrap(helper.ErrDataNotFound, "repository.News.Update")
+	// the identity comes from the URL and the version is kept here, the body may repeat them but they are never changed
+	fields := bson.M{}
+	for k, v := range data {
+		if k == "id" || k == "_id" || k == "version" {
+			continue
+		}
+		field, value, e := newsField(k, v)
+		if e != nil {
+			return news, errors.Wrap(e, "repository.News.Update")
 		}
+		fields[field] = value
+	}
+	if len(fields) == 0 {
+		return news, errors.Wrap(helper.ErrDataInvalid, "repository.News.Update")
 	}
-	news, e := r.GetBy(filter)
+	e := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		c := r.client.Database(r.database).Collection(news.TableName())
+		opts := options.FindOneAndUpdate().SetUpsert(false).SetReturnDocument(options.After)
+		if e := c.FindOneAndUpdate(sc, filter, bson.M{"$set": fields, "$inc": bson.M{"version": 1}}, opts).Decode(news); e != nil {
+			if e == mongo.ErrNoDocuments {
+				return helper.ErrDataNotFound
+			}
+			return e
+		}
+		return r.writeOutbox(sc, m.NewsUpdated, news)
+	})
 	if e != nil {
 		return news, errors.Wrap(e, "repository.News.Update")
 	}
@@ -101,30 +310,63 @@ func (r *newsMongoRepository) Update(data map[string]interface{}, id int) (*m.Ne
 	return news, nil
 
 }
-func (r *newsMongoRepository) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+
+// Delete removes the news and writes its deleted event carrying the last state in one transaction
+func (r *newsMongoRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 	filter := map[string]interface{}{"_id": id}
-	c := r.client.Database(r.database).Collection(new(m.News).TableName())
-	if res, e := c.DeleteOne(ctx, filter); e != nil {
-		return errors.Wrap(e, "repository.News.Delete")
-	} else {
-		if res.DeletedCount == 0 {
-			return errors.Wrap(helper.ErrDataNotFound, "repository.News.Delete")
+	e := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		news := new(m.News)
+		c := r.client.Database(r.database).Collection(news.TableName())
+		if e := c.FindOneAndDelete(sc, filter).Decode(news); e != nil {
+			if e == mongo.ErrNoDocuments {
+				return helper.ErrDataNotFound
+			}
+			return e
 		}
+		return r.writeOutbox(sc, m.NewsDeleted, news)
+	})
+	if e != nil {
+		return errors.Wrap(e, "repository.News.Delete")
 	}
 
 	return nil
 }
 
-func convertID(data map[string]interface{}) {
-	if _, ok := data["id"]; ok {
-		switch helper.Kind(data["id"]) {
-		case reflect.Float64:
-			data["_id"] = int(data["id"].(float64))
-		case reflect.Int:
-			data["_id"] = data["id"].(int)
+// newsField converts value into the type of models.News field and returns its document key,
+// unknown field or wrong type returns ErrDataInvalid like the SQL and memory adapters
+func newsField(name string, value interface{}) (string, interface{}, error) {
+	switch name {
+	case "id", "_id":
+		id, e := helper.ToInt(value)
+		if e != nil {
+			return "", nil, helper.ErrDataInvalid
+		}
+		return "_id", id, nil
+	case "author", "body":
+		if s, ok := value.(string); ok {
+			return name, s, nil
+		}
+	case "created":
+		created, e := helper.ToTime(value)
+		if e != nil {
+			return "", nil, helper.ErrDataInvalid
 		}
-		delete(data, "id")
+		return name, created, nil
 	}
+	return "", nil, helper.ErrDataInvalid
+}
+
+// newsFilter validates every key of filter, so data coming from HTTP request never becomes a query operator
+func newsFilter(filter map[string]interface{}) (bson.M, error) {
+	res := bson.M{}
+	for k, v := range filter {
+		field, value, e := newsField(k, v)
+		if e != nil {
+			return res, e
+		}
+		res[field] = value
+	}
+	return res, nil
 }
@@ -0,0 +1,52 @@
+// +build mongo_repo
+
+package mongo_test
+
+import (
+	"os"
+	"testing"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	mg "github.com/rinosukmandityo/maknews/repositories/mongodb"
+	"github.com/rinosukmandityo/maknews/repositories/repotest"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v -tags=mongo_repo
+
+	===================================
+	TO SET DATABASE INFO FROM TERMINAL
+	===================================
+	set MAKNEWS_DATABASE_URL=mongodb://localhost:27017/local
+	set MAKNEWS_DATABASE_NAME=news
+*/
+
+func TestNewsRepositoryContract(t *testing.T) {
+	url := os.Getenv("MAKNEWS_DATABASE_URL")
+	if url == "" {
+		url = "mongodb://localhost:27017/local"
+	}
+	db := os.Getenv("MAKNEWS_DATABASE_NAME")
+	if db == "" {
+		db = "news"
+	}
+	newsRepo, e := mg.NewNewsRepository(url, db, 10)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer newsRepo.Close()
+	repotest.RunNewsRepositoryContract(t, func(t *testing.T) repo.NewsRepository {
+		return newsRepo
+	})
+
+	// news and outbox are written in one transaction which needs MongoDB replica set
+	outboxRepo, e := mg.NewOutboxRepository(url, db, 10)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer outboxRepo.Close()
+	repotest.RunOutboxContract(t, newsRepo, outboxRepo)
+}
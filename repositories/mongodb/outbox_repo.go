@@ -0,0 +1,106 @@
+package mongo
+
+import (
+	"context"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type outboxMongoRepository struct {
+	client   *mongo.Client
+	database string
+	timeout  time.Duration
+}
+
+// NewOutboxRepository connects to the same database as NewNewsRepository, it is used by the outbox relay
+func NewOutboxRepository(mongoURL, mongoDB string, mongoTimeout int) (repo.OutboxRepository, error) {
+	repo := &outboxMongoRepository{
+		timeout:  time.Duration(mongoTimeout) * time.Second,
+		database: mongoDB,
+	}
+	client, e := newNewsClient(mongoURL, mongoTimeout)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewOutboxRepository")
+	}
+	repo.client = client
+	return repo, nil
+}
+
+func (r *outboxMongoRepository) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	return r.client.Disconnect(ctx)
+}
+
+// Claim takes the claimable messages one by one, every FindOneAndUpdate is atomic so two relays never claim the same message
+func (r *outboxMongoRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]m.Outbox, error) {
+	res := []m.Outbox{}
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	c := r.client.Database(r.database).Collection(new(m.Outbox).TableName())
+
+	now := time.Now().UTC()
+	filter := bson.M{"sent": nil, "$or": []bson.M{{"claimed_until": nil}, {"claimed_until": bson.M{"$lt": now}}}}
+	update := bson.M{"$set": bson.M{"claimed_until": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(primitive.D{{Key: "_id", Value: 1}}).SetReturnDocument(options.After)
+	for len(res) < limit {
+		outbox := m.Outbox{}
+		if e := c.FindOneAndUpdate(ctx, filter, update, opts).Decode(&outbox); e != nil {
+			if e == mongo.ErrNoDocuments {
+				break
+			}
+			return res, errors.Wrap(e, "repository.Outbox.Claim")
+		}
+		res = append(res, outbox)
+	}
+	return res, nil
+}
+
+func (r *outboxMongoRepository) MarkSent(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	c := r.client.Database(r.database).Collection(new(m.Outbox).TableName())
+
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	if _, e := c.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"sent": time.Now().UTC()}}); e != nil {
+		return errors.Wrap(e, "repository.Outbox.MarkSent")
+	}
+	return nil
+}
+
+func (r *outboxMongoRepository) ChangedSince(ctx context.Context, since time.Time) ([]int, error) {
+	res := []int{}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	c := r.client.Database(r.database).Collection(new(m.Outbox).TableName())
+
+	opts := options.Find().SetSort(primitive.D{{Key: "news_id", Value: 1}}).SetProjection(bson.M{"news_id": 1})
+	cur, e := c.Find(ctx, bson.M{"created": bson.M{"$gte": since.UTC()}}, opts)
+	if e != nil {
+		return res, errors.Wrap(e, "repository.Outbox.ChangedSince")
+	}
+	outbox := []m.Outbox{}
+	if e := cur.All(ctx, &outbox); e != nil {
+		return res, errors.Wrap(e, "repository.Outbox.ChangedSince")
+	}
+	for _, v := range outbox {
+		if len(res) == 0 || res[len(res)-1] != v.NewsID {
+			res = append(res, v.NewsID)
+		}
+	}
+	return res, nil
+}
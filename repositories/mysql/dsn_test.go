@@ -0,0 +1,42 @@
+package mysql
+
+import "testing"
+
+func TestDSN(t *testing.T) {
+	tts := []struct {
+		name     string
+		URL      string
+		expected string
+		server   string
+	}{
+		{
+			name:     "Case: Without Options",
+			URL:      "root:root@tcp(127.0.0.1:3306)/news",
+			expected: "root:root@tcp(127.0.0.1:3306)/news?parseTime=true",
+			server:   "root:root@tcp(127.0.0.1:3306)/",
+		},
+		{
+			name:     "Case: With Options",
+			URL:      "root:root@tcp(127.0.0.1:3306)/news?tls=true&timeout=5s",
+			expected: "root:root@tcp(127.0.0.1:3306)/news?tls=true&timeout=5s&parseTime=true",
+			server:   "root:root@tcp(127.0.0.1:3306)/?tls=true&timeout=5s",
+		},
+		{
+			name:     "Case: Slash In Password",
+			URL:      "root:p/ss@tcp(127.0.0.1:3306)/news",
+			expected: "root:p/ss@tcp(127.0.0.1:3306)/news?parseTime=true",
+			server:   "root:p/ss@tcp(127.0.0.1:3306)/",
+		},
+	}
+
+	for _, tt := range tts {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := dsn(tt.URL, "parseTime=true"); res != tt.expected {
+				t.Errorf("[ERROR] - Expected %s but got %s", tt.expected, res)
+			}
+			if res := serverURL(tt.URL); res != tt.server {
+				t.Errorf("[ERROR] - Expected server %s but got %s", tt.server, res)
+			}
+		})
+	}
+}
@@ -6,212 +6,139 @@ import (
 	"strings"
 	"time"
 
-	"github.com/rinosukmandityo/maknews/helper"
-	m "github.com/rinosukmandityo/maknews/models"
 	repo "github.com/rinosukmandityo/maknews/repositories"
+	"github.com/rinosukmandityo/maknews/repositories/migrations"
+	"github.com/rinosukmandityo/maknews/repositories/sqlquery"
+	"github.com/rinosukmandityo/maknews/repositories/sqlrepo"
 
-	"database/sql"
-	_ "github.com/go-sql-driver/mysql"
+	driver "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
-type newsMySQLRepository struct {
-	baseURL string
-	url     string
-	timeout time.Duration
+// PoolConfig controls the connection pool owned by the repository, zero value means database/sql default
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
-func newNewsClient(URL string) (*sql.DB, error) {
-	db, e := sql.Open("mysql", URL)
-	if e != nil {
-		return nil, e
-	}
-	if e = db.Ping(); e != nil {
-		return nil, e
+// dsn appends the connection options to URL, URL may already carry its own options like "...news?tls=true"
+func dsn(URL, options string) string {
+	separator := "?"
+	if strings.Contains(URL, "?") {
+		separator = "&"
 	}
-	return db, e
+	return URL + separator + options
 }
 
-func (r *newsMySQLRepository) createNewTable() error {
-	tablename := new(m.News).TableName()
-	schema := `CREATE TABLE ` + tablename + ` (
-		id INT NOT NULL UNIQUE,
-		author TEXT,
-		body TEXT,
-		created TIMESTAMP
-	);`
-	db, e := sqlx.Connect("mysql", r.url)
-	if e != nil {
-		return errors.Wrap(e, "repository.News.CreateTable")
-	}
-	defer db.Close()
-	res, e := db.Exec(schema)
-	if res != nil && e == nil {
-		fmt.Println("Table", tablename, "created")
+// serverURL drops the database name from URL but keeps its options, so the database can be created before it is selected
+func serverURL(URL string) string {
+	path, options := URL, ""
+	if i := strings.Index(URL, "?"); i >= 0 {
+		path, options = URL[:i], URL[i:]
 	}
-	return nil
+	return path[:strings.LastIndex(path, "/")+1] + options
 }
 
-func (r *newsMySQLRepository) testDBConnection() error {
-	dbname := new(m.News).TableName()
-	db, e := sql.Open("mysql", r.url)
+func newNewsClient(URL string, timeout time.Duration, pool PoolConfig) (*sqlx.DB, error) {
+	db, e := sqlx.Open("mysql", URL)
 	if e != nil {
-		return e
+		return nil, e
 	}
-	if e = db.Ping(); e != nil {
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if e = db.PingContext(ctx); e != nil {
 		db.Close()
-		if strings.Contains(strings.ToLower(e.Error()), "unknown database") {
-			db, e = sql.Open("mysql", r.baseURL)
-			if e != nil {
-				return e
-			}
-			defer db.Close()
-			_, e := db.Exec(fmt.Sprintf("CREATE DATABASE %s", dbname))
-			if e != nil {
-				fmt.Println(e.Error())
-				return e
-			}
-			fmt.Println("Database", dbname, "created")
-		}
-		return e
+		return nil, e
 	}
-	db.Close()
-	return nil
+	return db, nil
 }
 
-func NewNewsRepository(URL, DB string, timeout int) (repo.NewsRepository, error) {
-	repo := &newsMySQLRepository{
-		baseURL: strings.Split(URL, "/")[0] + "/",
-		url:     fmt.Sprintf("%s?parseTime=true", URL),
-		timeout: time.Duration(timeout) * time.Second,
-	}
-	repo.testDBConnection()
-	repo.createNewTable()
+func isDuplicateEntry(e error) bool {
+	mysqlErr, ok := e.(*driver.MySQLError)
+	return ok && mysqlErr.Number == 1062 // ER_DUP_ENTRY
+}
 
-	return repo, nil
+func isUnknownDatabase(e error) bool {
+	mysqlErr, ok := e.(*driver.MySQLError)
+	return ok && mysqlErr.Number == 1049 // ER_BAD_DB_ERROR
 }
 
-func (r *newsMySQLRepository) GetBy(filter map[string]interface{}) (*m.News, error) {
-	res := new(m.News)
-	db, e := sqlx.Connect("mysql", r.url)
+// createDatabase connects without selecting any database and creates DB
+func createDatabase(baseURL, DB string, timeout time.Duration) error {
+	db, e := newNewsClient(baseURL, timeout, PoolConfig{MaxOpenConns: 1})
 	if e != nil {
-		return res, errors.Wrap(e, "repository.News.GetBy")
+		return e
 	}
 	defer db.Close()
-	q := constructGetBy(filter)
-
-	if e = db.Get(res, q); e != nil {
-		if e == sql.ErrNoRows {
-			return res, errors.Wrap(helper.ErrDataNotFound, "repository.News.GetBy")
-		}
-		return res, errors.Wrap(e, "repository.News.GetBy")
+	if _, e := db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", DB)); e != nil {
+		return e
 	}
-	return res, nil
-
+	fmt.Println("Database", DB, "created")
+	return nil
 }
-func (r *newsMySQLRepository) Store(data *m.News) error {
-	db, e := newNewsClient(r.url)
-	if e != nil {
-		return errors.Wrap(e, "repository.News.Store")
-	}
-	defer db.Close()
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
-	defer cancel()
-	conn, e := db.Conn(ctx)
-	if e != nil {
-		return errors.Wrap(e, "repository.News.Store")
-	}
-	defer conn.Close()
-
-	q, dataField := constructStoreQuery(data)
 
-	stmt, e := conn.PrepareContext(ctx, q)
-	if e != nil {
-		return errors.Wrap(e, "repository.News.Store")
+// NewMigrator opens a connection for schema migration, the database is created when it does not exist yet
+func NewMigrator(URL, DB string, timeout int) (*migrations.Migrator, error) {
+	_timeout := time.Duration(timeout) * time.Second
+	url := dsn(URL, "parseTime=true")
+	db, e := newNewsClient(url, _timeout, PoolConfig{MaxOpenConns: 1})
+	if isUnknownDatabase(e) {
+		if e = createDatabase(serverURL(URL), DB, _timeout); e != nil {
+			return nil, errors.Wrap(e, "repository.NewMigrator")
+		}
+		db, e = newNewsClient(url, _timeout, PoolConfig{MaxOpenConns: 1})
 	}
-	if _, e := stmt.Exec(dataField...); e != nil {
-		return errors.Wrap(e, "repository.News.Store")
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewMigrator")
 	}
-
-	return nil
-
+	return migrations.NewMigrator(db, migrations.MySQL, timeout), nil
 }
 
-func (r *newsMySQLRepository) Update(data map[string]interface{}, id int) (*m.News, error) {
-	news := new(m.News)
-	db, e := newNewsClient(r.url)
-	if e != nil {
-		return news, errors.Wrap(e, "repository.News.Store")
+// NewReadOnlyMigrator opens a connection for reading the migration status, it never creates the database
+func NewReadOnlyMigrator(URL string, timeout int) (*migrations.Migrator, error) {
+	db, e := newNewsClient(dsn(URL, "parseTime=true"), time.Duration(timeout)*time.Second, PoolConfig{MaxOpenConns: 1})
+	if isUnknownDatabase(e) {
+		return migrations.NewMigrator(nil, migrations.MySQL, timeout), nil
 	}
-	defer db.Close()
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
-	defer cancel()
-	conn, e := db.Conn(ctx)
 	if e != nil {
-		return news, errors.Wrap(e, "repository.News.Update")
+		return nil, errors.Wrap(e, "repository.NewReadOnlyMigrator")
 	}
-	defer conn.Close()
+	return migrations.NewMigrator(db, migrations.MySQL, timeout), nil
+}
 
-	filter := map[string]interface{}{"id": id}
-	q, dataField := constructUpdateQuery(data, filter)
-	stmt, e := conn.PrepareContext(ctx, q)
+// connect opens the pool used by the repositories, it refuses to connect when the schema has pending migrations
+func connect(URL string, timeout int, pool PoolConfig) (*sqlx.DB, error) {
+	url := dsn(URL, "parseTime=true&clientFoundRows=true")
+	db, e := newNewsClient(url, time.Duration(timeout)*time.Second, pool)
 	if e != nil {
-		return news, errors.Wrap(e, "repository.News.Update")
-	}
-	defer stmt.Close()
-	if res, e := stmt.Exec(dataField...); e != nil {
-		return news, errors.Wrap(e, "repository.News.Update")
-	} else {
-		count, e := res.RowsAffected()
-		if e != nil {
-			return news, errors.Wrap(e, "repository.News.Update")
-		}
-		if count == 0 {
-			return news, errors.Wrap(helper.ErrDataNotFound, "repository.News.Update")
-		}
+		return nil, e
 	}
-	news, e = r.GetBy(filter)
-	if e != nil {
-		return news, errors.Wrap(e, "repository.News.Update")
+	if e = migrations.NewMigrator(db, migrations.MySQL, timeout).Check(); e != nil {
+		db.Close()
+		return nil, e
 	}
+	return db, nil
+}
 
-	return news, nil
-
+// dialect is MySQL flavour of the shared sqlx repositories
+var dialect = sqlrepo.Dialect{
+	Placeholder: sqlquery.Question,
+	IsDuplicate: isDuplicateEntry,
 }
-func (r *newsMySQLRepository) Delete(id int) error {
-	db, e := newNewsClient(r.url)
-	if e != nil {
-		return errors.Wrap(e, "repository.News.Delete")
-	}
-	defer db.Close()
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
-	defer cancel()
-	conn, e := db.Conn(ctx)
-	if e != nil {
-		return errors.Wrap(e, "repository.News.Delete")
-	}
-	defer conn.Close()
 
-	filter := map[string]interface{}{"id": id}
-	q, data := constructDeleteQuery(filter)
-	stmt, e := conn.PrepareContext(ctx, q)
+// NewNewsRepository refuses to start when the schema has pending migrations
+func NewNewsRepository(URL, DB string, timeout int, pool PoolConfig) (repo.NewsRepository, error) {
+	db, e := connect(URL, timeout, pool)
 	if e != nil {
-		return errors.Wrap(e, "repository.News.Delete")
-	}
-	defer stmt.Close()
-	if res, e := stmt.Exec(data...); e != nil {
-		return errors.Wrap(e, "repository.News.Delete")
-	} else {
-		count, e := res.RowsAffected()
-		if e != nil {
-			return errors.Wrap(e, "repository.News.Delete")
-		}
-		if count == 0 {
-			return errors.Wrap(helper.ErrDataNotFound, "repository.News.Delete")
-		}
+		return nil, errors.Wrap(e, "repository.NewNewsRepository")
 	}
-
-	return nil
-
+	return sqlrepo.NewNewsRepository(db, dialect, time.Duration(timeout)*time.Second), nil
 }
@@ -0,0 +1,79 @@
+// +build mysql_repo
+
+package mysql_test
+
+import (
+	"os"
+	"testing"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	mr "github.com/rinosukmandityo/maknews/repositories/mysql"
+	"github.com/rinosukmandityo/maknews/repositories/repotest"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v -tags=mysql_repo
+
+	===================================
+	TO SET DATABASE INFO FROM TERMINAL
+	===================================
+	set MAKNEWS_DATABASE_URL=root:Password.1@tcp(127.0.0.1:3306)/news
+*/
+
+func TestNewsRepositoryContract(t *testing.T) {
+	url := os.Getenv("MAKNEWS_DATABASE_URL")
+	if url == "" {
+		url = "root:root@tcp(127.0.0.1:3306)/news"
+	}
+	migrator, e := mr.NewMigrator(url, "news", 10)
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, e = migrator.Up()
+	migrator.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	newsRepo, e := mr.NewNewsRepository(url, "news", 10, mr.PoolConfig{MaxOpenConns: 5, MaxIdleConns: 5})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer newsRepo.Close()
+	repotest.RunNewsRepositoryContract(t, func(t *testing.T) repo.NewsRepository {
+		return newsRepo
+	})
+
+	outboxRepo, e := mr.NewOutboxRepository(url, "news", 10, mr.PoolConfig{MaxOpenConns: 5, MaxIdleConns: 5})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer outboxRepo.Close()
+	repotest.RunOutboxContract(t, newsRepo, outboxRepo)
+}
+
+func TestURLWithOptions(t *testing.T) {
+	url := os.Getenv("MAKNEWS_DATABASE_URL")
+	if url == "" {
+		url = "root:root@tcp(127.0.0.1:3306)/news"
+	}
+	url += "?timeout=5s"
+	migrator, e := mr.NewMigrator(url, "news", 10)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to migrate URL with options %s", e.Error())
+	}
+	_, e = migrator.Up()
+	migrator.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	newsRepo, e := mr.NewNewsRepository(url, "news", 10, mr.PoolConfig{MaxOpenConns: 5, MaxIdleConns: 5})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to open URL with options %s", e.Error())
+	}
+	newsRepo.Close()
+}
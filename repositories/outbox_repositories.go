@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	m "github.com/rinosukmandityo/maknews/models"
+)
+
+// OutboxRepository reads the outbox messages that NewsRepository.Store writes together with the news
+type OutboxRepository interface {
+	// Claim returns at most limit messages that have not been sent, oldest first. The returned messages are hidden
+	// from other claims until lease passes, so relays running on every replica never publish the same message together.
+	// A message that is not marked sent within its lease is claimed again.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]m.Outbox, error)
+	MarkSent(ctx context.Context, ids []int64) error
+	// ChangedSince returns the ID of every news with a message created at or after since, sent or not, ordered by ID
+	ChangedSince(ctx context.Context, since time.Time) ([]int, error)
+	Close() error
+}
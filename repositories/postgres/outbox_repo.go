@@ -0,0 +1,19 @@
+package postgres
+
+import (
+	"time"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	"github.com/rinosukmandityo/maknews/repositories/sqlrepo"
+
+	"github.com/pkg/errors"
+)
+
+// NewOutboxRepository connects to the same database as NewNewsRepository, it is used by the outbox relay
+func NewOutboxRepository(URL, DB string, timeout int) (repo.OutboxRepository, error) {
+	db, e := connect(URL, timeout)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewOutboxRepository")
+	}
+	return sqlrepo.NewOutboxRepository(db, dialect, time.Duration(timeout)*time.Second), nil
+}
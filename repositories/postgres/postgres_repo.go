@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	"github.com/rinosukmandityo/maknews/repositories/migrations"
+	"github.com/rinosukmandityo/maknews/repositories/sqlquery"
+	"github.com/rinosukmandityo/maknews/repositories/sqlrepo"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+func newNewsClient(URL string, timeout time.Duration) (*sqlx.DB, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	db, e := sqlx.Open("postgres", URL)
+	if e != nil {
+		return nil, e
+	}
+	if e = db.PingContext(ctx); e != nil {
+		db.Close()
+		return nil, e
+	}
+	return db, nil
+}
+
+func isDuplicateEntry(e error) bool {
+	pqErr, ok := e.(*pq.Error)
+	return ok && pqErr.Code == "23505" // unique_violation
+}
+
+// createDatabase connects into maintenance database "postgres" and creates the database given in URL
+func createDatabase(URL, DB string, timeout time.Duration) error {
+	u, e := url.Parse(URL)
+	if e != nil {
+		return e
+	}
+	u.Path = "/postgres"
+	db, e := newNewsClient(u.String(), timeout)
+	if e != nil {
+		return e
+	}
+	defer db.Close()
+	if _, e := db.Exec(fmt.Sprintf("CREATE DATABASE %s", pq.QuoteIdentifier(DB))); e != nil {
+		return e
+	}
+	fmt.Println("Database", DB, "created")
+	return nil
+}
+
+// NewMigrator opens a connection for schema migration, the database is created when it does not exist yet
+func NewMigrator(URL, DB string, timeout int) (*migrations.Migrator, error) {
+	_timeout := time.Duration(timeout) * time.Second
+	db, e := newNewsClient(URL, _timeout)
+	if pqErr, ok := e.(*pq.Error); ok && pqErr.Code == "3D000" { // invalid_catalog_name
+		if e = createDatabase(URL, DB, _timeout); e != nil {
+			return nil, errors.Wrap(e, "repository.NewMigrator")
+		}
+		db, e = newNewsClient(URL, _timeout)
+	}
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewMigrator")
+	}
+	return migrations.NewMigrator(db, migrations.Postgres, timeout), nil
+}
+
+// NewReadOnlyMigrator opens a connection for reading the migration status, it never creates the database
+func NewReadOnlyMigrator(URL string, timeout int) (*migrations.Migrator, error) {
+	db, e := newNewsClient(URL, time.Duration(timeout)*time.Second)
+	if pqErr, ok := e.(*pq.Error); ok && pqErr.Code == "3D000" { // invalid_catalog_name
+		return migrations.NewMigrator(nil, migrations.Postgres, timeout), nil
+	}
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewReadOnlyMigrator")
+	}
+	return migrations.NewMigrator(db, migrations.Postgres, timeout), nil
+}
+
+// connect opens the pool used by the repositories, it refuses to connect when the schema has pending migrations
+func connect(URL string, timeout int) (*sqlx.DB, error) {
+	db, e := newNewsClient(URL, time.Duration(timeout)*time.Second)
+	if e != nil {
+		return nil, e
+	}
+	if e = migrations.NewMigrator(db, migrations.Postgres, timeout).Check(); e != nil {
+		db.Close()
+		return nil, e
+	}
+	return db, nil
+}
+
+// dialect is PostgreSQL flavour of the shared sqlx repositories
+var dialect = sqlrepo.Dialect{
+	Placeholder: sqlquery.Dollar,
+	IsDuplicate: isDuplicateEntry,
+}
+
+// NewNewsRepository refuses to start when the schema has pending migrations
+func NewNewsRepository(URL, DB string, timeout int) (repo.NewsRepository, error) {
+	db, e := connect(URL, timeout)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewNewsRepository")
+	}
+	return sqlrepo.NewNewsRepository(db, dialect, time.Duration(timeout)*time.Second), nil
+}
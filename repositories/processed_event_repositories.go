@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"context"
+)
+
+// ProcessedEventRepository remembers the idempotency key of every handled event for a limited time,
+// so an event delivered more than once is applied only once
+type ProcessedEventRepository interface {
+	IsProcessed(ctx context.Context, key string) (bool, error)
+	MarkProcessed(ctx context.Context, key string) error
+	Close() error
+}
@@ -18,3 +18,7 @@ func generateKeyScore(data m.News) (string, float64) {
 func getScore(data m.News) float64 {
 	return float64(data.Created.UnixNano())
 }
+
+func generateProcessedEventKey(key string) string {
+	return fmt.Sprintf("processed_event<>%s", key)
+}
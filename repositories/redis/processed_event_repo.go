@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+)
+
+type processedEventRedisRepository struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewProcessedEventRepository keeps every idempotency key as its own redis key expiring after ttl seconds
+func NewProcessedEventRepository(redisURL string, ttl int) (repo.ProcessedEventRepository, error) {
+	client, e := newNewsClient(redisURL)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewProcessedEventRepository")
+	}
+	return &processedEventRedisRepository{
+		client: client,
+		ttl:    time.Duration(ttl) * time.Second,
+	}, nil
+}
+
+func (r *processedEventRedisRepository) Close() error {
+	return r.client.Close()
+}
+
+func (r *processedEventRedisRepository) IsProcessed(ctx context.Context, key string) (bool, error) {
+	n, e := r.client.WithContext(ctx).Exists(generateProcessedEventKey(key)).Result()
+	if e != nil {
+		return false, errors.Wrap(e, "repository.ProcessedEvent.IsProcessed")
+	}
+	return n > 0, nil
+}
+
+func (r *processedEventRedisRepository) MarkProcessed(ctx context.Context, key string) error {
+	if e := r.client.WithContext(ctx).Set(generateProcessedEventKey(key), 1, r.ttl).Err(); e != nil {
+		return errors.Wrap(e, "repository.ProcessedEvent.MarkProcessed")
+	}
+	return nil
+}
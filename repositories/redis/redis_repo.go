@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"time"
 
 	"github.com/rinosukmandityo/maknews/helper"
@@ -41,14 +42,19 @@ func NewNewsRepository(redisURL string, expiration int) (repo.CacheRepository, e
 	return repo, nil
 }
 
-func (r *newsRedisRepository) GetBy(param m.GetPayload) ([]m.News, error) {
+func (r *newsRedisRepository) Close() error {
+	return r.client.Close()
+}
+
+func (r *newsRedisRepository) GetBy(ctx context.Context, param m.GetPayload) ([]m.News, error) {
+	client := r.client.WithContext(ctx)
 	res := []m.News{}
-	keyList, e := r.client.ZRevRange(helper.REDIS_KEY_SET, int64(param.Offset), int64(param.Offset+param.Limit)).Result()
+	keyList, e := client.ZRevRange(helper.REDIS_KEY_SET, int64(param.Offset), int64(param.Offset+param.Limit)).Result()
 	if e != nil {
 		return res, errors.Wrap(e, "repository.News.GetBy")
 	}
 	for _, key := range keyList {
-		dataRedis, e := r.client.Get(key).Result()
+		dataRedis, e := client.Get(key).Result()
 		if e != nil {
 			return res, errors.Wrap(e, "repository.News.GetBy")
 		}
@@ -66,51 +72,50 @@ func (r *newsRedisRepository) GetBy(param m.GetPayload) ([]m.News, error) {
 	return res, nil
 }
 
-func (r *newsRedisRepository) Store(data []m.News) error {
+func (r *newsRedisRepository) Store(ctx context.Context, data []m.News) error {
+	client := r.client.WithContext(ctx)
 	for _, v := range data {
 		key, score := generateKeyScore(v)
 		dataByte, e := v.MarshalBinary()
 		if e != nil {
 			return errors.Wrap(e, "repository.News.Store")
 		}
-		if _, e := r.client.Set(key, string(dataByte), 0).Result(); e != nil {
+		if _, e := client.Set(key, string(dataByte), 0).Result(); e != nil {
 			return errors.Wrap(e, "repository.News.Store")
 		}
-		r.client.Expire(key, r.expiration)
+		client.Expire(key, r.expiration)
 
-		member := &redis.Z{
+		member := redis.Z{
 			Score:  score,
 			Member: key,
 		}
-		r.client.ZAdd(helper.REDIS_KEY_SET, member)
+		client.ZAdd(helper.REDIS_KEY_SET, member)
 	}
 	return nil
 
 }
 
-func (r *newsRedisRepository) Update(data m.News) error {
+func (r *newsRedisRepository) Update(ctx context.Context, data m.News) error {
+	client := r.client.WithContext(ctx)
 	key, _ := generateKeyScore(data)
 	dataByte, e := data.MarshalBinary()
 	if e != nil {
 		return errors.Wrap(e, "repository.News.Update")
 	}
-	if _, e := r.client.Set(key, string(dataByte), 0).Result(); e != nil {
+	if _, e := client.Set(key, string(dataByte), 0).Result(); e != nil {
 		return errors.Wrap(e, "repository.News.Update")
 	}
-	r.client.Expire(key, r.expiration)
+	client.Expire(key, r.expiration)
 	return nil
 
 }
-func (r *newsRedisRepository) Delete(data m.News) error {
-	key, score := generateKeyScore(data)
-	if _, e := r.client.Del(key).Result(); e != nil {
+func (r *newsRedisRepository) Delete(ctx context.Context, data m.News) error {
+	client := r.client.WithContext(ctx)
+	key, _ := generateKeyScore(data)
+	if _, e := client.Del(key).Result(); e != nil {
 		return errors.Wrap(e, "repository.News.Delete")
 	}
-	member := &redis.Z{
-		Score:  score,
-		Member: key,
-	}
-	r.client.ZRem(helper.REDIS_KEY_SET, member)
+	client.ZRem(helper.REDIS_KEY_SET, key)
 
 	return nil
 
@@ -0,0 +1,655 @@
+// Package repotest contains the contract every NewsRepository adapter has to fulfil.
+// Each adapter runs RunNewsRepositoryContract from its own test file so the service
+// really does not care which persistence database is used.
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+
+	"github.com/pkg/errors"
+)
+
+// NewsRepositoryFactory returns the repository under test
+type NewsRepositoryFactory func(t *testing.T) repo.NewsRepository
+
+// ListContractData returns news with IDs reserved for the contract suite.
+// Created is truncated to seconds since that is the precision of the MySQL TIMESTAMP column.
+func ListContractData() []m.News {
+	created := time.Date(2020, 3, 1, 22, 59, 59, 0, time.UTC)
+	return []m.News{{
+		ID:      9001,
+		Author:  "Alex",
+		Body:    "Hello this is news from Alex",
+		Created: created,
+	}, {
+		ID:      9002,
+		Author:  "Bacca",
+		Body:    "Hello this is news from Bacca",
+		Created: created.Add(time.Second * 3),
+	}, {
+		ID:      9003,
+		Author:  "Chicarito",
+		Body:    "Hello this is news from Chicarito",
+		Created: created.Add(time.Second * 5),
+	}}
+}
+
+const missingID = -9999
+
+// ctx is used by every contract call except the cancellation case
+var ctx = context.Background()
+
+func RunNewsRepositoryContract(t *testing.T, factory NewsRepositoryFactory) {
+	t.Run("Store And GetBy", func(t *testing.T) { storeAndGetBy(t, factory) })
+	t.Run("Duplicate ID", func(t *testing.T) { duplicateID(t, factory) })
+	t.Run("Missing ID", func(t *testing.T) { missing(t, factory) })
+	t.Run("Partial Update", func(t *testing.T) { partialUpdate(t, factory) })
+	t.Run("Update Unchanged Data", func(t *testing.T) { updateUnchanged(t, factory) })
+	t.Run("Update Created", func(t *testing.T) { updateCreated(t, factory) })
+	t.Run("Update Invalid Created", func(t *testing.T) { updateInvalidCreated(t, factory) })
+	t.Run("Store Created With Offset", func(t *testing.T) { storeCreatedWithOffset(t, factory) })
+	t.Run("Created With Mixed Offsets", func(t *testing.T) { createdMixedOffsets(t, factory) })
+	t.Run("Update Ignores ID And Version", func(t *testing.T) { updateIgnoresReadOnly(t, factory) })
+	t.Run("Update Unknown Field", func(t *testing.T) { updateUnknownField(t, factory) })
+	t.Run("Unknown Filter", func(t *testing.T) { unknownFilter(t, factory) })
+	t.Run("GetBy Several Matches", func(t *testing.T) { getBySeveralMatches(t, factory) })
+	t.Run("Delete", func(t *testing.T) { deleteData(t, factory) })
+	t.Run("List", func(t *testing.T) { list(t, factory) })
+	t.Run("Get By IDs", func(t *testing.T) { getByIDs(t, factory) })
+	t.Run("Store Many", func(t *testing.T) { storeMany(t, factory) })
+	t.Run("Store Many Chunks", func(t *testing.T) { storeManyChunks(t, factory) })
+	t.Run("Store Many Cancelled", func(t *testing.T) { storeManyCancelled(t, factory) })
+	t.Run("Cancelled Context", func(t *testing.T) { cancelledContext(t, factory) })
+}
+
+// setup returns a repository holding exactly the contract data
+func setup(t *testing.T, factory NewsRepositoryFactory) repo.NewsRepository {
+	r := factory(t)
+	clean(r)
+	for _, data := range ListContractData() {
+		_data := data
+		if e := r.Store(ctx, &_data); e != nil {
+			clean(r)
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+	return r
+}
+
+func clean(r repo.NewsRepository) {
+	for _, data := range ListContractData() {
+		r.Delete(ctx, data.ID)
+	}
+}
+
+func assertNews(t *testing.T, expected, actual m.News) {
+	t.Helper()
+	if expected.ID != actual.ID || expected.Author != actual.Author || expected.Body != actual.Body {
+		t.Errorf("[ERROR] - Expected %+v but got %+v", expected, actual)
+	}
+	if !expected.Created.Equal(actual.Created) {
+		t.Errorf("[ERROR] - Expected created %s but got %s", expected.Created, actual.Created)
+	}
+}
+
+// assertCreatedNear allows the rounding of a backend that keeps created in seconds, like the MySQL TIMESTAMP column
+func assertCreatedNear(t *testing.T, expected, actual time.Time) {
+	t.Helper()
+	diff := actual.Sub(expected)
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff >= time.Second {
+		t.Errorf("[ERROR] - Expected created %s but got %s", expected, actual)
+	}
+}
+
+func assertCause(t *testing.T, expected, actual error) {
+	t.Helper()
+	if errors.Cause(actual) != expected {
+		t.Errorf("[ERROR] - It should be error '%v' but got '%v'", expected, actual)
+	}
+}
+
+func storeAndGetBy(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	for _, data := range ListContractData() {
+		res, e := r.GetBy(ctx, map[string]interface{}{"id": data.ID})
+		if e != nil {
+			t.Errorf("[ERROR] - Failed to get data %s", e.Error())
+			continue
+		}
+		assertNews(t, data, *res)
+		if res.Version != 1 {
+			t.Errorf("[ERROR] - Stored news should be version 1 but got %d", res.Version)
+		}
+	}
+}
+
+func duplicateID(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	data := ListContractData()[0]
+	duplicate := data
+	duplicate.Author = "Duplicate"
+	assertCause(t, helper.ErrDataExists, r.Store(ctx, &duplicate))
+
+	res, e := r.GetBy(ctx, map[string]interface{}{"id": data.ID})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+	}
+	assertNews(t, data, *res)
+}
+
+func missing(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	_, e := r.GetBy(ctx, map[string]interface{}{"id": missingID})
+	assertCause(t, helper.ErrDataNotFound, e)
+
+	_, e = r.Update(ctx, map[string]interface{}{"author": "Data Not Exists"}, missingID)
+	assertCause(t, helper.ErrDataNotFound, e)
+
+	assertCause(t, helper.ErrDataNotFound, r.Delete(ctx, missingID))
+}
+
+func partialUpdate(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	expected := ListContractData()[0]
+	expected.Author += "UPDATED"
+	res, e := r.Update(ctx, map[string]interface{}{"author": expected.Author}, expected.ID)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+	}
+	assertNews(t, expected, *res)
+	if res.Version != 2 {
+		t.Errorf("[ERROR] - Updated news should be version 2 but got %d", res.Version)
+	}
+
+	res, e = r.GetBy(ctx, map[string]interface{}{"id": expected.ID})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+	}
+	assertNews(t, expected, *res)
+	if res.Version != 2 {
+		t.Errorf("[ERROR] - Updated news should be version 2 but got %d", res.Version)
+	}
+
+	// other rows are untouched
+	other := ListContractData()[1]
+	res, e = r.GetBy(ctx, map[string]interface{}{"id": other.ID})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+	}
+	assertNews(t, other, *res)
+}
+
+func updateUnchanged(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	expected := ListContractData()[0]
+	res, e := r.Update(ctx, map[string]interface{}{"author": expected.Author}, expected.ID)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+	}
+	assertNews(t, expected, *res)
+}
+
+func updateCreated(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	data := ListContractData()[0]
+	created := data.Created.Add(time.Hour * 24)
+	testdata := []struct {
+		name    string
+		created string
+		exact   bool
+	}{
+		{"UTC", created.Format("2006-01-02T15:04:05Z"), true},
+		{"Offset", created.Add(time.Hour).In(time.FixedZone("WIB", 7*60*60)).Format(time.RFC3339), true},
+		{"Fractional Seconds", created.Add(time.Hour*2 + time.Millisecond*123).Format(time.RFC3339Nano), false},
+		{"Offset And Nanoseconds", created.Add(time.Hour*3 + 123456789).In(time.FixedZone("EST", -5*60*60)).Format(time.RFC3339Nano), false},
+	}
+	for _, tc := range testdata {
+		t.Run(tc.name, func(t *testing.T) {
+			expected := data
+			expected.Created, _ = time.Parse(time.RFC3339Nano, tc.created)
+			res, e := r.Update(ctx, map[string]interface{}{"created": tc.created}, expected.ID)
+			if e != nil {
+				t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+			}
+			got, e := r.GetBy(ctx, map[string]interface{}{"id": expected.ID})
+			if e != nil {
+				t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+			}
+			if tc.exact {
+				assertNews(t, expected, *res)
+				assertNews(t, expected, *got)
+				return
+			}
+			assertCreatedNear(t, expected.Created, res.Created)
+			assertCreatedNear(t, expected.Created, got.Created)
+		})
+	}
+}
+
+func updateInvalidCreated(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	expected := ListContractData()[0]
+	_, e := r.Update(ctx, map[string]interface{}{"created": "01/03/2020 22:59:59"}, expected.ID)
+	assertCause(t, helper.ErrDataInvalid, e)
+
+	res, e := r.GetBy(ctx, map[string]interface{}{"id": expected.ID})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+	}
+	assertNews(t, expected, *res)
+}
+
+func storeCreatedWithOffset(t *testing.T, factory NewsRepositoryFactory) {
+	r := factory(t)
+	clean(r)
+	defer clean(r)
+
+	data := ListContractData()[0]
+	data.Created = data.Created.Add(123456789).In(time.FixedZone("WIB", 7*60*60))
+	stored := data
+	if e := r.Store(ctx, &stored); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+	res, e := r.GetBy(ctx, map[string]interface{}{"id": data.ID})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+	}
+	assertCreatedNear(t, data.Created, res.Created)
+}
+
+// createdMixedOffsets compares created as instants, 12:00Z is written as 19:00+07:00 between 10:00Z and 15:00Z
+func createdMixedOffsets(t *testing.T, factory NewsRepositoryFactory) {
+	r := factory(t)
+	clean(r)
+	defer clean(r)
+
+	data := ListContractData()
+	day := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	data[0].Created = day.Add(time.Hour * 10)
+	data[1].Created = day.Add(time.Hour * 12).In(time.FixedZone("WIB", 7*60*60))
+	data[2].Created = day.Add(time.Hour * 15)
+	for _, v := range data {
+		stored := v
+		if e := r.Store(ctx, &stored); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+
+	res, e := r.List(ctx, m.GetPayload{Limit: 1000, Order: map[string]bool{"created": false}})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to list data %s", e.Error())
+	}
+	index := contractIndex(res)
+	if len(index) != len(data) {
+		t.Fatalf("[ERROR] - Expected %d contract data but got %d", len(data), len(index))
+	}
+	for i, pos := range index {
+		assertNews(t, data[len(data)-1-i], res[pos])
+	}
+
+	for _, created := range []string{"2020-03-01T12:00:00Z", "2020-03-01T19:00:00+07:00"} {
+		got, e := r.GetBy(ctx, map[string]interface{}{"created": created})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to get data created %s %s", created, e.Error())
+		}
+		assertNews(t, data[1], *got)
+	}
+}
+
+func updateIgnoresReadOnly(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	expected := ListContractData()[0]
+	expected.Author += "UPDATED"
+	res, e := r.Update(ctx, map[string]interface{}{"id": float64(missingID), "version": 100, "author": expected.Author}, expected.ID)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+	}
+	assertNews(t, expected, *res)
+	if res.Version != 2 {
+		t.Errorf("[ERROR] - Version should only be increased by the update but got %d", res.Version)
+	}
+
+	res, e = r.GetBy(ctx, map[string]interface{}{"id": expected.ID})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+	}
+	assertNews(t, expected, *res)
+	_, e = r.GetBy(ctx, map[string]interface{}{"id": missingID})
+	assertCause(t, helper.ErrDataNotFound, e)
+
+	// nothing is left to change
+	_, e = r.Update(ctx, map[string]interface{}{"id": expected.ID}, expected.ID)
+	assertCause(t, helper.ErrDataInvalid, e)
+}
+
+func updateUnknownField(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	expected := ListContractData()[0]
+	_, e := r.Update(ctx, map[string]interface{}{"author": expected.Author + "UPDATED", "unknown": "value"}, expected.ID)
+	assertCause(t, helper.ErrDataInvalid, e)
+
+	// nothing is changed by a rejected update
+	res, e := r.GetBy(ctx, map[string]interface{}{"id": expected.ID})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+	}
+	assertNews(t, expected, *res)
+	if res.Version != 1 {
+		t.Errorf("[ERROR] - Rejected update should keep version 1 but got %d", res.Version)
+	}
+}
+
+func unknownFilter(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	filter := map[string]interface{}{"unknown": "value"}
+	_, e := r.GetBy(ctx, filter)
+	assertCause(t, helper.ErrDataInvalid, e)
+
+	_, e = r.List(ctx, m.GetPayload{Filter: filter})
+	assertCause(t, helper.ErrDataInvalid, e)
+}
+
+func getBySeveralMatches(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	// every news matches, the oldest one is stored last and ties are broken by id
+	data := ListContractData()
+	oldest := data[0].Created.Add(-time.Hour).Format(time.RFC3339)
+	for _, v := range data[1:] {
+		if _, e := r.Update(ctx, map[string]interface{}{"author": data[0].Author, "created": oldest}, v.ID); e != nil {
+			t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+		}
+	}
+	for i := 0; i < 5; i++ {
+		res, e := r.GetBy(ctx, map[string]interface{}{"author": data[0].Author})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+		}
+		if res.ID != data[1].ID {
+			t.Fatalf("[ERROR] - It should return the oldest news %d but got %d", data[1].ID, res.ID)
+		}
+	}
+}
+
+func deleteData(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	data := ListContractData()[1]
+	if e := r.Delete(ctx, data.ID); e != nil {
+		t.Fatalf("[ERROR] - Failed to delete data %s", e.Error())
+	}
+	_, e := r.GetBy(ctx, map[string]interface{}{"id": data.ID})
+	assertCause(t, helper.ErrDataNotFound, e)
+	assertCause(t, helper.ErrDataNotFound, r.Delete(ctx, data.ID))
+
+	// other rows are untouched
+	for _, other := range []m.News{ListContractData()[0], ListContractData()[2]} {
+		if _, e := r.GetBy(ctx, map[string]interface{}{"id": other.ID}); e != nil {
+			t.Errorf("[ERROR] - Failed to get data %s", e.Error())
+		}
+	}
+}
+
+// contractIndex returns position of contract data inside res, other rows may exist in a shared database
+func contractIndex(res []m.News) []int {
+	index := []int{}
+	for i, v := range res {
+		for _, data := range ListContractData() {
+			if v.ID == data.ID {
+				index = append(index, i)
+			}
+		}
+	}
+	return index
+}
+
+func list(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	expected := ListContractData()
+	t.Run("Order By Created Descending", func(t *testing.T) {
+		res, e := r.List(ctx, m.GetPayload{Limit: 1000, Order: map[string]bool{"created": false}})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to list data %s", e.Error())
+		}
+		index := contractIndex(res)
+		if len(index) != len(expected) {
+			t.Fatalf("[ERROR] - Expected %d contract data but got %d", len(expected), len(index))
+		}
+		for i, pos := range index {
+			assertNews(t, expected[len(expected)-1-i], res[pos])
+		}
+	})
+	t.Run("Offset And Limit", func(t *testing.T) {
+		all, e := r.List(ctx, m.GetPayload{Limit: 1000, Order: map[string]bool{"created": true}})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to list data %s", e.Error())
+		}
+		res, e := r.List(ctx, m.GetPayload{Offset: 1, Limit: 1, Order: map[string]bool{"created": true}})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to list data %s", e.Error())
+		}
+		if len(all) < 2 || len(res) != 1 {
+			t.Fatalf("[ERROR] - Expected 1 data but got %d", len(res))
+		}
+		assertNews(t, all[1], res[0])
+	})
+	t.Run("Filter", func(t *testing.T) {
+		res, e := r.List(ctx, m.GetPayload{Filter: map[string]interface{}{"id": expected[1].ID}})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to list data %s", e.Error())
+		}
+		if len(res) != 1 {
+			t.Fatalf("[ERROR] - Expected 1 data but got %d", len(res))
+		}
+		assertNews(t, expected[1], res[0])
+	})
+	t.Run("Empty Result", func(t *testing.T) {
+		res, e := r.List(ctx, m.GetPayload{Filter: map[string]interface{}{"id": missingID}})
+		if e != nil || len(res) != 0 {
+			t.Errorf("[ERROR] - Expected empty data but got %v %v", res, e)
+		}
+	})
+	t.Run("After ID", func(t *testing.T) {
+		after := expected[0].ID
+		res, e := r.List(ctx, m.GetPayload{Limit: 1000, Order: map[string]bool{"id": true}, AfterID: &after})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to list data %s", e.Error())
+		}
+		index := contractIndex(res)
+		if len(index) != 2 {
+			t.Fatalf("[ERROR] - Expected 2 contract data but got %d", len(index))
+		}
+		assertNews(t, expected[1], res[index[0]])
+		assertNews(t, expected[2], res[index[1]])
+		for _, v := range res {
+			if v.ID <= after {
+				t.Errorf("[ERROR] - News %d is not after %d", v.ID, after)
+			}
+		}
+	})
+	t.Run("Unknown Order", func(t *testing.T) {
+		_, e := r.List(ctx, m.GetPayload{Order: map[string]bool{"unknown": true}})
+		assertCause(t, helper.ErrDataInvalid, e)
+	})
+}
+
+func getByIDs(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+
+	expected := ListContractData()
+	t.Run("Keep Order", func(t *testing.T) {
+		res, e := r.GetByIDs(ctx, []int{expected[2].ID, expected[0].ID, expected[1].ID})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+		}
+		if len(res) != 3 {
+			t.Fatalf("[ERROR] - Expected 3 data but got %d", len(res))
+		}
+		assertNews(t, expected[2], res[0])
+		assertNews(t, expected[0], res[1])
+		assertNews(t, expected[1], res[2])
+	})
+	t.Run("Partial Data", func(t *testing.T) {
+		res, e := r.GetByIDs(ctx, []int{missingID, expected[1].ID})
+		partial, ok := errors.Cause(e).(*helper.PartialDataError)
+		if !ok || len(partial.Missing) != 1 || partial.Missing[0] != missingID {
+			t.Errorf("[ERROR] - It should be partial data error but got '%v'", e)
+		}
+		if len(res) != 1 {
+			t.Fatalf("[ERROR] - Expected 1 data but got %d", len(res))
+		}
+		assertNews(t, expected[1], res[0])
+	})
+	t.Run("Empty IDs", func(t *testing.T) {
+		res, e := r.GetByIDs(ctx, []int{})
+		if e != nil || len(res) != 0 {
+			t.Errorf("[ERROR] - Expected empty data but got %v %v", res, e)
+		}
+	})
+}
+
+func storeMany(t *testing.T, factory NewsRepositoryFactory) {
+	r := factory(t)
+	clean(r)
+	defer clean(r)
+
+	data := ListContractData()
+	existing := data[1]
+	if e := r.Store(ctx, &existing); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+
+	res, e := r.StoreMany(ctx, data)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+	if len(res) != len(data) {
+		t.Fatalf("[ERROR] - Expected %d result but got %d", len(data), len(res))
+	}
+	assertCause(t, helper.ErrDataExists, res[1])
+	for _, i := range []int{0, 2} {
+		if res[i] != nil {
+			t.Errorf("[ERROR] - Failed to save data %s", res[i].Error())
+		}
+		got, e := r.GetBy(ctx, map[string]interface{}{"id": data[i].ID})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+		}
+		assertNews(t, data[i], *got)
+	}
+}
+
+// storeManyChunks stores more news than one chunk of the SQL adapters
+func storeManyChunks(t *testing.T, factory NewsRepositoryFactory) {
+	r := factory(t)
+	created := ListContractData()[0].Created
+	data := []m.News{}
+	for id := 9100; id < 9350; id++ {
+		data = append(data, m.News{ID: id, Author: "Chunk", Body: "Hello this is news from Chunk", Created: created})
+	}
+	defer func() {
+		for _, v := range data {
+			r.Delete(ctx, v.ID)
+		}
+	}()
+	// one duplicate inside a chunk must not roll back its neighbours
+	existing := data[150]
+	if e := r.Store(ctx, &existing); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+
+	res, e := r.StoreMany(ctx, data)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+	for i, v := range data {
+		if i == 150 {
+			assertCause(t, helper.ErrDataExists, res[i])
+			continue
+		}
+		if res[i] != nil {
+			t.Fatalf("[ERROR] - Failed to save data %d %s", v.ID, res[i].Error())
+		}
+	}
+	stored, e := r.List(ctx, m.GetPayload{Filter: map[string]interface{}{"author": "Chunk"}, Limit: len(data) + 1})
+	if e != nil || len(stored) != len(data) {
+		t.Errorf("[ERROR] - Expected %d data but got %d %v", len(data), len(stored), e)
+	}
+}
+
+func storeManyCancelled(t *testing.T, factory NewsRepositoryFactory) {
+	r := factory(t)
+	clean(r)
+	defer clean(r)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	data := ListContractData()
+	res, e := r.StoreMany(cancelled, data)
+	if e == nil {
+		t.Fatalf("[ERROR] - StoreMany should fail with cancelled context")
+	}
+	for i, v := range data {
+		if i >= len(res) || res[i] == nil {
+			t.Errorf("[ERROR] - News %d should get the batch error", v.ID)
+		}
+		if _, e := r.GetBy(ctx, map[string]interface{}{"id": v.ID}); errors.Cause(e) != helper.ErrDataNotFound {
+			t.Errorf("[ERROR] - News %d should not be stored %v", v.ID, e)
+		}
+	}
+}
+
+func cancelledContext(t *testing.T, factory NewsRepositoryFactory) {
+	r := setup(t, factory)
+	defer clean(r)
+	data := ListContractData()[0]
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, e := r.GetBy(cancelled, map[string]interface{}{"id": data.ID}); e == nil {
+		t.Errorf("[ERROR] - GetBy should fail with cancelled context")
+	}
+	if _, e := r.List(cancelled, m.GetPayload{}); e == nil {
+		t.Errorf("[ERROR] - List should fail with cancelled context")
+	}
+	if e := r.Delete(cancelled, data.ID); e == nil {
+		t.Errorf("[ERROR] - Delete should fail with cancelled context")
+	}
+	if _, e := r.GetBy(ctx, map[string]interface{}{"id": data.ID}); e != nil {
+		t.Errorf("[ERROR] - Data should not be deleted with cancelled context %s", e.Error())
+	}
+}
@@ -0,0 +1,178 @@
+package repotest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+)
+
+// RunOutboxContract checks that NewsRepository.Store, Update and Delete write the outbox event read by outboxRepo.
+// Every pending message is marked sent first, so run it against a test database. Messages are read by claiming them
+// with a lease in the past, which leaves them claimable for the next read.
+func RunOutboxContract(t *testing.T, newsRepo repo.NewsRepository, outboxRepo repo.OutboxRepository) {
+	clean(newsRepo)
+	defer clean(newsRepo)
+	drain(t, outboxRepo)
+
+	data := ListContractData()
+	for i := range data {
+		if e := newsRepo.Store(ctx, &data[i]); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+	duplicate := data[0]
+	assertCause(t, helper.ErrDataExists, newsRepo.Store(ctx, &duplicate))
+
+	t.Run("Unsent", func(t *testing.T) {
+		res, e := outboxRepo.Claim(ctx, 100, -time.Minute)
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to get pending message %s", e.Error())
+		}
+		if len(res) != len(data) {
+			t.Fatalf("[ERROR] - Expected %d pending message but got %d", len(data), len(res))
+		}
+		for i, v := range res {
+			if v.NewsID != data[i].ID || v.ID == 0 {
+				t.Errorf("[ERROR] - Incorrect pending message %+v", v)
+			}
+			assertEvent(t, v, m.NewsCreated, data[i])
+		}
+	})
+	t.Run("Limit", func(t *testing.T) {
+		res, e := outboxRepo.Claim(ctx, 1, -time.Minute)
+		if e != nil || len(res) != 1 || res[0].NewsID != data[0].ID {
+			t.Errorf("[ERROR] - Expected oldest pending message but got %v %v", res, e)
+		}
+	})
+	t.Run("Mark Sent", func(t *testing.T) {
+		res, e := outboxRepo.Claim(ctx, 1, -time.Minute)
+		if e != nil || len(res) != 1 {
+			t.Fatalf("[ERROR] - Failed to get pending message %v %v", res, e)
+		}
+		if e := outboxRepo.MarkSent(ctx, []int64{res[0].ID}); e != nil {
+			t.Fatalf("[ERROR] - Failed to mark message sent %s", e.Error())
+		}
+		res, e = outboxRepo.Claim(ctx, 100, -time.Minute)
+		if e != nil || len(res) != len(data)-1 || res[0].NewsID != data[1].ID {
+			t.Errorf("[ERROR] - Sent message should never be claimed %v %v", res, e)
+		}
+	})
+	t.Run("Claim", func(t *testing.T) {
+		drain(t, outboxRepo)
+		for i := range data {
+			data[i].Author = "claimed author"
+			if _, e := newsRepo.Update(ctx, map[string]interface{}{"author": data[i].Author}, data[i].ID); e != nil {
+				t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+			}
+		}
+		first, e := outboxRepo.Claim(ctx, 2, time.Minute)
+		if e != nil || len(first) != 2 || first[0].NewsID != data[0].ID || first[1].NewsID != data[1].ID {
+			t.Fatalf("[ERROR] - Expected the 2 oldest messages but got %v %v", first, e)
+		}
+		second, e := outboxRepo.Claim(ctx, 100, time.Minute)
+		if e != nil || len(second) != 1 || second[0].NewsID != data[2].ID {
+			t.Fatalf("[ERROR] - Claimed messages should be skipped %v %v", second, e)
+		}
+		if res, e := outboxRepo.Claim(ctx, 100, time.Minute); e != nil || len(res) != 0 {
+			t.Errorf("[ERROR] - Every message is claimed already %v %v", res, e)
+		}
+		ids := []int64{first[0].ID, first[1].ID, second[0].ID}
+		if e := outboxRepo.MarkSent(ctx, ids); e != nil {
+			t.Fatalf("[ERROR] - Failed to mark message sent %s", e.Error())
+		}
+	})
+	t.Run("Expired Claim", func(t *testing.T) {
+		drain(t, outboxRepo)
+		if _, e := newsRepo.Update(ctx, map[string]interface{}{"author": "expired author"}, data[0].ID); e != nil {
+			t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+		}
+		// a lease in the past behaves like a relay that crashed long ago
+		if res, e := outboxRepo.Claim(ctx, 100, -time.Minute); e != nil || len(res) != 1 {
+			t.Fatalf("[ERROR] - Failed to claim message %v %v", res, e)
+		}
+		res, e := outboxRepo.Claim(ctx, 100, time.Minute)
+		if e != nil || len(res) != 1 || res[0].NewsID != data[0].ID {
+			t.Fatalf("[ERROR] - Expired claim should be claimed again %v %v", res, e)
+		}
+		if e := outboxRepo.MarkSent(ctx, []int64{res[0].ID}); e != nil {
+			t.Fatalf("[ERROR] - Failed to mark message sent %s", e.Error())
+		}
+		if res, e := outboxRepo.Claim(ctx, 100, -time.Minute); e != nil || len(res) != 0 {
+			t.Errorf("[ERROR] - Sent message should never be claimed %v %v", res, e)
+		}
+	})
+	t.Run("Changed Since", func(t *testing.T) {
+		// a database may keep created in seconds, the earlier messages are older than since anyway
+		time.Sleep(time.Second * 2)
+		since := time.Now().Add(-time.Second)
+		for _, i := range []int{2, 0, 2} {
+			if _, e := newsRepo.Update(ctx, map[string]interface{}{"author": "changed author"}, data[i].ID); e != nil {
+				t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+			}
+			data[i].Author = "changed author"
+		}
+		res, e := outboxRepo.ChangedSince(ctx, since)
+		if e != nil || len(res) != 2 || res[0] != data[0].ID || res[1] != data[2].ID {
+			t.Errorf("[ERROR] - Expected news %d and %d once each but got %v %v", data[0].ID, data[2].ID, res, e)
+		}
+		if res, e := outboxRepo.ChangedSince(ctx, time.Now().Add(time.Hour)); e != nil || len(res) != 0 {
+			t.Errorf("[ERROR] - Expected no news changed in the future but got %v %v", res, e)
+		}
+	})
+	t.Run("Update And Delete", func(t *testing.T) {
+		drain(t, outboxRepo)
+		updated, e := newsRepo.Update(ctx, map[string]interface{}{"author": "updated author"}, data[0].ID)
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+		}
+		if e := newsRepo.Delete(ctx, data[1].ID); e != nil {
+			t.Fatalf("[ERROR] - Failed to delete data %s", e.Error())
+		}
+		_, e = newsRepo.Update(ctx, map[string]interface{}{"author": "updated author"}, data[1].ID)
+		assertCause(t, helper.ErrDataNotFound, e)
+		assertCause(t, helper.ErrDataNotFound, newsRepo.Delete(ctx, data[1].ID))
+
+		res, e := outboxRepo.Claim(ctx, 100, -time.Minute)
+		if e != nil || len(res) != 2 {
+			t.Fatalf("[ERROR] - Expected updated and deleted event but got %v %v", res, e)
+		}
+		assertEvent(t, res[0], m.NewsUpdated, *updated)
+		assertEvent(t, res[1], m.NewsDeleted, data[1])
+	})
+}
+
+// assertEvent checks that outbox carries event of eventType with expected as payload
+func assertEvent(t *testing.T, outbox m.Outbox, eventType m.EventType, expected m.News) {
+	event := m.Event{}
+	if e := json.Unmarshal(outbox.Payload, &event); e != nil {
+		t.Fatalf("[ERROR] - Invalid payload %s", e.Error())
+	}
+	if outbox.NewsID != expected.ID || event.Type != eventType || event.ID == "" ||
+		event.SchemaVersion != m.EventSchemaVersion || event.OccurredAt.IsZero() {
+		t.Errorf("[ERROR] - Expected %s event of news %d but got %+v", eventType, expected.ID, event)
+	}
+	assertNews(t, expected, event.Payload)
+}
+
+func drain(t *testing.T, outboxRepo repo.OutboxRepository) {
+	for {
+		res, e := outboxRepo.Claim(ctx, 100, -time.Minute)
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to claim message %s", e.Error())
+		}
+		if len(res) == 0 {
+			return
+		}
+		ids := make([]int64, len(res))
+		for i, v := range res {
+			ids[i] = v.ID
+		}
+		if e := outboxRepo.MarkSent(ctx, ids); e != nil {
+			t.Fatalf("[ERROR] - Failed to mark message sent %s", e.Error())
+		}
+	}
+}
@@ -1,12 +1,20 @@
 package repositories
 
 import (
+	"context"
+
 	m "github.com/rinosukmandityo/maknews/models"
 )
 
 type NewsRepository interface {
-	GetBy(filter map[string]interface{}) (*m.News, error)
-	Store(data *m.News) error
-	Update(data map[string]interface{}, id int) (*m.News, error)
-	Delete(id int) error
+	GetBy(ctx context.Context, filter map[string]interface{}) (*m.News, error)
+	List(ctx context.Context, param m.GetPayload) ([]m.News, error)
+	GetByIDs(ctx context.Context, ids []int) ([]m.News, error)
+	Store(ctx context.Context, data *m.News) error
+	// StoreMany returns one error per data (nil when stored). A failure that stops the batch, like a lost connection,
+	// is returned as the second error and every data that is not stored gets it too.
+	StoreMany(ctx context.Context, data []m.News) ([]error, error)
+	Update(ctx context.Context, data map[string]interface{}, id int) (*m.News, error)
+	Delete(ctx context.Context, id int) error
+	Close() error
 }
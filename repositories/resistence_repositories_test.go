@@ -3,6 +3,7 @@
 package repositories_test
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -10,10 +11,12 @@ import (
 	"time"
 
 	_ "github.com/rinosukmandityo/maknews/api"
+	"github.com/rinosukmandityo/maknews/config"
 	"github.com/rinosukmandityo/maknews/helper"
 	m "github.com/rinosukmandityo/maknews/models"
 	. "github.com/rinosukmandityo/maknews/repositories"
 	rh "github.com/rinosukmandityo/maknews/repositories/helper"
+	"github.com/rinosukmandityo/maknews/repositories/repotest"
 )
 
 /*
@@ -28,20 +31,21 @@ import (
 	=======
 	MySQL
 	=======
-	set url=root:Password.1@tcp(127.0.0.1:3306)/news
-	set timeout=10
-	set db=news
-	set driver=mysql
+	set MAKNEWS_DATABASE_URL=root:Password.1@tcp(127.0.0.1:3306)/news
+	set MAKNEWS_DATABASE_TIMEOUT=10s
+	set MAKNEWS_DATABASE_NAME=news
+	set MAKNEWS_DATABASE_DRIVER=mysql
 	=======
 	MongoDB
 	=======
-	set url=mongodb://localhost:27017/local
-	set timeout=10
-	set db=local
-	set driver=mongo
+	set MAKNEWS_DATABASE_URL=mongodb://localhost:27017/local
+	set MAKNEWS_DATABASE_TIMEOUT=10s
+	set MAKNEWS_DATABASE_NAME=news
+	set MAKNEWS_DATABASE_DRIVER=mongo
 */
 
 var (
+	ctx  = context.Background()
 	repo NewsRepository
 )
 
@@ -75,7 +79,13 @@ func ListTestData() []m.News {
 }
 
 func init() {
-	repo = rh.ChooseRepo()
+	cfg, e := config.Load("")
+	if e != nil {
+		panic(e)
+	}
+	if repo, e = rh.ChooseRepo(cfg); e != nil {
+		panic(e)
+	}
 }
 
 func TestService(t *testing.T) {
@@ -86,6 +96,12 @@ func TestService(t *testing.T) {
 	t.Run("Get Data", GetData)
 }
 
+func TestNewsRepositoryContract(t *testing.T) {
+	repotest.RunNewsRepositoryContract(t, func(t *testing.T) NewsRepository {
+		return repo
+	})
+}
+
 func InsertData(t *testing.T) {
 	tts := []TestTable{
 		{
@@ -102,15 +118,15 @@ func InsertData(t *testing.T) {
 
 		// Clean test data if any
 		for _, data := range testdata {
-			repo.Delete(data.ID)
+			repo.Delete(ctx, data.ID)
 		}
 
 		for _, data := range testdata {
 			t.Run(tt.name, func(t *testing.T) {
-				if e := repo.Store(&data); e != tt.expectedErr {
+				if e := repo.Store(ctx, &data); e != tt.expectedErr {
 					t.Errorf("%s %s ", tt.errMsg, e.Error())
 				}
-				if res, e := repo.GetBy(map[string]interface{}{
+				if res, e := repo.GetBy(ctx, map[string]interface{}{
 					"id": data.ID,
 				}); e != nil || res.ID == 0 {
 					t.Errorf("[ERROR] - Failed to get data")
@@ -143,7 +159,7 @@ func UpdateData(t *testing.T) {
 	for _, tt := range tts {
 		for _, data := range tt.data {
 			t.Run(tt.name, func(t *testing.T) {
-				if _, e := repo.Update(tt.updatedData, data.ID); e != tt.expectedErr {
+				if _, e := repo.Update(ctx, tt.updatedData, data.ID); e != tt.expectedErr {
 					if !strings.Contains(e.Error(), tt.expectedErr.Error()) {
 						t.Errorf("%s %s ", tt.errMsg, e.Error())
 					}
@@ -174,7 +190,7 @@ func DeleteData(t *testing.T) {
 	for _, tt := range tts {
 		for _, data := range tt.data {
 			t.Run(tt.name, func(t *testing.T) {
-				if e := repo.Delete(data.ID); e != tt.expectedErr {
+				if e := repo.Delete(ctx, data.ID); e != tt.expectedErr {
 					if !strings.Contains(e.Error(), tt.expectedErr.Error()) {
 						t.Errorf("%s %s ", tt.errMsg, e.Error())
 					}
@@ -203,7 +219,7 @@ func GetData(t *testing.T) {
 	}
 
 	for _, tt := range tts {
-		if _, e := repo.GetBy(tt.filter); e != tt.expectedErr {
+		if _, e := repo.GetBy(ctx, tt.filter); e != tt.expectedErr {
 			t.Run(tt.name, func(t *testing.T) {
 				if !strings.Contains(e.Error(), tt.expectedErr.Error()) {
 					t.Errorf("%s %s ", tt.errMsg, e.Error())
@@ -218,7 +234,7 @@ func DeleteAll(t *testing.T) {
 	for _, data := range ListTestData() {
 		wg.Add(1)
 		go func(_data m.News) {
-			repo.Delete(_data.ID)
+			repo.Delete(ctx, _data.ID)
 			wg.Done()
 		}(data)
 	}
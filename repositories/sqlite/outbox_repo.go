@@ -0,0 +1,19 @@
+package sqlite
+
+import (
+	"time"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	"github.com/rinosukmandityo/maknews/repositories/sqlrepo"
+
+	"github.com/pkg/errors"
+)
+
+// NewOutboxRepository opens the same sqlite database file as NewNewsRepository, it is used by the outbox relay
+func NewOutboxRepository(path string, timeout int) (repo.OutboxRepository, error) {
+	db, e := connect(path, timeout)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewOutboxRepository")
+	}
+	return sqlrepo.NewOutboxRepository(db, dialect, time.Duration(timeout)*time.Second), nil
+}
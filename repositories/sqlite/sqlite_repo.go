@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	"github.com/rinosukmandityo/maknews/repositories/migrations"
+	"github.com/rinosukmandityo/maknews/repositories/sqlquery"
+	"github.com/rinosukmandityo/maknews/repositories/sqlrepo"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// dsn appends the connection options to path, path may already carry its own options like "file:news.db?mode=rwc"
+func dsn(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + "_busy_timeout=5000&_foreign_keys=on"
+}
+
+func newNewsClient(path string) (*sqlx.DB, error) {
+	db, e := sqlx.Open("sqlite3", dsn(path))
+	if e != nil {
+		return nil, e
+	}
+	// sqlite only allows one writer at a time, sharing one connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+	if e = db.Ping(); e != nil {
+		db.Close()
+		return nil, e
+	}
+	return db, nil
+}
+
+func isDuplicateEntry(e error) bool {
+	sqliteErr, ok := e.(sqlite3.Error)
+	return ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// NewMigrator opens (or creates) the sqlite database file in path for schema migration
+func NewMigrator(path string, timeout int) (*migrations.Migrator, error) {
+	db, e := newNewsClient(path)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewMigrator")
+	}
+	return migrations.NewMigrator(db, migrations.SQLite, timeout), nil
+}
+
+// NewReadOnlyMigrator opens the sqlite database file in path for reading the migration status,
+// a plain path to a file that does not exist yet is not created
+func NewReadOnlyMigrator(path string, timeout int) (*migrations.Migrator, error) {
+	if _, e := os.Stat(path); os.IsNotExist(e) && !strings.HasPrefix(path, "file:") {
+		return migrations.NewMigrator(nil, migrations.SQLite, timeout), nil
+	}
+	db, e := newNewsClient(path)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewReadOnlyMigrator")
+	}
+	return migrations.NewMigrator(db, migrations.SQLite, timeout), nil
+}
+
+// connect opens the sqlite database file in path, it refuses to connect when the schema has pending migrations
+func connect(path string, timeout int) (*sqlx.DB, error) {
+	db, e := newNewsClient(path)
+	if e != nil {
+		return nil, e
+	}
+	if e = migrations.NewMigrator(db, migrations.SQLite, timeout).Check(); e != nil {
+		db.Close()
+		return nil, e
+	}
+	return db, nil
+}
+
+// dialect is SQLite flavour of the shared sqlx repositories
+var dialect = sqlrepo.Dialect{
+	Placeholder: sqlquery.Question,
+	IsDuplicate: isDuplicateEntry,
+}
+
+// NewNewsRepository opens the sqlite database file in path, it refuses to start when the schema has pending migrations
+func NewNewsRepository(path string, timeout int) (repo.NewsRepository, error) {
+	db, e := connect(path, timeout)
+	if e != nil {
+		return nil, errors.Wrap(e, "repository.NewNewsRepository")
+	}
+	return sqlrepo.NewNewsRepository(db, dialect, time.Duration(timeout)*time.Second), nil
+}
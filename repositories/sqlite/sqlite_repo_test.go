@@ -0,0 +1,212 @@
+package sqlite_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	"github.com/rinosukmandityo/maknews/repositories/migrations"
+	"github.com/rinosukmandityo/maknews/repositories/repotest"
+	sl "github.com/rinosukmandityo/maknews/repositories/sqlite"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v
+*/
+
+func tempDB(t *testing.T) (string, func()) {
+	dir, e := ioutil.TempDir("", "maknews")
+	if e != nil {
+		t.Fatal(e)
+	}
+	return filepath.Join(dir, "news.db"), func() { os.RemoveAll(dir) }
+}
+
+func migrateUp(t *testing.T, path string) {
+	migrator, e := sl.NewMigrator(path, 10)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer migrator.Close()
+	if _, e := migrator.Up(); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestNewsRepositoryContract(t *testing.T) {
+	path, clean := tempDB(t)
+	defer clean()
+	migrateUp(t, path)
+
+	newsRepo, e := sl.NewNewsRepository(path, 10)
+	if e != nil {
+		t.Fatal(e)
+	}
+	repotest.RunNewsRepositoryContract(t, func(t *testing.T) repo.NewsRepository {
+		return newsRepo
+	})
+}
+
+func TestOutboxContract(t *testing.T) {
+	path, clean := tempDB(t)
+	defer clean()
+	migrateUp(t, path)
+
+	newsRepo, e := sl.NewNewsRepository(path, 10)
+	if e != nil {
+		t.Fatal(e)
+	}
+	outboxRepo, e := sl.NewOutboxRepository(path, 10)
+	if e != nil {
+		t.Fatal(e)
+	}
+	repotest.RunOutboxContract(t, newsRepo, outboxRepo)
+}
+
+func TestPathWithOptions(t *testing.T) {
+	path, clean := tempDB(t)
+	defer clean()
+	path = "file:" + path + "?mode=rwc"
+	migrateUp(t, path)
+
+	newsRepo, e := sl.NewNewsRepository(path, 10)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to open path with options %s", e.Error())
+	}
+	newsRepo.Close()
+}
+
+func TestMigration(t *testing.T) {
+	path, clean := tempDB(t)
+	defer clean()
+
+	t.Run("Case: Refuse Unmigrated Schema", func(t *testing.T) {
+		if _, e := sl.NewNewsRepository(path, 10); errors.Cause(e) != migrations.ErrSchemaOutdated {
+			t.Errorf("[ERROR] - It should be error '%s' %v", migrations.ErrSchemaOutdated.Error(), e)
+		}
+	})
+
+	migrator, e := sl.NewMigrator(path, 10)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer migrator.Close()
+
+	t.Run("Case: Up", func(t *testing.T) {
+		applied, e := migrator.Up()
+		if e != nil || len(applied) != len(migrations.SQLite) {
+			t.Errorf("[ERROR] - Failed to migrate up %v %v", applied, e)
+		}
+		if applied, e := migrator.Up(); e != nil || len(applied) != 0 {
+			t.Errorf("[ERROR] - Migrate up should be idempotent %v %v", applied, e)
+		}
+		if e := migrator.Check(); e != nil {
+			t.Errorf("[ERROR] - Schema should be up to date %s", e.Error())
+		}
+	})
+	t.Run("Case: Status", func(t *testing.T) {
+		status, e := migrator.Status()
+		if e != nil || len(status) != len(migrations.SQLite) {
+			t.Fatalf("[ERROR] - Failed to get status %v %v", status, e)
+		}
+		for _, v := range status {
+			if !v.Applied || v.AppliedAt.IsZero() {
+				t.Errorf("[ERROR] - Migration %d should be applied", v.Version)
+			}
+		}
+	})
+	t.Run("Case: Down", func(t *testing.T) {
+		for range migrations.SQLite {
+			if _, e := migrator.Down(); e != nil {
+				t.Fatalf("[ERROR] - Failed to migrate down %s", e.Error())
+			}
+		}
+		if _, e := migrator.Down(); errors.Cause(e) != migrations.ErrNoMigration {
+			t.Errorf("[ERROR] - It should be error '%s' %v", migrations.ErrNoMigration.Error(), e)
+		}
+		if e := migrator.Check(); errors.Cause(e) != migrations.ErrSchemaOutdated {
+			t.Errorf("[ERROR] - It should be error '%s' %v", migrations.ErrSchemaOutdated.Error(), e)
+		}
+	})
+	t.Run("Case: Check Closed Database", func(t *testing.T) {
+		closed, e := sl.NewMigrator(path, 10)
+		if e != nil {
+			t.Fatal(e)
+		}
+		closed.Close()
+		e = closed.Check()
+		if e == nil || errors.Cause(e) == migrations.ErrSchemaOutdated {
+			t.Errorf("[ERROR] - A database that does not answer should not be reported as outdated schema %v", e)
+		}
+	})
+}
+
+func TestReadOnlyMigrator(t *testing.T) {
+	path, clean := tempDB(t)
+	defer clean()
+
+	assertNothingApplied := func(t *testing.T) {
+		t.Helper()
+		migrator, e := sl.NewReadOnlyMigrator(path, 10)
+		if e != nil {
+			t.Fatal(e)
+		}
+		defer migrator.Close()
+		status, e := migrator.ReadStatus()
+		if e != nil || len(status) != len(migrations.SQLite) {
+			t.Fatalf("[ERROR] - Failed to read status %v %v", status, e)
+		}
+		for _, v := range status {
+			if v.Applied {
+				t.Errorf("[ERROR] - Migration %d should not be applied", v.Version)
+			}
+		}
+	}
+
+	t.Run("Case: Missing Database", func(t *testing.T) {
+		assertNothingApplied(t)
+		if _, e := os.Stat(path); !os.IsNotExist(e) {
+			t.Errorf("[ERROR] - Database file should not be created %v", e)
+		}
+	})
+	t.Run("Case: Missing Migration Table", func(t *testing.T) {
+		if _, e := sl.NewNewsRepository(path, 10); errors.Cause(e) != migrations.ErrSchemaOutdated {
+			t.Fatalf("[ERROR] - It should be error '%s' %v", migrations.ErrSchemaOutdated.Error(), e)
+		}
+		assertNothingApplied(t)
+		db, e := sqlx.Open("sqlite3", path)
+		if e != nil {
+			t.Fatal(e)
+		}
+		defer db.Close()
+		tables := 0
+		if e := db.Get(&tables, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'"); e != nil || tables != 0 {
+			t.Errorf("[ERROR] - schema_migrations should not be created %d %v", tables, e)
+		}
+	})
+	t.Run("Case: Migrated", func(t *testing.T) {
+		migrateUp(t, path)
+		migrator, e := sl.NewReadOnlyMigrator(path, 10)
+		if e != nil {
+			t.Fatal(e)
+		}
+		defer migrator.Close()
+		status, e := migrator.ReadStatus()
+		if e != nil || len(status) != len(migrations.SQLite) {
+			t.Fatalf("[ERROR] - Failed to read status %v %v", status, e)
+		}
+		for _, v := range status {
+			if !v.Applied {
+				t.Errorf("[ERROR] - Migration %d should be applied", v.Version)
+			}
+		}
+	})
+}
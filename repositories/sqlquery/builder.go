@@ -0,0 +1,240 @@
+// Package sqlquery builds parameterized queries for news table in SQL backends.
+// Column names are only taken from a whitelist of models.News columns and every value
+// is passed as bind parameter, so data coming from HTTP request never ends up in the SQL text.
+package sqlquery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+)
+
+// DefaultLimit is used when payload limit is not set, same as Elasticsearch default size
+const DefaultLimit = 10
+
+// Placeholder returns bind parameter for n-th value (starts from 1)
+type Placeholder func(n int) string
+
+var (
+	// Question is placeholder used by MySQL and SQLite
+	Question Placeholder = func(int) string { return "?" }
+	// Dollar is placeholder used by PostgreSQL
+	Dollar Placeholder = func(n int) string { return fmt.Sprintf("$%d", n) }
+)
+
+// Condition is a validated column and its value converted into the column type
+type Condition struct {
+	Column string
+	Value  interface{}
+}
+
+type NewsBuilder struct {
+	table       string
+	placeholder Placeholder
+}
+
+func NewNewsBuilder(placeholder Placeholder) *NewsBuilder {
+	return &NewsBuilder{
+		table:       new(m.News).TableName(),
+		placeholder: placeholder,
+	}
+}
+
+var columns = map[string]bool{
+	"id":      true,
+	"author":  true,
+	"body":    true,
+	"created": true,
+}
+
+// readOnly columns are kept by the repository, update data may repeat them but they are never changed
+var readOnly = map[string]bool{
+	"id":      true,
+	"_id":     true,
+	"version": true,
+}
+
+// column converts value into the type of models.News column, unknown column or wrong type returns ErrDataInvalid.
+// created is bound in UTC, SQLite keeps it as text in its offset so only one offset compares as instants.
+func column(name string, value interface{}) (interface{}, error) {
+	switch name {
+	case "id":
+		return helper.ToInt(value)
+	case "author", "body":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case "created":
+		created, e := helper.ToTime(value)
+		if e != nil {
+			return nil, e
+		}
+		return created.UTC(), nil
+	}
+	return nil, helper.ErrDataInvalid
+}
+
+// Conditions validates every key in data, it is sorted by column name to keep the query stable
+func Conditions(data map[string]interface{}) ([]Condition, error) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := []Condition{}
+	for _, k := range keys {
+		v, e := column(k, data[k])
+		if e != nil {
+			return res, helper.ErrDataInvalid
+		}
+		res = append(res, Condition{Column: k, Value: v})
+	}
+	return res, nil
+}
+
+func (b *NewsBuilder) join(conditions []Condition, separator string, values []interface{}) (string, []interface{}) {
+	parts := []string{}
+	for _, c := range conditions {
+		values = append(values, c.Value)
+		parts = append(parts, fmt.Sprintf("%s=%s", c.Column, b.placeholder(len(values))))
+	}
+	return strings.Join(parts, separator), values
+}
+
+func (b *NewsBuilder) where(filter map[string]interface{}, values []interface{}) (string, []interface{}, error) {
+	conditions, e := Conditions(filter)
+	if e != nil {
+		return "", values, e
+	}
+	if len(conditions) == 0 {
+		return "", values, nil
+	}
+	q, values := b.join(conditions, " AND ", values)
+	return " WHERE " + q, values, nil
+}
+
+// Select returns "SELECT id, author, body, created, version FROM news WHERE filter1=? AND filter2=? ORDER BY created ASC, id ASC LIMIT 1",
+// the oldest news is picked so the same filter always returns the same news
+func (b *NewsBuilder) Select(filter map[string]interface{}) (string, []interface{}, error) {
+	where, values, e := b.where(filter, []interface{}{})
+	if e != nil {
+		return "", nil, e
+	}
+	return fmt.Sprintf("SELECT id, author, body, created, version FROM %s%s ORDER BY created ASC, id ASC LIMIT 1", b.table, where), values, nil
+}
+
+// SelectIn returns "SELECT id, author, body, created, version FROM news WHERE id IN (?, ?, ?)"
+func (b *NewsBuilder) SelectIn(ids []int) (string, []interface{}, error) {
+	if len(ids) == 0 {
+		return "", nil, helper.ErrDataInvalid
+	}
+	values := []interface{}{}
+	placeholders := []string{}
+	for _, id := range ids {
+		values = append(values, id)
+		placeholders = append(placeholders, b.placeholder(len(values)))
+	}
+	q := fmt.Sprintf("SELECT id, author, body, created, version FROM %s WHERE id IN (%s)", b.table, strings.Join(placeholders, ", "))
+	return q, values, nil
+}
+
+// List returns "SELECT id, author, body, created, version FROM news WHERE filter1=? AND id>? ORDER BY created DESC, id ASC LIMIT ? OFFSET ?".
+// Order maps column into ascending flag, id is always used as the last order to keep pagination stable.
+// The id condition is only added for payload.AfterID.
+func (b *NewsBuilder) List(payload m.GetPayload) (string, []interface{}, error) {
+	where, values, e := b.where(payload.Filter, []interface{}{})
+	if e != nil {
+		return "", nil, e
+	}
+	if payload.AfterID != nil {
+		values = append(values, *payload.AfterID)
+		after := "id>" + b.placeholder(len(values))
+		if where == "" {
+			where = " WHERE " + after
+		} else {
+			where += " AND " + after
+		}
+	}
+
+	keys := make([]string, 0, len(payload.Order))
+	for k := range payload.Order {
+		if !columns[k] {
+			return "", nil, helper.ErrDataInvalid
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	orders := []string{}
+	for _, k := range keys {
+		direction := "DESC"
+		if payload.Order[k] {
+			direction = "ASC"
+		}
+		orders = append(orders, k+" "+direction)
+	}
+	if _, ok := payload.Order["id"]; !ok {
+		orders = append(orders, "id ASC")
+	}
+
+	limit := payload.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	values = append(values, limit, payload.Offset)
+	q := fmt.Sprintf("SELECT id, author, body, created, version FROM %s%s ORDER BY %s LIMIT %s OFFSET %s",
+		b.table, where, strings.Join(orders, ", "), b.placeholder(len(values)-1), b.placeholder(len(values)))
+
+	return q, values, nil
+}
+
+// Insert returns "INSERT INTO news (id, author, body, created, version) VALUES(?, ?, ?, ?, ?)"
+func (b *NewsBuilder) Insert(data *m.News) (string, []interface{}) {
+	values := []interface{}{data.ID, data.Author, data.Body, data.Created.UTC(), data.Version}
+	placeholders := []string{}
+	for i := range values {
+		placeholders = append(placeholders, b.placeholder(i+1))
+	}
+	q := fmt.Sprintf("INSERT INTO %s (id, author, body, created, version) VALUES(%s)", b.table, strings.Join(placeholders, ", "))
+	return q, values
+}
+
+// Update returns "UPDATE news SET field1=?, field2=?, version=version+1 WHERE filter1=? AND filter2=?",
+// id and version in data are ignored
+func (b *NewsBuilder) Update(data, filter map[string]interface{}) (string, []interface{}, error) {
+	fields := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if !readOnly[k] {
+			fields[k] = v
+		}
+	}
+	sets, e := Conditions(fields)
+	if e != nil {
+		return "", nil, e
+	}
+	if len(sets) == 0 {
+		return "", nil, helper.ErrDataInvalid
+	}
+	set, values := b.join(sets, ", ", []interface{}{})
+	where, values, e := b.where(filter, values)
+	if e != nil {
+		return "", nil, e
+	}
+	return fmt.Sprintf("UPDATE %s SET %s, version=version+1%s", b.table, set, where), values, nil
+}
+
+// Delete returns "DELETE FROM news WHERE filter1=? AND filter2=?"
+func (b *NewsBuilder) Delete(filter map[string]interface{}) (string, []interface{}, error) {
+	where, values, e := b.where(filter, []interface{}{})
+	if e != nil {
+		return "", nil, e
+	}
+	if where == "" {
+		// never delete the whole table by accident
+		return "", nil, helper.ErrDataInvalid
+	}
+	return fmt.Sprintf("DELETE FROM %s%s", b.table, where), values, nil
+}
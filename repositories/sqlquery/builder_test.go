@@ -0,0 +1,221 @@
+package sqlquery_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	. "github.com/rinosukmandityo/maknews/repositories/sqlquery"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v
+*/
+
+type TestTable struct {
+	name           string
+	placeholder    Placeholder
+	data           map[string]interface{}
+	filter         map[string]interface{}
+	expected       string
+	expectedValues []interface{}
+	expectedErr    error
+}
+
+func TestUpdate(t *testing.T) {
+	created := time.Date(2020, 3, 1, 22, 59, 59, 0, time.UTC)
+	tts := []TestTable{
+		{
+			name:           "Case: Positive Test",
+			placeholder:    Question,
+			data:           map[string]interface{}{"body": "Hello", "author": "Alex", "created": "2020-03-01T22:59:59Z"},
+			filter:         map[string]interface{}{"id": float64(1)},
+			expected:       "UPDATE news SET author=?, body=?, created=?, version=version+1 WHERE id=?",
+			expectedValues: []interface{}{"Alex", "Hello", created, 1},
+		},
+		{
+			name:           "Case: Created Is Bound In UTC",
+			placeholder:    Question,
+			data:           map[string]interface{}{"created": "2020-03-02T05:59:59+07:00"},
+			filter:         map[string]interface{}{"created": "2020-03-01T17:59:59-05:00"},
+			expected:       "UPDATE news SET created=?, version=version+1 WHERE created=?",
+			expectedValues: []interface{}{created, created},
+		},
+		{
+			name:           "Case: Dollar Placeholder And Multiple Filter",
+			placeholder:    Dollar,
+			data:           map[string]interface{}{"author": "Alex"},
+			filter:         map[string]interface{}{"id": 1, "body": "Hello"},
+			expected:       "UPDATE news SET author=$1, version=version+1 WHERE body=$2 AND id=$3",
+			expectedValues: []interface{}{"Alex", "Hello", 1},
+		},
+		{
+			name:        "Case: Unknown Column",
+			placeholder: Question,
+			data:        map[string]interface{}{"author=author, body": "injected"},
+			filter:      map[string]interface{}{"id": 1},
+			expectedErr: helper.ErrDataInvalid,
+		},
+		{
+			name:        "Case: Wrong Type",
+			placeholder: Question,
+			data:        map[string]interface{}{"author": 10},
+			filter:      map[string]interface{}{"id": 1},
+			expectedErr: helper.ErrDataInvalid,
+		},
+		{
+			name:           "Case: ID And Version Are Ignored",
+			placeholder:    Question,
+			data:           map[string]interface{}{"id": float64(2), "_id": 2, "version": 9, "author": "Alex"},
+			filter:         map[string]interface{}{"id": 1},
+			expected:       "UPDATE news SET author=?, version=version+1 WHERE id=?",
+			expectedValues: []interface{}{"Alex", 1},
+		},
+		{
+			name:        "Case: Only ID",
+			placeholder: Question,
+			data:        map[string]interface{}{"id": 1},
+			filter:      map[string]interface{}{"id": 1},
+			expectedErr: helper.ErrDataInvalid,
+		},
+		{
+			name:        "Case: Empty Data",
+			placeholder: Question,
+			data:        map[string]interface{}{},
+			filter:      map[string]interface{}{"id": 1},
+			expectedErr: helper.ErrDataInvalid,
+		},
+	}
+
+	for _, tt := range tts {
+		t.Run(tt.name, func(t *testing.T) {
+			q, values, e := NewNewsBuilder(tt.placeholder).Update(tt.data, tt.filter)
+			if e != tt.expectedErr {
+				t.Fatalf("[ERROR] - It should be error '%v' but got '%v'", tt.expectedErr, e)
+			}
+			if q != tt.expected || (e == nil && !reflect.DeepEqual(values, tt.expectedValues)) {
+				t.Errorf("[ERROR] - Expected %s %v but got %s %v", tt.expected, tt.expectedValues, q, values)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tts := []TestTable{
+		{
+			name:           "Case: Value Is Bound",
+			placeholder:    Question,
+			filter:         map[string]interface{}{"author": "x' OR '1'='1", "id": 1},
+			expected:       "SELECT id, author, body, created, version FROM news WHERE author=? AND id=? ORDER BY created ASC, id ASC LIMIT 1",
+			expectedValues: []interface{}{"x' OR '1'='1", 1},
+		},
+		{
+			name:        "Case: Unknown Column",
+			placeholder: Question,
+			filter:      map[string]interface{}{"1=1 OR id": 1},
+			expectedErr: helper.ErrDataInvalid,
+		},
+	}
+
+	for _, tt := range tts {
+		t.Run(tt.name, func(t *testing.T) {
+			q, values, e := NewNewsBuilder(tt.placeholder).Select(tt.filter)
+			if e != tt.expectedErr {
+				t.Fatalf("[ERROR] - It should be error '%v' but got '%v'", tt.expectedErr, e)
+			}
+			if q != tt.expected || (e == nil && !reflect.DeepEqual(values, tt.expectedValues)) {
+				t.Errorf("[ERROR] - Expected %s %v but got %s %v", tt.expected, tt.expectedValues, q, values)
+			}
+		})
+	}
+}
+
+func TestListAfterID(t *testing.T) {
+	after := 10
+	tts := []struct {
+		name           string
+		payload        m.GetPayload
+		expected       string
+		expectedValues []interface{}
+	}{
+		{
+			name:           "Case: Without Filter",
+			payload:        m.GetPayload{Limit: 5, Order: map[string]bool{"id": true}, AfterID: &after},
+			expected:       "SELECT id, author, body, created, version FROM news WHERE id>$1 ORDER BY id ASC LIMIT $2 OFFSET $3",
+			expectedValues: []interface{}{10, 5, 0},
+		},
+		{
+			name:           "Case: With Filter",
+			payload:        m.GetPayload{Filter: map[string]interface{}{"author": "Alex"}, Limit: 5, AfterID: &after},
+			expected:       "SELECT id, author, body, created, version FROM news WHERE author=$1 AND id>$2 ORDER BY id ASC LIMIT $3 OFFSET $4",
+			expectedValues: []interface{}{"Alex", 10, 5, 0},
+		},
+	}
+
+	for _, tt := range tts {
+		t.Run(tt.name, func(t *testing.T) {
+			q, values, e := NewNewsBuilder(Dollar).List(tt.payload)
+			if e != nil {
+				t.Fatalf("[ERROR] - Failed to build query %s", e.Error())
+			}
+			if q != tt.expected || !reflect.DeepEqual(values, tt.expectedValues) {
+				t.Errorf("[ERROR] - Expected %s %v but got %s %v", tt.expected, tt.expectedValues, q, values)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	t.Run("Case: Empty Filter", func(t *testing.T) {
+		if _, _, e := NewNewsBuilder(Question).Delete(map[string]interface{}{}); e != helper.ErrDataInvalid {
+			t.Errorf("[ERROR] - It should be error '%s' but got '%v'", helper.ErrDataInvalid.Error(), e)
+		}
+	})
+}
+
+func TestOutboxMarkSent(t *testing.T) {
+	sent := time.Date(2020, 3, 1, 22, 59, 59, 0, time.UTC)
+	t.Run("Case: Dollar Placeholder", func(t *testing.T) {
+		q, values, e := NewOutboxBuilder(Dollar).MarkSent([]int64{3, 4}, sent)
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to build query %s", e.Error())
+		}
+		expected := "UPDATE outbox SET sent=$1 WHERE id IN ($2, $3)"
+		expectedValues := []interface{}{sent, int64(3), int64(4)}
+		if q != expected || !reflect.DeepEqual(values, expectedValues) {
+			t.Errorf("[ERROR] - Expected %s %v but got %s %v", expected, expectedValues, q, values)
+		}
+	})
+	t.Run("Case: Empty IDs", func(t *testing.T) {
+		if _, _, e := NewOutboxBuilder(Question).MarkSent([]int64{}, sent); e != helper.ErrDataInvalid {
+			t.Errorf("[ERROR] - It should be error '%s' but got '%v'", helper.ErrDataInvalid.Error(), e)
+		}
+	})
+}
+
+func TestOutboxClaim(t *testing.T) {
+	now := time.Date(2020, 3, 1, 22, 59, 59, 0, time.UTC)
+	until := now.Add(time.Minute)
+	q, values := NewOutboxBuilder(Dollar).Claim("abc", now, until, 5)
+	expected := "UPDATE outbox SET claim=$1, claimed_until=$2 WHERE sent IS NULL AND (claimed_until IS NULL OR claimed_until<$3) " +
+		"AND id IN (SELECT id FROM (SELECT id FROM outbox WHERE sent IS NULL AND (claimed_until IS NULL OR claimed_until<$4) ORDER BY id LIMIT $5) AS claimable)"
+	expectedValues := []interface{}{"abc", until, now, now, 5}
+	if q != expected || !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("[ERROR] - Expected %s %v but got %s %v", expected, expectedValues, q, values)
+	}
+}
+
+func TestOutboxChangedSince(t *testing.T) {
+	since := time.Date(2020, 3, 1, 22, 59, 59, 0, time.UTC)
+	q, values := NewOutboxBuilder(Question).ChangedSince(since)
+	expected := "SELECT DISTINCT news_id FROM outbox WHERE created>=? ORDER BY news_id"
+	expectedValues := []interface{}{since}
+	if q != expected || !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("[ERROR] - Expected %s %v but got %s %v", expected, expectedValues, q, values)
+	}
+}
@@ -0,0 +1,77 @@
+package sqlquery
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+)
+
+type OutboxBuilder struct {
+	table       string
+	placeholder Placeholder
+}
+
+func NewOutboxBuilder(placeholder Placeholder) *OutboxBuilder {
+	return &OutboxBuilder{
+		table:       new(m.Outbox).TableName(),
+		placeholder: placeholder,
+	}
+}
+
+// Insert returns "INSERT INTO outbox (news_id, payload, created) VALUES(?, ?, ?)"
+func (b *OutboxBuilder) Insert(data *m.Outbox) (string, []interface{}) {
+	values := []interface{}{data.NewsID, data.Payload, data.Created}
+	placeholders := []string{}
+	for i := range values {
+		placeholders = append(placeholders, b.placeholder(i+1))
+	}
+	q := fmt.Sprintf("INSERT INTO %s (news_id, payload, created) VALUES(%s)", b.table, strings.Join(placeholders, ", "))
+	return q, values
+}
+
+// Claim returns "UPDATE outbox SET claim=?, claimed_until=? WHERE sent IS NULL AND (claimed_until IS NULL OR claimed_until<?)
+// AND id IN (SELECT id FROM (SELECT id FROM outbox WHERE ... ORDER BY id LIMIT ?) AS claimable)".
+// The condition is repeated outside the subquery, so a row claimed by a concurrent relay meanwhile is skipped
+// when the row lock is released. The derived table lets MySQL read the table it updates.
+func (b *OutboxBuilder) Claim(claim string, now, until time.Time, limit int) (string, []interface{}) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	claimable := func(n int) string {
+		return fmt.Sprintf("sent IS NULL AND (claimed_until IS NULL OR claimed_until<%s)", b.placeholder(n))
+	}
+	q := fmt.Sprintf("UPDATE %s SET claim=%s, claimed_until=%s WHERE %s AND id IN (SELECT id FROM (SELECT id FROM %s WHERE %s ORDER BY id LIMIT %s) AS claimable)",
+		b.table, b.placeholder(1), b.placeholder(2), claimable(3), b.table, claimable(4), b.placeholder(5))
+	return q, []interface{}{claim, until, now, now, limit}
+}
+
+// Claimed returns "SELECT id, news_id, payload, created FROM outbox WHERE claim=? AND sent IS NULL ORDER BY id"
+func (b *OutboxBuilder) Claimed(claim string) (string, []interface{}) {
+	q := fmt.Sprintf("SELECT id, news_id, payload, created FROM %s WHERE claim=%s AND sent IS NULL ORDER BY id",
+		b.table, b.placeholder(1))
+	return q, []interface{}{claim}
+}
+
+// ChangedSince returns "SELECT DISTINCT news_id FROM outbox WHERE created>=? ORDER BY news_id"
+func (b *OutboxBuilder) ChangedSince(since time.Time) (string, []interface{}) {
+	q := fmt.Sprintf("SELECT DISTINCT news_id FROM %s WHERE created>=%s ORDER BY news_id", b.table, b.placeholder(1))
+	return q, []interface{}{since}
+}
+
+// MarkSent returns "UPDATE outbox SET sent=? WHERE id IN (?, ?, ?)"
+func (b *OutboxBuilder) MarkSent(ids []int64, sent time.Time) (string, []interface{}, error) {
+	if len(ids) == 0 {
+		return "", nil, helper.ErrDataInvalid
+	}
+	values := []interface{}{sent}
+	placeholders := []string{}
+	for _, id := range ids {
+		values = append(values, id)
+		placeholders = append(placeholders, b.placeholder(len(values)))
+	}
+	q := fmt.Sprintf("UPDATE %s SET sent=%s WHERE id IN (%s)", b.table, b.placeholder(1), strings.Join(placeholders, ", "))
+	return q, values, nil
+}
@@ -0,0 +1,330 @@
+// Package sqlrepo implements NewsRepository and OutboxRepository on top of sqlx.
+// MySQL, PostgreSQL and SQLite only open the connection and pass their Dialect,
+// every query and transaction is shared.
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	"github.com/rinosukmandityo/maknews/repositories/sqlquery"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Dialect is what differs between SQL backends
+type Dialect struct {
+	Placeholder sqlquery.Placeholder
+	// IsDuplicate reports whether e is a unique constraint violation
+	IsDuplicate func(e error) bool
+}
+
+type newsSQLRepository struct {
+	db            *sqlx.DB
+	dialect       Dialect
+	builder       *sqlquery.NewsBuilder
+	outboxBuilder *sqlquery.OutboxBuilder
+	timeout       time.Duration
+}
+
+// NewNewsRepository takes the ownership of db, it is closed by Close
+func NewNewsRepository(db *sqlx.DB, dialect Dialect, timeout time.Duration) repo.NewsRepository {
+	return &newsSQLRepository{
+		db:            db,
+		dialect:       dialect,
+		builder:       sqlquery.NewNewsBuilder(dialect.Placeholder),
+		outboxBuilder: sqlquery.NewOutboxBuilder(dialect.Placeholder),
+		timeout:       timeout,
+	}
+}
+
+// Close releases every pooled connection
+func (r *newsSQLRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *newsSQLRepository) GetBy(ctx context.Context, filter map[string]interface{}) (*m.News, error) {
+	res := new(m.News)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	// SELECT id, author, body, created, version FROM <tablename> WHERE filter1=? AND filter2=? ORDER BY created ASC, id ASC LIMIT 1
+	q, values, e := r.builder.Select(filter)
+	if e != nil {
+		return res, errors.Wrap(e, "repository.News.GetBy")
+	}
+
+	if e := r.db.GetContext(ctx, res, q, values...); e != nil {
+		if e == sql.ErrNoRows {
+			return res, errors.Wrap(helper.ErrDataNotFound, "repository.News.GetBy")
+		}
+		return res, errors.Wrap(e, "repository.News.GetBy")
+	}
+	return res, nil
+
+}
+func (r *newsSQLRepository) List(ctx context.Context, param m.GetPayload) ([]m.News, error) {
+	res := []m.News{}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	// SELECT id, author, body, created, version FROM <tablename> WHERE filter1=? ORDER BY created DESC, id ASC LIMIT ? OFFSET ?
+	q, values, e := r.builder.List(param)
+	if e != nil {
+		return res, errors.Wrap(e, "repository.News.List")
+	}
+
+	if e := r.db.SelectContext(ctx, &res, q, values...); e != nil {
+		return res, errors.Wrap(e, "repository.News.List")
+	}
+	return res, nil
+}
+
+// GetByIDs keeps the order of ids, missing data is reported with helper.PartialDataError
+func (r *newsSQLRepository) GetByIDs(ctx context.Context, ids []int) ([]m.News, error) {
+	res := []m.News{}
+	if len(ids) == 0 {
+		return res, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	// SELECT id, author, body, created, version FROM <tablename> WHERE id IN (?, ?, ?)
+	q, values, e := r.builder.SelectIn(ids)
+	if e != nil {
+		return res, errors.Wrap(e, "repository.News.GetByIDs")
+	}
+
+	if e := r.db.SelectContext(ctx, &res, q, values...); e != nil {
+		return res, errors.Wrap(e, "repository.News.GetByIDs")
+	}
+	res, missing := m.SortByIDs(ids, res)
+	if len(missing) > 0 {
+		return res, errors.Wrap(&helper.PartialDataError{Missing: missing}, "repository.News.GetByIDs")
+	}
+	return res, nil
+}
+
+// writeOutbox inserts the event of data into the outbox inside tx, so the change is never stored without being published
+func (r *newsSQLRepository) writeOutbox(ctx context.Context, tx *sqlx.Tx, eventType m.EventType, data *m.News) error {
+	event, e := m.NewEvent(eventType, data)
+	if e != nil {
+		return e
+	}
+	outbox, e := m.NewOutbox(event)
+	if e != nil {
+		return e
+	}
+	// INSERT INTO outbox (news_id, payload, created) VALUES(?, ?, ?)
+	q, dataField := r.outboxBuilder.Insert(outbox)
+	_, e = tx.ExecContext(ctx, q, dataField...)
+	return e
+}
+
+// insert stores data as version 1 and its created event in one transaction
+func (r *newsSQLRepository) insert(ctx context.Context, data *m.News) error {
+	tx, e := r.db.BeginTxx(ctx, nil)
+	if e != nil {
+		return e
+	}
+	defer tx.Rollback()
+
+	data.Version = 1
+	// INSERT INTO <tablename> (id, author, body, created, version) VALUES(?, ?, ?, ?, ?)
+	q, dataField := r.builder.Insert(data)
+	if _, e := tx.ExecContext(ctx, q, dataField...); e != nil {
+		if r.dialect.IsDuplicate(e) {
+			return helper.ErrDataExists
+		}
+		return e
+	}
+	if e := r.writeOutbox(ctx, tx, m.NewsCreated, data); e != nil {
+		return e
+	}
+
+	return tx.Commit()
+}
+
+func (r *newsSQLRepository) Store(ctx context.Context, data *m.News) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if e := r.insert(ctx, data); e != nil {
+		return errors.Wrap(e, "repository.News.Store")
+	}
+
+	return nil
+
+}
+
+// storeManyChunk is the number of news StoreMany writes in one transaction, each chunk has its own timeout
+const storeManyChunk = 100
+
+// StoreMany writes data in chunks of storeManyChunk. A news that already exists is reported in its own error
+// and does not stop the others, any other failure stops the batch and is given to every news not stored.
+func (r *newsSQLRepository) StoreMany(ctx context.Context, data []m.News) ([]error, error) {
+	res := make([]error, len(data))
+	for start := 0; start < len(data); start += storeManyChunk {
+		end := start + storeManyChunk
+		if end > len(data) {
+			end = len(data)
+		}
+		if e := r.insertChunk(ctx, data[start:end], res[start:end]); e != nil {
+			e = errors.Wrap(e, "repository.News.StoreMany")
+			for i := start; i < len(data); i++ {
+				res[i] = e
+			}
+			return res, e
+		}
+	}
+
+	return res, nil
+}
+
+// insertChunk stores data and their created events in one transaction, a duplicate news is rolled back
+// to its savepoint and reported in res
+func (r *newsSQLRepository) insertChunk(ctx context.Context, data []m.News, res []error) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	tx, e := r.db.BeginTxx(ctx, nil)
+	if e != nil {
+		return e
+	}
+	defer tx.Rollback()
+
+	for i := range data {
+		if _, e := tx.ExecContext(ctx, "SAVEPOINT news"); e != nil {
+			return e
+		}
+		data[i].Version = 1
+		q, dataField := r.builder.Insert(&data[i])
+		if _, e := tx.ExecContext(ctx, q, dataField...); e != nil {
+			if !r.dialect.IsDuplicate(e) {
+				return e
+			}
+			if _, e := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT news"); e != nil {
+				return e
+			}
+			res[i] = errors.Wrap(helper.ErrDataExists, "repository.News.StoreMany")
+			continue
+		}
+		if e := r.writeOutbox(ctx, tx, m.NewsCreated, &data[i]); e != nil {
+			return e
+		}
+		if _, e := tx.ExecContext(ctx, "RELEASE SAVEPOINT news"); e != nil {
+			return e
+		}
+	}
+
+	return tx.Commit()
+}
+
+// update changes the news and writes its updated event in one transaction
+func (r *newsSQLRepository) update(ctx context.Context, data map[string]interface{}, id int) (*m.News, error) {
+	news := new(m.News)
+	filter := map[string]interface{}{"id": id}
+	// UPDATE <tablename> SET field1=?, field2=?, version=version+1 WHERE filter1=? AND filter2=?
+	q, dataField, e := r.builder.Update(data, filter)
+	if e != nil {
+		return news, e
+	}
+	tx, e := r.db.BeginTxx(ctx, nil)
+	if e != nil {
+		return news, e
+	}
+	defer tx.Rollback()
+
+	res, e := tx.ExecContext(ctx, q, dataField...)
+	if e != nil {
+		return news, e
+	}
+	count, e := res.RowsAffected()
+	if e != nil {
+		return news, e
+	}
+	if count == 0 {
+		return news, helper.ErrDataNotFound
+	}
+	q, dataField, e = r.builder.Select(filter)
+	if e != nil {
+		return news, e
+	}
+	if e := tx.GetContext(ctx, news, q, dataField...); e != nil {
+		return news, e
+	}
+	if e := r.writeOutbox(ctx, tx, m.NewsUpdated, news); e != nil {
+		return news, e
+	}
+
+	return news, tx.Commit()
+}
+
+func (r *newsSQLRepository) Update(ctx context.Context, data map[string]interface{}, id int) (*m.News, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	news, e := r.update(ctx, data, id)
+	if e != nil {
+		return news, errors.Wrap(e, "repository.News.Update")
+	}
+
+	return news, nil
+
+}
+
+// remove deletes the news and writes its deleted event carrying the last state in one transaction
+func (r *newsSQLRepository) remove(ctx context.Context, id int) error {
+	news := new(m.News)
+	filter := map[string]interface{}{"id": id}
+	tx, e := r.db.BeginTxx(ctx, nil)
+	if e != nil {
+		return e
+	}
+	defer tx.Rollback()
+
+	q, data, e := r.builder.Select(filter)
+	if e != nil {
+		return e
+	}
+	if e := tx.GetContext(ctx, news, q, data...); e != nil {
+		if e == sql.ErrNoRows {
+			return helper.ErrDataNotFound
+		}
+		return e
+	}
+	// DELETE FROM <tablename> WHERE filter1=? AND filter2=?
+	q, data, e = r.builder.Delete(filter)
+	if e != nil {
+		return e
+	}
+	res, e := tx.ExecContext(ctx, q, data...)
+	if e != nil {
+		return e
+	}
+	count, e := res.RowsAffected()
+	if e != nil {
+		return e
+	}
+	if count == 0 {
+		return helper.ErrDataNotFound
+	}
+	if e := r.writeOutbox(ctx, tx, m.NewsDeleted, news); e != nil {
+		return e
+	}
+
+	return tx.Commit()
+}
+
+func (r *newsSQLRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if e := r.remove(ctx, id); e != nil {
+		return errors.Wrap(e, "repository.News.Delete")
+	}
+
+	return nil
+
+}
@@ -0,0 +1,90 @@
+package sqlrepo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	"github.com/rinosukmandityo/maknews/repositories/sqlquery"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type outboxSQLRepository struct {
+	db      *sqlx.DB
+	builder *sqlquery.OutboxBuilder
+	timeout time.Duration
+}
+
+// NewOutboxRepository takes the ownership of db, it is closed by Close
+func NewOutboxRepository(db *sqlx.DB, dialect Dialect, timeout time.Duration) repo.OutboxRepository {
+	return &outboxSQLRepository{
+		db:      db,
+		builder: sqlquery.NewOutboxBuilder(dialect.Placeholder),
+		timeout: timeout,
+	}
+}
+
+// Close releases every pooled connection
+func (r *outboxSQLRepository) Close() error {
+	return r.db.Close()
+}
+
+// Claim marks the claimable messages with a random claim first, then reads the messages carrying it
+func (r *outboxSQLRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]m.Outbox, error) {
+	res := []m.Outbox{}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	token := make([]byte, 16)
+	if _, e := rand.Read(token); e != nil {
+		return res, errors.Wrap(e, "repository.Outbox.Claim")
+	}
+	claim := hex.EncodeToString(token)
+	now := time.Now().UTC()
+	q, values := r.builder.Claim(claim, now, now.Add(lease), limit)
+	if _, e := r.db.ExecContext(ctx, q, values...); e != nil {
+		return res, errors.Wrap(e, "repository.Outbox.Claim")
+	}
+	// SELECT id, news_id, payload, created FROM outbox WHERE claim=? AND sent IS NULL ORDER BY id
+	q, values = r.builder.Claimed(claim)
+	if e := r.db.SelectContext(ctx, &res, q, values...); e != nil {
+		return res, errors.Wrap(e, "repository.Outbox.Claim")
+	}
+	return res, nil
+}
+
+func (r *outboxSQLRepository) MarkSent(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// UPDATE outbox SET sent=? WHERE id IN (?, ?)
+	q, values, e := r.builder.MarkSent(ids, time.Now().UTC())
+	if e != nil {
+		return errors.Wrap(e, "repository.Outbox.MarkSent")
+	}
+	if _, e := r.db.ExecContext(ctx, q, values...); e != nil {
+		return errors.Wrap(e, "repository.Outbox.MarkSent")
+	}
+	return nil
+}
+
+func (r *outboxSQLRepository) ChangedSince(ctx context.Context, since time.Time) ([]int, error) {
+	res := []int{}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// SELECT DISTINCT news_id FROM outbox WHERE created>=? ORDER BY news_id
+	q, values := r.builder.ChangedSince(since.UTC())
+	if e := r.db.SelectContext(ctx, &res, q, values...); e != nil {
+		return res, errors.Wrap(e, "repository.Outbox.ChangedSince")
+	}
+	return res, nil
+}
@@ -1,9 +1,15 @@
 package services
 
 import (
+	"context"
+	"time"
+
 	repo "github.com/rinosukmandityo/maknews/repositories"
 )
 
 type KafkaService interface {
-	ReadMessage(newsRepo repo.NewsRepository, elasticRepo repo.ElasticRepository) error
+	ReadMessage(ctx context.Context, newsRepo repo.NewsRepository, elasticRepo repo.ElasticRepository) error
+	// Redrive moves messages from the dead-letter topic back to the main topic,
+	// it stops when no message arrives within idle and returns the number of moved messages
+	Redrive(ctx context.Context, idle time.Duration) (int, error)
 }
@@ -1,51 +1,231 @@
 package logic
 
 import (
+	"context"
 	"encoding/json"
+	"log"
+	"time"
 
+	"github.com/rinosukmandityo/maknews/helper"
 	m "github.com/rinosukmandityo/maknews/models"
 	repo "github.com/rinosukmandityo/maknews/repositories"
 	svc "github.com/rinosukmandityo/maknews/services"
+
+	errs "github.com/pkg/errors"
 )
 
+// restartDelay is the wait before reading again after a message could not be handled nor dead-lettered
+const restartDelay = time.Second
+
+// RetryPolicy retries a failed message with exponential backoff, the wait starts at Backoff
+// and is doubled after every retry up to MaxBackoff
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy retries 3 times waiting 100ms, 200ms and 400ms
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	Backoff:    100 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+// wait returns the backoff before retry number retry, it starts from 1
+func (p RetryPolicy) wait(retry int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < retry && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	return wait
+}
+
 type kafkaService struct {
-	repo repo.KafkaRepository
+	repo           repo.KafkaRepository
+	deadLetterRepo repo.KafkaRepository
+	processedRepo  repo.ProcessedEventRepository
+	policy         RetryPolicy
+	workers        int
 }
 
-func NewKafkaService(repo repo.KafkaRepository) svc.KafkaService {
+// NewKafkaService reads news from repo, a message failing after every retry of policy is sent to deadLetterRepo.
+// Without deadLetterRepo the failed message is not committed and it is read again after restartDelay.
+// An event whose idempotency key is found in processedRepo is skipped, without processedRepo every event is applied.
+// Messages are handled by workers goroutines, zero means defaultWorkers.
+func NewKafkaService(repo, deadLetterRepo repo.KafkaRepository, processedRepo repo.ProcessedEventRepository, policy RetryPolicy, workers int) svc.KafkaService {
 	return &kafkaService{
-		repo,
+		repo:           repo,
+		deadLetterRepo: deadLetterRepo,
+		processedRepo:  processedRepo,
+		policy:         policy,
+		workers:        workers,
 	}
 }
 
-func (u *kafkaService) ReadMessage(newsRepo repo.NewsRepository, elasticRepo repo.ElasticRepository) error {
-	dataChan := make(chan []byte) // it will be sent to ReadMessage function
+// processEvent applies the event in msg once, the idempotency key is marked only after the event is applied
+// so an event that fails halfway is applied again on the next delivery
+func (u *kafkaService) processEvent(ctx context.Context, newsRepo repo.NewsRepository, elasticRepo repo.ElasticRepository, msg []byte) error {
+	event, e := m.DecodeEvent(msg)
+	if e != nil {
+		// it would never succeed, so it is not retried
+		return errs.Wrap(helper.ErrDataInvalid, e.Error())
+	}
+	if u.processedRepo != nil {
+		processed, e := u.processedRepo.IsProcessed(ctx, event.ID)
+		if e != nil {
+			return errs.Wrap(e, "service.Kafka.ReadMessage")
+		}
+		if processed {
+			return nil
+		}
+	}
+	if e := applyEvent(ctx, newsRepo, elasticRepo, event); e != nil {
+		return e
+	}
+	if u.processedRepo != nil {
+		if e := u.processedRepo.MarkProcessed(ctx, event.ID); e != nil {
+			return errs.Wrap(e, "service.Kafka.ReadMessage")
+		}
+	}
+	return nil
+}
 
-	go func() {
-		for {
-			select {
-			case dataByte := <-dataChan:
-				data := new(m.News)
-				if e := json.Unmarshal(dataByte, data); e != nil {
-					return
-				}
-				elasticData := m.ElasticNews{
-					ID:      data.ID,
-					Created: data.Created,
-				}
-				if e := elasticRepo.Store(elasticData); e != nil {
-					return
-				}
+// applyEvent keeps elasticsearch in sync with the persistence database. The event is never applied to the
+// persistence database: the change is already stored there, and storing it again would write another outbox event.
+// The stored news decides what is indexed, an event whose version is not the stored one is stale and dropped,
+// the event of the stored version carries the same change. A legacy event has no version, it indexes the stored news.
+func applyEvent(ctx context.Context, newsRepo repo.NewsRepository, elasticRepo repo.ElasticRepository, event *m.Event) error {
+	if elasticRepo == nil {
+		return nil
+	}
+	data := &event.Payload
+	stored, e := newsRepo.GetBy(ctx, map[string]interface{}{"id": data.ID})
+	found := e == nil
+	if e != nil && errs.Cause(e) != helper.ErrDataNotFound {
+		return errs.Wrapf(e, "service.Kafka.ReadMessage %s", event.Type)
+	}
 
-				if e := newsRepo.Store(data); e != nil {
-					return
-				}
-			default:
-			}
+	if event.Type == m.NewsDeleted {
+		if found {
+			// stored again after the delete, its created event indexes it
+			return nil
+		}
+		if e := elasticRepo.Delete(ctx, data.ID); e != nil && errs.Cause(e) != helper.ErrDataNotFound {
+			return errs.Wrapf(e, "service.Kafka.ReadMessage %s", event.Type)
 		}
-	}()
+		return nil
+	}
+	if !found || (data.Version != 0 && data.Version != stored.Version) {
+		return nil
+	}
+	// indexing replaces the existing document, so it serves both created and updated
+	if e := elasticRepo.Store(ctx, m.NewElasticNews(*stored)); e != nil {
+		return errs.Wrapf(e, "service.Kafka.ReadMessage %s", event.Type)
+	}
+	return nil
+}
 
-	u.repo.ReadMessage(dataChan)
+// handle calls process until it succeeds or the retries run out, then the message is dead-lettered.
+// ctx is detached so the attempt that has started is finished, but no retry starts once the consumer stops,
+// the message is then left uncommitted and delivered again.
+func (u *kafkaService) handle(ctx context.Context, msg m.Message, process func(ctx context.Context, msg []byte) error) error {
+	stopping := helper.Stopping(ctx)
+	attempts := 0
+	for {
+		attempts++
+		e := process(ctx, msg.Value)
+		if e == nil {
+			return nil
+		}
+		if errs.Cause(e) == helper.ErrDataInvalid || attempts > u.policy.MaxRetries {
+			return u.deadLetter(ctx, msg, e, attempts)
+		}
+		log.Printf("service.Kafka.ReadMessage retry %d offset %d: %s", attempts, msg.Offset, e.Error())
+		select {
+		case <-stopping:
+			return errs.Wrapf(e, "service.Kafka.ReadMessage stopped before retry %d offset %d", attempts, msg.Offset)
+		case <-time.After(u.policy.wait(attempts)):
+		}
+	}
+}
 
+func (u *kafkaService) deadLetter(ctx context.Context, msg m.Message, cause error, attempts int) error {
+	if u.deadLetterRepo == nil {
+		return cause
+	}
+	value, e := json.Marshal(m.DeadLetter{
+		Message:  msg,
+		Error:    cause.Error(),
+		Attempts: attempts,
+		FailedAt: time.Now().UTC(),
+	})
+	if e != nil {
+		return errs.Wrap(e, "service.Kafka.ReadMessage")
+	}
+	if e := u.deadLetterRepo.WriteRawMessages(ctx, []m.Message{{Key: msg.Key, Value: value}}); e != nil {
+		return errs.Wrap(e, "service.Kafka.ReadMessage")
+	}
+	log.Printf("service.Kafka.ReadMessage dead-lettered offset %d after %d attempts: %s", msg.Offset, attempts, cause.Error())
 	return nil
 }
+
+// ReadMessage consumes news events with a pool of workers until ctx is cancelled. Every batch is committed
+// after all of its messages are handled. When a message can be neither indexed nor dead-lettered the reader
+// is restarted from the last committed offset, so every news is handled at least once.
+func (u *kafkaService) ReadMessage(ctx context.Context, newsRepo repo.NewsRepository, elasticRepo repo.ElasticRepository) error {
+	process := func(ctx context.Context, msg []byte) error {
+		return u.processEvent(ctx, newsRepo, elasticRepo, msg)
+	}
+	pool := newWorkerPool(u.workers, func(ctx context.Context, msg m.Message) error {
+		return u.handle(ctx, msg, process)
+	})
+	defer pool.Stop()
+
+	for {
+		e := u.repo.ReadBatch(ctx, pool.BatchSize(), pool.Run)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if e != nil {
+			log.Println(e.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(restartDelay):
+		}
+	}
+}
+
+func (u *kafkaService) Redrive(ctx context.Context, idle time.Duration) (int, error) {
+	if u.deadLetterRepo == nil {
+		return 0, errs.Wrap(helper.ErrDataInvalid, "service.Kafka.Redrive dead-letter topic is not set")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := time.AfterFunc(idle, cancel)
+	defer timer.Stop()
+
+	count := 0
+	e := u.deadLetterRepo.ReadMessage(ctx, func(ctx context.Context, msg m.Message) error {
+		timer.Stop()
+		data := m.DeadLetter{}
+		if e := json.Unmarshal(msg.Value, &data); e != nil {
+			return errs.Wrap(e, "service.Kafka.Redrive")
+		}
+		if e := u.repo.WriteRawMessages(ctx, []m.Message{data.Message}); e != nil {
+			return errs.Wrap(e, "service.Kafka.Redrive")
+		}
+		count++
+		timer.Reset(idle)
+		return nil
+	})
+	if e != nil {
+		return count, errs.Wrap(e, "service.Kafka.Redrive")
+	}
+	return count, nil
+}
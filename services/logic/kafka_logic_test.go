@@ -0,0 +1,631 @@
+package logic_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	mem "github.com/rinosukmandityo/maknews/repositories/memory"
+	"github.com/rinosukmandityo/maknews/services/logic"
+
+	"github.com/pkg/errors"
+)
+
+// flakyElastic fails the first Store call
+type flakyElastic struct {
+	repo.ElasticRepository
+	mu     sync.Mutex
+	failed bool
+}
+
+func (r *flakyElastic) Store(ctx context.Context, data m.ElasticNews) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.failed {
+		r.failed = true
+		return errors.New("no available connection")
+	}
+	return r.ElasticRepository.Store(ctx, data)
+}
+
+// failingElastic never stores the data
+type failingElastic struct {
+	repo.ElasticRepository
+}
+
+func (failingElastic) Store(ctx context.Context, data m.ElasticNews) error {
+	return errors.New("no available connection")
+}
+
+var testRetryPolicy = logic.RetryPolicy{
+	MaxRetries: 2,
+	Backoff:    time.Millisecond,
+	MaxBackoff: time.Millisecond * 5,
+}
+
+// newsEvents returns event of eventType for every data
+func newsEvents(t *testing.T, eventType m.EventType, data ...m.News) []m.Event {
+	res := make([]m.Event, len(data))
+	for i := range data {
+		event, e := m.NewEvent(eventType, &data[i])
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to create event %s", e.Error())
+		}
+		res[i] = *event
+	}
+	return res
+}
+
+// storeNews stores data in newsRepo, the consumer only indexes news that are stored
+func storeNews(t testing.TB, newsRepo repo.NewsRepository, data []m.News) {
+	for i := range data {
+		if e := newsRepo.Store(ctx, &data[i]); e != nil {
+			t.Fatalf("[ERROR] - Failed to store data %s", e.Error())
+		}
+	}
+}
+
+// waitFor polls done until it returns true or fails the test after 5 seconds
+func waitFor(t *testing.T, done func() bool, message string) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("[ERROR] - %s", message)
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+}
+
+func TestKafkaConsumer(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := mem.NewElasticRepository()
+	kafkaRepo := mem.NewKafkaConnection()
+	data := ListTestData()
+	storeNews(t, newsRepo, data)
+	if e := kafkaRepo.WriteEvents(ctx, newsEvents(t, m.NewsCreated, data...)); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+
+	readCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		logic.NewKafkaService(kafkaRepo, nil, nil, testRetryPolicy, 0).ReadMessage(readCtx, newsRepo,
+			&flakyElastic{ElasticRepository: elasticRepo})
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		res, e := elasticRepo.GetBy(ctx, m.GetPayload{})
+		if e == nil && len(res) == len(ListTestData()) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("[ERROR] - Every news should be indexed after retry %v %v", res, e)
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 2):
+		t.Errorf("[ERROR] - Consumer should stop when context is cancelled")
+	}
+}
+
+// signalingElastic fails every Store and signals it on attempted
+type signalingElastic struct {
+	repo.ElasticRepository
+	attempted chan struct{}
+}
+
+func (r signalingElastic) Store(ctx context.Context, data m.ElasticNews) error {
+	select {
+	case r.attempted <- struct{}{}:
+	default:
+	}
+	return errors.New("no available connection")
+}
+
+func TestKafkaConsumerStopBetweenRetries(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := mem.NewElasticRepository()
+	kafkaRepo := mem.NewKafkaConnection()
+	data := ListTestData()[:1]
+	storeNews(t, newsRepo, data)
+	if e := kafkaRepo.WriteEvents(ctx, newsEvents(t, m.NewsCreated, data...)); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+
+	// the backoff is far longer than the test, the consumer must not wait for it
+	policy := logic.RetryPolicy{MaxRetries: 3, Backoff: time.Minute, MaxBackoff: time.Minute}
+	failing := signalingElastic{elasticRepo, make(chan struct{}, 1)}
+	readCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		logic.NewKafkaService(kafkaRepo, nil, nil, policy, 1).ReadMessage(readCtx, newsRepo, failing)
+		close(done)
+	}()
+	select {
+	case <-failing.attempted:
+	case <-time.After(time.Second * 2):
+		t.Fatalf("[ERROR] - Message was not delivered")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 2):
+		t.Fatalf("[ERROR] - Consumer should stop between retries when context is cancelled")
+	}
+
+	// the message is not committed, so it is delivered again after the restart
+	readCtx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	go logic.NewKafkaService(kafkaRepo, nil, nil, testRetryPolicy, 1).ReadMessage(readCtx, newsRepo, elasticRepo)
+	waitFor(t, func() bool {
+		res, e := elasticRepo.GetBy(ctx, m.GetPayload{})
+		return e == nil && len(res) == 1
+	}, "Message should be delivered again after the restart")
+}
+
+// recordingKafka sends every written message to written
+type recordingKafka struct {
+	repo.KafkaRepository
+	written chan m.Message
+}
+
+func (r recordingKafka) WriteRawMessages(ctx context.Context, msgs []m.Message) error {
+	for _, v := range msgs {
+		r.written <- v
+	}
+	return r.KafkaRepository.WriteRawMessages(ctx, msgs)
+}
+
+func TestKafkaDeadLetter(t *testing.T) {
+	kafkaRepo := mem.NewKafkaConnection()
+	deadLetterRepo := recordingKafka{mem.NewKafkaConnection(), make(chan m.Message, 10)}
+	newsRepo := mem.NewNewsRepository()
+	news := ListTestData()[:1]
+	storeNews(t, newsRepo, news)
+	invalid := m.Message{Key: []byte("invalid"), Value: []byte("not a news")}
+	if e := kafkaRepo.WriteRawMessages(ctx, []m.Message{invalid}); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+	if e := kafkaRepo.WriteEvents(ctx, newsEvents(t, m.NewsCreated, news...)); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+	// one worker keeps the order of dead letters
+	kafkaService := logic.NewKafkaService(kafkaRepo, deadLetterRepo, nil, testRetryPolicy, 1)
+
+	readCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		kafkaService.ReadMessage(readCtx, newsRepo, failingElastic{mem.NewElasticRepository()})
+		close(done)
+	}()
+
+	// deadLetter waits for the next dead letter
+	deadLetter := func(t *testing.T) m.DeadLetter {
+		data := m.DeadLetter{}
+		select {
+		case msg := <-deadLetterRepo.written:
+			if e := json.Unmarshal(msg.Value, &data); e != nil {
+				t.Fatalf("[ERROR] - Invalid dead letter %s %s", msg.Value, e.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("[ERROR] - Message was not dead-lettered")
+		}
+		return data
+	}
+
+	t.Run("Case: Invalid Message Is Not Retried", func(t *testing.T) {
+		data := deadLetter(t)
+		if data.Attempts != 1 || string(data.Message.Value) != string(invalid.Value) || data.Error == "" {
+			t.Errorf("[ERROR] - Expected invalid message after 1 attempt but got %+v", data)
+		}
+	})
+	t.Run("Case: Failed Message After Every Retry", func(t *testing.T) {
+		data := deadLetter(t)
+		if data.Attempts != testRetryPolicy.MaxRetries+1 || string(data.Message.Key) != "1" ||
+			data.Message.Offset != 1 || data.Error == "" {
+			t.Errorf("[ERROR] - Expected news 1 after %d attempts but got %+v", testRetryPolicy.MaxRetries+1, data)
+		}
+	})
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 2):
+		t.Errorf("[ERROR] - Consumer should stop when context is cancelled")
+	}
+
+	t.Run("Case: Redrive", func(t *testing.T) {
+		n, e := kafkaService.Redrive(ctx, time.Millisecond*50)
+		if e != nil || n != 2 {
+			t.Fatalf("[ERROR] - Expected 2 redriven messages but got %d %v", n, e)
+		}
+		if n, e := kafkaService.Redrive(ctx, time.Millisecond*50); e != nil || n != 0 {
+			t.Errorf("[ERROR] - Expected nothing left to redrive but got %d %v", n, e)
+		}
+
+		// the consumer continues from its committed offset, so it reads the redriven messages next
+		readCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		msgs := []m.Message{}
+		kafkaRepo.ReadMessage(readCtx, func(ctx context.Context, msg m.Message) error {
+			msgs = append(msgs, msg)
+			if len(msgs) == 2 {
+				cancel()
+			}
+			return nil
+		})
+		if string(msgs[0].Value) != string(invalid.Value) || string(msgs[1].Key) != "1" {
+			t.Errorf("[ERROR] - Expected original messages but got %+v", msgs)
+		}
+	})
+}
+
+func TestKafkaConsumerEvents(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := mem.NewElasticRepository()
+	kafkaRepo := mem.NewKafkaConnection()
+	data := ListTestData()
+	storeNews(t, newsRepo, data[:3])
+	for _, v := range data[:2] {
+		if e := elasticRepo.Store(ctx, m.ElasticNews{ID: v.ID, Created: v.Created}); e != nil {
+			t.Fatalf("[ERROR] - Failed to index data %s", e.Error())
+		}
+	}
+
+	updated, e := newsRepo.Update(ctx, map[string]interface{}{"author": "Updated Author"}, data[0].ID)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+	}
+	if e := newsRepo.Delete(ctx, data[1].ID); e != nil {
+		t.Fatalf("[ERROR] - Failed to delete data %s", e.Error())
+	}
+	events := newsEvents(t, m.NewsUpdated, *updated, *updated)
+	events = append(events, newsEvents(t, m.NewsDeleted, data[1], data[1])...)
+	// news that is not stored, its transaction was rolled back or it is deleted since
+	missing := m.News{ID: 4, Author: "Dave", Body: "Hello this is news from Dave", Created: time.Now().UTC(), Version: 1}
+	events = append(events, newsEvents(t, m.NewsCreated, missing)...)
+	if e := kafkaRepo.WriteEvents(ctx, events); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+	// message published before the event envelope existed
+	legacyNews := data[2]
+	legacyNews.Version = 0
+	legacy, _ := json.Marshal(legacyNews)
+	if e := kafkaRepo.WriteRawMessages(ctx, []m.Message{{Key: []byte("3"), Value: legacy}}); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	deadLetterRepo := recordingKafka{mem.NewKafkaConnection(), make(chan m.Message, 10)}
+	// one worker applies the events in their order, so the legacy message is the last one
+	go logic.NewKafkaService(kafkaRepo, deadLetterRepo, mem.NewProcessedEventRepository(0), testRetryPolicy, 1).
+		ReadMessage(readCtx, newsRepo, elasticRepo)
+
+	var res []m.ElasticNews
+	waitFor(t, func() bool {
+		res, e = elasticRepo.GetBy(ctx, m.GetPayload{})
+		return e == nil && len(res) == 2 && res[1].ID == data[2].ID
+	}, "Legacy message should index the stored news")
+
+	t.Run("Case: Updated", func(t *testing.T) {
+		if res[0].ID != updated.ID || res[0].Author != updated.Author {
+			t.Errorf("[ERROR] - Expected updated author but got %v", res[0])
+		}
+	})
+	t.Run("Case: Deleted And Not Stored", func(t *testing.T) {
+		for _, v := range res {
+			if v.ID == data[1].ID || v.ID == missing.ID {
+				t.Errorf("[ERROR] - News %d should not be indexed", v.ID)
+			}
+		}
+	})
+	t.Run("Case: Not Applied To Persistence Database", func(t *testing.T) {
+		if _, e := newsRepo.GetBy(ctx, map[string]interface{}{"id": missing.ID}); errors.Cause(e) != helper.ErrDataNotFound {
+			t.Errorf("[ERROR] - News should only be stored by the service but got %v", e)
+		}
+		if stored, e := newsRepo.GetBy(ctx, map[string]interface{}{"id": updated.ID}); e != nil || stored.Version != updated.Version {
+			t.Errorf("[ERROR] - Expected version %d but got %v %v", updated.Version, stored, e)
+		}
+	})
+	t.Run("Case: Applied Change Is Not Dead-lettered", func(t *testing.T) {
+		select {
+		case msg := <-deadLetterRepo.written:
+			t.Errorf("[ERROR] - Unexpected dead letter %s", msg.Value)
+		default:
+		}
+	})
+}
+
+// countingElastic counts the Store calls of every news
+type countingElastic struct {
+	repo.ElasticRepository
+	mu     sync.Mutex
+	stored map[int]int
+}
+
+func (r *countingElastic) Store(ctx context.Context, data m.ElasticNews) error {
+	r.mu.Lock()
+	r.stored[data.ID]++
+	r.mu.Unlock()
+	return r.ElasticRepository.Store(ctx, data)
+}
+
+func (r *countingElastic) count(id int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stored[id]
+}
+
+func TestKafkaConsumerIdempotency(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := &countingElastic{ElasticRepository: mem.NewElasticRepository(), stored: map[int]int{}}
+	kafkaRepo := mem.NewKafkaConnection()
+	processedRepo := mem.NewProcessedEventRepository(0)
+	data := ListTestData()[:2]
+	storeNews(t, newsRepo, data)
+
+	events := newsEvents(t, m.NewsCreated, data...)
+	// the first event is delivered again, it is not indexed again
+	events = []m.Event{events[0], events[0], events[1]}
+	if e := kafkaRepo.WriteEvents(ctx, events); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// one worker handles the duplicate before the last event
+	go logic.NewKafkaService(kafkaRepo, nil, processedRepo, testRetryPolicy, 1).ReadMessage(readCtx, newsRepo, elasticRepo)
+
+	waitFor(t, func() bool {
+		processed, e := processedRepo.IsProcessed(ctx, events[2].ID)
+		return e == nil && processed
+	}, "Every event should be consumed")
+
+	if n := elasticRepo.count(data[0].ID); n != 1 {
+		t.Errorf("[ERROR] - Duplicate event should be skipped, expected 1 store but got %d", n)
+	}
+	for _, event := range events {
+		if processed, e := processedRepo.IsProcessed(ctx, event.ID); e != nil || !processed {
+			t.Errorf("[ERROR] - Event %s should be marked processed %v", event.ID, e)
+		}
+	}
+}
+
+func TestKafkaConsumerStaleEvents(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := &countingElastic{ElasticRepository: mem.NewElasticRepository(), stored: map[int]int{}}
+	kafkaRepo := mem.NewKafkaConnection()
+	data := ListTestData()
+	storeNews(t, newsRepo, data)
+
+	// every news is updated many times before its events are read, interleaved with the other news
+	updates := 100
+	events := []m.Event{}
+	for i := 0; i < updates; i++ {
+		for _, v := range data {
+			res, e := newsRepo.Update(ctx, map[string]interface{}{"author": fmt.Sprintf("%s %d", v.Author, i)}, v.ID)
+			if e != nil {
+				t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+			}
+			events = append(events, newsEvents(t, m.NewsUpdated, *res)...)
+		}
+	}
+	if e := kafkaRepo.WriteEvents(ctx, events); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go logic.NewKafkaService(kafkaRepo, nil, nil, testRetryPolicy, 8).ReadMessage(readCtx, newsRepo, elasticRepo)
+
+	for _, v := range data {
+		expected := fmt.Sprintf("%s %d", v.Author, updates-1)
+		waitFor(t, func() bool {
+			res, e := elasticRepo.GetBy(ctx, m.GetPayload{Limit: len(data)})
+			for _, indexed := range res {
+				if e == nil && indexed.ID == v.ID && indexed.Author == expected {
+					return true
+				}
+			}
+			return false
+		}, fmt.Sprintf("News %d should be indexed with the last update %s", v.ID, expected))
+		// only the event of the stored version is indexed, the older ones are dropped
+		if n := elasticRepo.count(v.ID); n != 1 {
+			t.Errorf("[ERROR] - Stale events of news %d should be dropped, expected 1 store but got %d", v.ID, n)
+		}
+	}
+}
+
+func TestKafkaConsumerOutbox(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := mem.NewElasticRepository()
+	kafkaRepo := mem.NewKafkaConnection()
+	outboxRepo := mem.NewOutboxRepository(newsRepo)
+	relay := logic.NewOutboxService(outboxRepo, kafkaRepo, 100, time.Minute)
+	data := ListTestData()
+	storeNews(t, newsRepo, data)
+	updated, e := newsRepo.Update(ctx, map[string]interface{}{"author": "Updated Author"}, data[0].ID)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+	}
+	if e := newsRepo.Delete(ctx, data[1].ID); e != nil {
+		t.Fatalf("[ERROR] - Failed to delete data %s", e.Error())
+	}
+
+	if n, e := relay.Relay(ctx); e != nil || n != len(data)+2 {
+		t.Fatalf("[ERROR] - Expected %d relayed events but got %d %v", len(data)+2, n, e)
+	}
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go logic.NewKafkaService(kafkaRepo, nil, nil, testRetryPolicy, 0).ReadMessage(readCtx, newsRepo, elasticRepo)
+	waitFor(t, func() bool {
+		res, e := elasticRepo.GetBy(ctx, m.GetPayload{Limit: len(data)})
+		return e == nil && len(res) == len(data)-1 && res[0].Author == updated.Author
+	}, "Every stored news should be indexed")
+
+	// consuming an event must not write another one
+	if n, e := relay.Relay(ctx); e != nil || n != 0 {
+		t.Errorf("[ERROR] - Expected nothing left to relay but got %d %v", n, e)
+	}
+	if res, e := outboxRepo.Claim(ctx, 100, time.Minute); e != nil || len(res) != 0 {
+		t.Errorf("[ERROR] - Expected empty outbox but got %v %v", res, e)
+	}
+}
+
+// slowElastic takes delay for every Store like a remote server
+type slowElastic struct {
+	repo.ElasticRepository
+	delay time.Duration
+}
+
+func (r slowElastic) Store(ctx context.Context, data m.ElasticNews) error {
+	time.Sleep(r.delay)
+	return r.ElasticRepository.Store(ctx, data)
+}
+
+// BenchmarkKafkaConsumer measures the time to consume one message when indexing takes 1ms,
+// one worker is the sequential consumer before the worker pool
+func BenchmarkKafkaConsumer(b *testing.B) {
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			newsRepo := mem.NewNewsRepository()
+			elasticRepo := mem.NewElasticRepository()
+			kafkaRepo := mem.NewKafkaConnection()
+			events := make([]m.Event, b.N)
+			for i := range events {
+				news := m.News{ID: i + 1, Author: "Alex", Body: "Hello this is news from Alex", Created: time.Now().UTC()}
+				storeNews(b, newsRepo, []m.News{news})
+				news.Version = 1
+				event, e := m.NewEvent(m.NewsCreated, &news)
+				if e != nil {
+					b.Fatalf("[ERROR] - Failed to create event %s", e.Error())
+				}
+				events[i] = *event
+			}
+			if e := kafkaRepo.WriteEvents(ctx, events); e != nil {
+				b.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+			}
+
+			readCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			b.ResetTimer()
+			go logic.NewKafkaService(kafkaRepo, nil, nil, testRetryPolicy, workers).
+				ReadMessage(readCtx, newsRepo, slowElastic{elasticRepo, time.Millisecond})
+			for {
+				if res, e := elasticRepo.GetBy(ctx, m.GetPayload{Limit: b.N}); e == nil && len(res) == b.N {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			b.StopTimer()
+		})
+	}
+}
+
+// notifyingElastic sends the ID of every indexed news to stored
+type notifyingElastic struct {
+	repo.ElasticRepository
+	stored chan int
+}
+
+func (r notifyingElastic) Store(ctx context.Context, data m.ElasticNews) error {
+	e := r.ElasticRepository.Store(ctx, data)
+	r.stored <- data.ID
+	return e
+}
+
+// BenchmarkKafkaConsumerLatency measures the time from writing one message to an idle consumer until it is indexed
+func BenchmarkKafkaConsumerLatency(b *testing.B) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := notifyingElastic{mem.NewElasticRepository(), make(chan int, 1)}
+	kafkaRepo := mem.NewKafkaConnection()
+	events := make([]m.Event, b.N)
+	for i := range events {
+		news := []m.News{{ID: i + 1, Author: "Alex", Created: time.Now().UTC()}}
+		storeNews(b, newsRepo, news)
+		event, e := m.NewEvent(m.NewsCreated, &news[0])
+		if e != nil {
+			b.Fatalf("[ERROR] - Failed to create event %s", e.Error())
+		}
+		events[i] = *event
+	}
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go logic.NewKafkaService(kafkaRepo, nil, nil, testRetryPolicy, 0).ReadMessage(readCtx, newsRepo, elasticRepo)
+
+	b.ResetTimer()
+	for i := range events {
+		if e := kafkaRepo.WriteEvents(ctx, events[i:i+1]); e != nil {
+			b.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+		}
+		<-elasticRepo.stored
+	}
+}
+
+// blockingElastic blocks Store until release is closed, started receives the ID of the news being indexed
+type blockingElastic struct {
+	repo.ElasticRepository
+	started chan int
+	release chan struct{}
+}
+
+func (r blockingElastic) Store(ctx context.Context, data m.ElasticNews) error {
+	r.started <- data.ID
+	<-r.release
+	return r.ElasticRepository.Store(ctx, data)
+}
+
+func TestKafkaConsumerStop(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := blockingElastic{mem.NewElasticRepository(), make(chan int, 1), make(chan struct{})}
+	kafkaRepo := mem.NewKafkaConnection()
+	data := ListTestData()[:1]
+	storeNews(t, newsRepo, data)
+	if e := kafkaRepo.WriteEvents(ctx, newsEvents(t, m.NewsCreated, data...)); e != nil {
+		t.Fatalf("[ERROR] - Failed to write message %s", e.Error())
+	}
+
+	readCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		logic.NewKafkaService(kafkaRepo, nil, nil, testRetryPolicy, 0).ReadMessage(readCtx, newsRepo, elasticRepo)
+		close(done)
+	}()
+
+	<-elasticRepo.started
+	cancel()
+	select {
+	case <-done:
+		t.Fatalf("[ERROR] - Consumer should finish the message being handled before it stops")
+	case <-time.After(time.Millisecond * 50):
+	}
+	close(elasticRepo.release)
+	select {
+	case <-done:
+	case <-time.After(time.Second * 2):
+		t.Fatalf("[ERROR] - Consumer should stop after the message is handled")
+	}
+
+	if res, e := elasticRepo.GetBy(ctx, m.GetPayload{}); e != nil || len(res) != 1 {
+		t.Errorf("[ERROR] - Message being handled should be indexed %v %v", res, e)
+	}
+	// the message is committed, so the next reader waits for a new one
+	readCtx, cancel = context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	kafkaRepo.ReadMessage(readCtx, func(ctx context.Context, msg m.Message) error {
+		t.Errorf("[ERROR] - Message should be committed but got offset %d again", msg.Offset)
+		return nil
+	})
+}
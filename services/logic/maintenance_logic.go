@@ -0,0 +1,250 @@
+package logic
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	svc "github.com/rinosukmandityo/maknews/services"
+
+	errs "github.com/pkg/errors"
+	"gopkg.in/dealancer/validate.v2"
+)
+
+// defaultReindexBatch is the number of news read and indexed at a time by Reindex
+const defaultReindexBatch = 500
+
+// reindexChangeMargin is taken from the start of a reindex when reading the changed news, it covers a change
+// whose outbox message is created just before the start but committed after the build has read past it,
+// and the clock differences between replicas
+const reindexChangeMargin = time.Minute
+
+type maintenanceService struct {
+	news       *newsService
+	outboxRepo repo.OutboxRepository
+}
+
+func NewMaintenanceService(repo repo.NewsRepository, outboxRepo repo.OutboxRepository, redisRepo repo.CacheRepository, elasticRepo repo.ElasticRepository) svc.MaintenanceService {
+	return &maintenanceService{
+		&newsService{
+			repo,
+			redisRepo,
+			elasticRepo,
+		},
+		outboxRepo,
+	}
+}
+
+// Reindex builds a new index while searches and writes keep using the one behind the alias, an index that fails to build
+// is deleted and the alias is left untouched. News changed during the build only reach the old index, so after the swap
+// the news changed since the build started, read from the outbox, are indexed again through the alias
+// and the ones deleted meanwhile are removed from it.
+func (u *maintenanceService) Reindex(ctx context.Context, batchSize int, deleteOld, dryRun bool) (*m.ReindexResult, error) {
+	elasticRepo := u.news.elasticRepo
+	if elasticRepo == nil {
+		return nil, errs.New("service.Maintenance.Reindex elasticsearch is not enabled")
+	}
+	if batchSize <= 0 {
+		batchSize = defaultReindexBatch
+	}
+
+	res := &m.ReindexResult{}
+	since := time.Now().Add(-reindexChangeMargin)
+	if dryRun {
+		n, e := u.indexAll(ctx, batchSize, nil)
+		res.Indexed = n
+		return res, errs.Wrap(e, "service.Maintenance.Reindex")
+	}
+
+	index, e := elasticRepo.CreateIndex(ctx)
+	if e != nil {
+		return res, errs.Wrap(e, "service.Maintenance.Reindex")
+	}
+	res.Index = index
+	res.Indexed, e = u.indexAll(ctx, batchSize, func(ctx context.Context, data []m.ElasticNews) ([]error, error) {
+		return elasticRepo.StoreManyIn(ctx, index, data)
+	})
+	if e != nil {
+		if de := elasticRepo.DeleteIndex(ctx, index); de != nil {
+			log.Println("service.Maintenance.Reindex failed to delete incomplete index", index, de.Error())
+		}
+		return res, errs.Wrap(e, "service.Maintenance.Reindex")
+	}
+
+	if res.Previous, e = elasticRepo.SwapIndex(ctx, index); e != nil {
+		return res, errs.Wrap(e, "service.Maintenance.Reindex")
+	}
+	if res.Changed, e = u.indexChanged(ctx, since, batchSize); e != nil {
+		return res, errs.Wrap(e, "service.Maintenance.Reindex catching up")
+	}
+
+	if deleteOld {
+		for _, v := range res.Previous {
+			if e := elasticRepo.DeleteIndex(ctx, v); e != nil {
+				return res, errs.Wrap(e, "service.Maintenance.Reindex")
+			}
+		}
+		res.Deleted = true
+	}
+	return res, nil
+}
+
+// indexAll pages through persistence database by ID, so news stored meanwhile are read in the last pages
+// and news deleted meanwhile never shift the next page.
+// It keeps going when some news fail to index and reports them at the end, a nil store only counts the news.
+func (u *maintenanceService) indexAll(ctx context.Context, batchSize int, store func(ctx context.Context, data []m.ElasticNews) ([]error, error)) (int, error) {
+	indexed, failed := 0, 0
+	payload := m.GetPayload{Limit: batchSize, Order: map[string]bool{"id": true}}
+	for {
+		data, e := u.news.repo.List(ctx, payload)
+		if e != nil {
+			return indexed, e
+		}
+		if len(data) == 0 {
+			break
+		}
+		lastID := data[len(data)-1].ID
+		payload.AfterID = &lastID
+
+		if store == nil {
+			indexed += len(data)
+			continue
+		}
+		eNews := make([]m.ElasticNews, len(data))
+		for i, v := range data {
+			eNews[i] = m.NewElasticNews(v)
+		}
+		itemErrs, e := store(ctx, eNews)
+		if e != nil {
+			return indexed, e
+		}
+		for i, e := range itemErrs {
+			if e != nil {
+				failed++
+				log.Println("service.Maintenance.Reindex failed to index news", eNews[i].ID, e.Error())
+				continue
+			}
+			indexed++
+		}
+		if len(data) < batchSize {
+			break
+		}
+	}
+	if failed > 0 {
+		return indexed, errs.Errorf("%d news failed to index", failed)
+	}
+	return indexed, nil
+}
+
+// indexChanged indexes the news changed since into the alias again, batchSize news at a time,
+// a changed news that is not stored anymore is deleted from it
+func (u *maintenanceService) indexChanged(ctx context.Context, since time.Time, batchSize int) (int, error) {
+	ids, e := u.outboxRepo.ChangedSince(ctx, since)
+	if e != nil {
+		return 0, e
+	}
+	changed, failed := 0, 0
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		data, e := u.news.repo.GetByIDs(ctx, ids[start:end])
+		if _, ok := errs.Cause(e).(*helper.PartialDataError); e != nil && !ok {
+			return changed, e
+		}
+
+		stored := map[int]bool{}
+		eNews := make([]m.ElasticNews, len(data))
+		for i, v := range data {
+			stored[v.ID] = true
+			eNews[i] = m.NewElasticNews(v)
+		}
+		if len(eNews) > 0 {
+			itemErrs, e := u.news.elasticRepo.StoreMany(ctx, eNews)
+			if e != nil {
+				return changed, e
+			}
+			for i, e := range itemErrs {
+				if e != nil {
+					failed++
+					log.Println("service.Maintenance.Reindex failed to index news", eNews[i].ID, e.Error())
+					continue
+				}
+				changed++
+			}
+		}
+		for _, id := range ids[start:end] {
+			if stored[id] {
+				continue
+			}
+			if e := u.news.elasticRepo.Delete(ctx, id); e != nil && errs.Cause(e) != helper.ErrDataNotFound {
+				failed++
+				log.Println("service.Maintenance.Reindex failed to delete news", id, e.Error())
+				continue
+			}
+			changed++
+		}
+	}
+	if failed > 0 {
+		return changed, errs.Errorf("%d news failed to index", failed)
+	}
+	return changed, nil
+}
+
+func (u *maintenanceService) WarmCache(ctx context.Context, limit int, dryRun bool) (int, error) {
+	if limit <= 0 {
+		return 0, errs.New("service.Maintenance.WarmCache limit must be positive")
+	}
+	payload := m.GetPayload{Limit: limit, Order: map[string]bool{"created": false}}
+	data, e := u.news.listData(ctx, payload)
+	if errs.Cause(e) == helper.ErrDataNotFound {
+		return 0, nil
+	}
+	if e != nil {
+		return 0, errs.Wrap(e, "service.Maintenance.WarmCache")
+	}
+	if !dryRun {
+		if e := u.news.redisRepo.Store(ctx, data); e != nil {
+			return 0, errs.Wrap(e, "service.Maintenance.WarmCache")
+		}
+	}
+	return len(data), nil
+}
+
+// Seed with dryRun also reports news whose ID is already stored or repeated in data, like StoreMany would
+func (u *maintenanceService) Seed(ctx context.Context, data []m.News, dryRun bool) ([]m.BulkResult, error) {
+	if !dryRun {
+		return u.news.StoreMany(ctx, data)
+	}
+	results := make([]m.BulkResult, len(data))
+	ids := make([]int, len(data))
+	for i := range data {
+		ids[i] = data[i].ID
+	}
+	existing, e := u.news.repo.GetByIDs(ctx, ids)
+	if _, ok := errs.Cause(e).(*helper.PartialDataError); e != nil && !ok {
+		return results, errs.Wrap(e, "service.Maintenance.Seed")
+	}
+	seen := map[int]bool{}
+	for _, v := range existing {
+		seen[v.ID] = true
+	}
+	for i := range data {
+		results[i].ID = data[i].ID
+		if e := validate.Validate(&data[i]); e != nil {
+			results[i].Error = helper.ErrDataInvalid.Error()
+			continue
+		}
+		if seen[data[i].ID] {
+			results[i].Error = helper.ErrDataExists.Error()
+			continue
+		}
+		seen[data[i].ID] = true
+		results[i].Success = true
+	}
+	return results, nil
+}
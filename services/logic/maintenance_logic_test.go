@@ -0,0 +1,236 @@
+package logic_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	mem "github.com/rinosukmandityo/maknews/repositories/memory"
+	"github.com/rinosukmandityo/maknews/services/logic"
+
+	"github.com/pkg/errors"
+)
+
+// failingIndexElastic fails every news indexed into a new index
+type failingIndexElastic struct {
+	repo.ElasticRepository
+}
+
+func (r failingIndexElastic) StoreManyIn(ctx context.Context, index string, data []m.ElasticNews) ([]error, error) {
+	return nil, errors.New("no available connection")
+}
+
+func TestMaintenanceReindex(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := mem.NewElasticRepository()
+	for _, data := range ListTestData() {
+		_data := data
+		if e := newsRepo.Store(ctx, &_data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+	maintenanceService := logic.NewMaintenanceService(newsRepo, mem.NewOutboxRepository(newsRepo), mem.NewCacheRepository(10), elasticRepo)
+
+	res, e := maintenanceService.Reindex(ctx, 2, false, true)
+	if e != nil || res.Indexed != len(ListTestData()) || res.Index != "" {
+		t.Errorf("[ERROR] - Dry run should count %d news but got %+v %v", len(ListTestData()), res, e)
+	}
+	if _, e := elasticRepo.GetBy(ctx, m.GetPayload{}); e == nil {
+		t.Errorf("[ERROR] - Dry run should not index any news")
+	}
+
+	res, e = maintenanceService.Reindex(ctx, 2, false, false)
+	if e != nil || res.Indexed != len(ListTestData()) {
+		t.Fatalf("[ERROR] - Expected %d indexed news but got %+v %v", len(ListTestData()), res, e)
+	}
+	if res.Index != "news_v2" || len(res.Previous) != 1 || res.Previous[0] != "news_v1" || res.Deleted {
+		t.Errorf("[ERROR] - Expected alias moved from news_v1 to news_v2 but got %+v", res)
+	}
+	indexed, e := elasticRepo.GetBy(ctx, m.GetPayload{})
+	if e != nil || len(indexed) != len(ListTestData()) {
+		t.Errorf("[ERROR] - Expected %d news in elasticsearch but got %d %v", len(ListTestData()), len(indexed), e)
+	}
+
+	t.Run("Case: Delete Old Index", func(t *testing.T) {
+		res, e := maintenanceService.Reindex(ctx, 2, true, false)
+		if e != nil || res.Index != "news_v3" || !res.Deleted {
+			t.Fatalf("[ERROR] - Expected news_v2 deleted after reindex but got %+v %v", res, e)
+		}
+		if e := elasticRepo.DeleteIndex(ctx, "news_v2"); errors.Cause(e) != helper.ErrDataNotFound {
+			t.Errorf("[ERROR] - news_v2 should be deleted %v", e)
+		}
+	})
+	t.Run("Case: Failed Index Keeps Alias", func(t *testing.T) {
+		failing := logic.NewMaintenanceService(newsRepo, mem.NewOutboxRepository(newsRepo), mem.NewCacheRepository(10), failingIndexElastic{elasticRepo})
+		if res, e := failing.Reindex(ctx, 2, true, false); e == nil {
+			t.Fatalf("[ERROR] - Reindex should fail but got %+v", res)
+		}
+		if e := elasticRepo.DeleteIndex(ctx, "news_v4"); errors.Cause(e) != helper.ErrDataNotFound {
+			t.Errorf("[ERROR] - Incomplete news_v4 should be deleted %v", e)
+		}
+		if e := elasticRepo.DeleteIndex(ctx, "news_v3"); errors.Cause(e) != helper.ErrIndexInUse {
+			t.Errorf("[ERROR] - Alias should still point to news_v3 %v", e)
+		}
+	})
+
+	if _, e := logic.NewMaintenanceService(newsRepo, mem.NewOutboxRepository(newsRepo), mem.NewCacheRepository(10), nil).Reindex(ctx, 2, false, false); e == nil {
+		t.Errorf("[ERROR] - Reindex without elasticsearch should fail")
+	}
+}
+
+// changingElastic calls during once, when the first news are indexed into the new index
+type changingElastic struct {
+	repo.ElasticRepository
+	during func()
+}
+
+func (r *changingElastic) StoreManyIn(ctx context.Context, index string, data []m.ElasticNews) ([]error, error) {
+	if r.during != nil {
+		r.during()
+		r.during = nil
+	}
+	return r.ElasticRepository.StoreManyIn(ctx, index, data)
+}
+
+func TestMaintenanceReindexChangedDuringBuild(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := mem.NewElasticRepository()
+	data := ListTestData()
+	for i := range data {
+		if e := newsRepo.Store(ctx, &data[i]); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+	added := m.News{ID: 4, Author: "Dave", Body: "Hello this is news from Dave", Created: time.Now().UTC()}
+	// the first page is read already, so the new index gets news 1 and 2 as they were before
+	changing := &changingElastic{elasticRepo, func() {
+		if _, e := newsRepo.Update(ctx, map[string]interface{}{"author": "Updated Author"}, data[0].ID); e != nil {
+			t.Fatalf("[ERROR] - Failed to update data %s", e.Error())
+		}
+		if e := newsRepo.Delete(ctx, data[1].ID); e != nil {
+			t.Fatalf("[ERROR] - Failed to delete data %s", e.Error())
+		}
+		if e := newsRepo.Store(ctx, &added); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}}
+	maintenanceService := logic.NewMaintenanceService(newsRepo, mem.NewOutboxRepository(newsRepo), mem.NewCacheRepository(10), changing)
+
+	res, e := maintenanceService.Reindex(ctx, 2, false, false)
+	if e != nil || res.Changed != len(data)+1 {
+		t.Fatalf("[ERROR] - Expected %d changed news but got %+v %v", len(data)+1, res, e)
+	}
+	indexed, e := elasticRepo.GetBy(ctx, m.GetPayload{Limit: 10})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get indexed news %s", e.Error())
+	}
+	authors := map[int]string{}
+	for _, v := range indexed {
+		authors[v.ID] = v.Author
+	}
+	expected := map[int]string{data[0].ID: "Updated Author", data[2].ID: data[2].Author, added.ID: added.Author}
+	if !reflect.DeepEqual(authors, expected) {
+		t.Errorf("[ERROR] - Expected %v indexed after the swap but got %v", expected, authors)
+	}
+}
+
+// buildOutbox only reports the news changed during the build, like news stored long before the reindex started
+type buildOutbox struct {
+	repo.OutboxRepository
+	changed []int
+}
+
+func (r *buildOutbox) ChangedSince(ctx context.Context, since time.Time) ([]int, error) {
+	return r.changed, nil
+}
+
+func TestMaintenanceReindexDeletedDuringBuild(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	elasticRepo := mem.NewElasticRepository()
+	data := ListTestData()
+	for i := range data {
+		if e := newsRepo.Store(ctx, &data[i]); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+	outboxRepo := &buildOutbox{OutboxRepository: mem.NewOutboxRepository(newsRepo)}
+	// the first page is read already, a page read by offset would skip the next news after this delete
+	changing := &changingElastic{elasticRepo, func() {
+		if e := newsRepo.Delete(ctx, data[0].ID); e != nil {
+			t.Fatalf("[ERROR] - Failed to delete data %s", e.Error())
+		}
+		outboxRepo.changed = append(outboxRepo.changed, data[0].ID)
+	}}
+	maintenanceService := logic.NewMaintenanceService(newsRepo, outboxRepo, mem.NewCacheRepository(10), changing)
+
+	if res, e := maintenanceService.Reindex(ctx, 1, false, false); e != nil {
+		t.Fatalf("[ERROR] - Failed to reindex %+v %v", res, e)
+	}
+	indexed, e := elasticRepo.GetBy(ctx, m.GetPayload{Limit: 10})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get indexed news %s", e.Error())
+	}
+	ids := map[int]bool{}
+	for _, v := range indexed {
+		ids[v.ID] = true
+	}
+	expected := map[int]bool{data[1].ID: true, data[2].ID: true}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("[ERROR] - Expected every remaining news %v indexed but got %v", expected, ids)
+	}
+}
+
+func TestMaintenanceWarmCache(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	cacheRepo := mem.NewCacheRepository(10)
+	for _, data := range ListTestData() {
+		_data := data
+		if e := newsRepo.Store(ctx, &_data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+	maintenanceService := logic.NewMaintenanceService(newsRepo, mem.NewOutboxRepository(newsRepo), cacheRepo, nil)
+
+	n, e := maintenanceService.WarmCache(ctx, 2, true)
+	if e != nil || n != 2 {
+		t.Errorf("[ERROR] - Dry run should count 2 news but got %d %v", n, e)
+	}
+	if cached, _ := cacheRepo.GetBy(ctx, m.GetPayload{Limit: 10}); len(cached) != 0 {
+		t.Errorf("[ERROR] - Dry run should not cache any news but got %d", len(cached))
+	}
+
+	n, e = maintenanceService.WarmCache(ctx, 2, false)
+	if e != nil || n != 2 {
+		t.Errorf("[ERROR] - Expected 2 cached news but got %d %v", n, e)
+	}
+	cached, e := cacheRepo.GetBy(ctx, m.GetPayload{Limit: 10})
+	if e != nil || len(cached) != 2 || cached[0].ID != 3 {
+		t.Errorf("[ERROR] - Expected the 2 latest news in cache but got %v %v", cached, e)
+	}
+}
+
+func TestMaintenanceSeed(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	maintenanceService := logic.NewMaintenanceService(newsRepo, mem.NewOutboxRepository(newsRepo), mem.NewCacheRepository(10), nil)
+	data := append(ListTestData(), ListTestData()[0])
+
+	for _, dryRun := range []bool{true, false} {
+		results, e := maintenanceService.Seed(ctx, data, dryRun)
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to seed %s", e.Error())
+		}
+		for i, v := range results {
+			if expected := i < len(ListTestData()); v.Success != expected {
+				t.Errorf("[ERROR] - Dry run %v news %d success should be %v but got %+v", dryRun, v.ID, expected, v)
+			}
+		}
+		stored, _ := newsRepo.List(ctx, m.GetPayload{Limit: 10})
+		if expected := map[bool]int{true: 0, false: len(ListTestData())}[dryRun]; len(stored) != expected {
+			t.Errorf("[ERROR] - Dry run %v should store %d news but got %d", dryRun, expected, len(stored))
+		}
+	}
+}
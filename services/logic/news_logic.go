@@ -1,6 +1,11 @@
 package logic
 
 import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
 	"github.com/rinosukmandityo/maknews/helper"
 	m "github.com/rinosukmandityo/maknews/models"
 	repo "github.com/rinosukmandityo/maknews/repositories"
@@ -10,20 +15,37 @@ import (
 	"gopkg.in/dealancer/validate.v2"
 )
 
+// defaults of Search highlight, the tags of elasticsearch
+const (
+	defaultFragmentSize = 100
+	defaultPreTag       = "<em>"
+	defaultPostTag      = "</em>"
+)
+
+// indexChunk is the number of news StoreMany indexes in one elasticsearch bulk request,
+// same as the chunk the repositories store in one transaction
+const indexChunk = 100
+
+// defaults of Stats payload
+const (
+	defaultStatsInterval = "day"
+	defaultStatsTimeZone = "UTC"
+	defaultStatsAuthors  = 10
+)
+
 type newsService struct {
 	repo        repo.NewsRepository
 	redisRepo   repo.CacheRepository
 	elasticRepo repo.ElasticRepository
-	kafkaRepo   repo.KafkaRepository
 }
 
-func NewNewsService(repo repo.NewsRepository, redisRepo repo.CacheRepository, elasticRepo repo.ElasticRepository,
-	kafkaRepo repo.KafkaRepository) svc.NewsService {
+// NewNewsService does not publish to kafka directly, repo writes every stored news into the outbox
+// and the outbox relay publishes it
+func NewNewsService(repo repo.NewsRepository, redisRepo repo.CacheRepository, elasticRepo repo.ElasticRepository) svc.NewsService {
 	return &newsService{
 		repo,
 		redisRepo,
 		elasticRepo,
-		kafkaRepo,
 	}
 }
 
@@ -31,54 +53,48 @@ func IsDataEmpty(data m.News) bool {
 	return data.ID == 0 && data.Author == "" && data.Body == "" && data.Created.IsZero()
 }
 
-func (u *newsService) worker(jobs <-chan int, res chan<- *m.News, err chan<- error) {
-	for id := range jobs {
-		result, e := u.GetById(id)
-		if e != nil {
-			err <- e
-		} else {
-			err <- nil
+// getDataByIDs fetches the complete data from persistence database in one query and keeps elasticsearch order.
+// News that are indexed in elasticsearch but do not exist in persistence database are logged and skipped.
+func (u *newsService) getDataByIDs(ctx context.Context, elasticData []m.ElasticNews) ([]m.News, error) {
+	ids := make([]int, 0, len(elasticData))
+	for _, v := range elasticData {
+		ids = append(ids, v.ID)
+	}
+	data, e := u.repo.GetByIDs(ctx, ids)
+	if e != nil {
+		if _, ok := errs.Cause(e).(*helper.PartialDataError); !ok {
+			return data, errs.Wrap(e, "service.News.GetData")
 		}
-		res <- result
+		log.Println("service.News.GetData elasticsearch index is out of sync:", e.Error())
 	}
+	return data, nil
 }
 
-func (u *newsService) getDataWithWorker(elasticData []m.ElasticNews) []m.News {
-	lenData := len(elasticData)
-	jobs := make(chan int, lenData)
-	res := make(chan *m.News, lenData)
-	err := make(chan error, lenData)
-	resMap := map[int]m.News{}
-	for i := 0; i < 3; i++ {
-		go u.worker(jobs, res, err)
-	}
-
-	for _, v := range elasticData {
-		jobs <- v.ID
-	}
-	close(jobs)
-
-	for i := 0; i < lenData; i++ {
-		result := <-res
-		resMap[result.ID] = *result
+// listData gets news ID from elasticsearch and fetch the complete data from persistence database,
+// when elasticsearch is not configured or unavailable the data is listed from persistence database directly
+func (u *newsService) listData(ctx context.Context, payload m.GetPayload) ([]m.News, error) {
+	if u.elasticRepo != nil {
+		elasticData, e := u.elasticRepo.GetBy(ctx, payload)
+		if e == nil {
+			return u.getDataByIDs(ctx, elasticData)
+		}
+		if errs.Cause(e) == helper.ErrDataNotFound {
+			return []m.News{}, helper.ErrDataNotFound
+		}
+		log.Println("service.News.GetData elasticsearch is unavailable, listing from persistence database:", e.Error())
 	}
 
-	errs := []error{}
-	for i := 0; i < lenData; i++ {
-		e := <-err
-		errs = append(errs, e)
+	data, e := u.repo.List(ctx, payload)
+	if e != nil {
+		return data, errs.Wrap(e, "service.News.GetData")
 	}
-
-	data := []m.News{}
-	for _, v := range elasticData {
-		if !IsDataEmpty(resMap[v.ID]) {
-			data = append(data, resMap[v.ID])
-		}
+	if len(data) == 0 {
+		return data, helper.ErrDataNotFound
 	}
-	return data
+	return data, nil
 }
 
-func (u *newsService) GetData(payload m.GetPayload) ([]m.News, error) {
+func (u *newsService) GetData(ctx context.Context, payload m.GetPayload) ([]m.News, error) {
 	if payload.Offset < 0 {
 		return []m.News{}, errs.New("Offset can not be less than zero")
 	}
@@ -86,19 +102,15 @@ func (u *newsService) GetData(payload m.GetPayload) ([]m.News, error) {
 		return []m.News{}, errs.New("Limit can not be less than zero")
 	}
 
-	data, e := u.redisRepo.GetBy(payload)
+	data, e := u.redisRepo.GetBy(ctx, payload)
 
 	if e != nil || len(data) == 0 {
 		payload.Order = map[string]bool{"created": false}
-		elasticData, e := u.elasticRepo.GetBy(payload)
+		data, e = u.listData(ctx, payload)
 		if e != nil {
-			if errs.Cause(e) == helper.ErrDataNotFound {
-				e = helper.ErrDataNotFound
-			}
 			return data, e
 		}
-		data = u.getDataWithWorker(elasticData)
-		if e := u.redisRepo.Store(data); e != nil {
+		if e := u.redisRepo.Store(ctx, data); e != nil {
 			return data, e
 		}
 	}
@@ -106,9 +118,9 @@ func (u *newsService) GetData(payload m.GetPayload) ([]m.News, error) {
 	return data, nil
 }
 
-func (u *newsService) GetById(id int) (*m.News, error) {
+func (u *newsService) GetById(ctx context.Context, id int) (*m.News, error) {
 	filter := map[string]interface{}{"id": id}
-	res, e := u.repo.GetBy(filter)
+	res, e := u.repo.GetBy(ctx, filter)
 	if e != nil {
 		return res, errs.Wrap(e, "service.News.GetById")
 	}
@@ -116,53 +128,277 @@ func (u *newsService) GetById(id int) (*m.News, error) {
 	return res, nil
 
 }
-func (u *newsService) Store(data *m.News) error {
-	if e := u.kafkaRepo.WriteMessage(data); e != nil {
+
+// Store validates data and stores it together with its outbox message, so news is either fully accepted or not at all.
+// Indexing into elasticsearch happens after that, a failure is only logged since the consumer indexes it again.
+func (u *newsService) Store(ctx context.Context, data *m.News) error {
+	if e := validate.Validate(data); e != nil {
+		return errs.Wrap(helper.ErrDataInvalid, "service.News.Store")
+	}
+	if e := u.repo.Store(ctx, data); e != nil {
 		return errs.Wrap(e, "service.News.Store")
 	}
 
-	eNews := m.ElasticNews{
-		ID:      data.ID,
-		Created: data.Created,
+	if u.elasticRepo != nil {
+		if e := u.elasticRepo.Store(ctx, m.NewElasticNews(*data)); e != nil {
+			log.Println("service.News.Store failed to index news", data.ID, e.Error())
+		}
 	}
-	if e := u.elasticRepo.Store(eNews); e != nil {
-		return errs.Wrap(e, "service.News.Store")
+	return nil
+
+}
+
+// StoreMany stores valid news with one repository call and indexes the stored ones with elasticsearch bulk requests of indexChunk news.
+// Result follows the order of data, like Store a news is successful once it is stored with its outbox message.
+// When the repository stops the batch the chunks committed before stay stored, so the results are returned with the error.
+func (u *newsService) StoreMany(ctx context.Context, data []m.News) ([]m.BulkResult, error) {
+	results := make([]m.BulkResult, len(data))
+	pending := []int{} // index of data that has not failed yet
+	for i := range data {
+		results[i].ID = data[i].ID
+		if e := validate.Validate(&data[i]); e != nil {
+			results[i].Error = helper.ErrDataInvalid.Error()
+			continue
+		}
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return results, nil
 	}
 
-	if e := validate.Validate(data); e != nil {
-		return errs.Wrap(helper.ErrDataInvalid, "service.News.Store")
+	news := make([]m.News, len(pending))
+	for j, i := range pending {
+		news[j] = data[i]
+	}
+	itemErrs, batchErr := u.repo.StoreMany(ctx, news)
+	if batchErr != nil {
+		// every news not stored gets the batch error, an adapter that does not report them has stored none of the rest
+		for len(itemErrs) < len(news) {
+			itemErrs = append(itemErrs, batchErr)
+		}
+	}
+	pending = failItems(results, pending, itemErrs)
+	for _, i := range pending {
+		results[i].Success = true
+	}
+
+	if u.elasticRepo != nil && len(pending) > 0 {
+		eNews := make([]m.ElasticNews, len(pending))
+		for j, i := range pending {
+			eNews[j] = m.NewElasticNews(data[i])
+		}
+		u.indexMany(ctx, eNews)
+	}
+	if batchErr != nil {
+		return results, errs.Wrap(batchErr, "service.News.StoreMany")
+	}
+
+	return results, nil
+}
+
+// indexMany sends data in bulks of indexChunk, so one bulk stays under the request size and timeout of elasticsearch.
+// Failures are only logged like in Store, a failed bulk does not stop the next ones.
+func (u *newsService) indexMany(ctx context.Context, data []m.ElasticNews) {
+	for start := 0; start < len(data); start += indexChunk {
+		end := start + indexChunk
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[start:end]
+		itemErrs, e := u.elasticRepo.StoreMany(ctx, chunk)
+		for j := range chunk {
+			itemErr := e
+			if j < len(itemErrs) && itemErrs[j] != nil {
+				itemErr = itemErrs[j]
+			}
+			if itemErr != nil {
+				log.Println("service.News.StoreMany failed to index news", chunk[j].ID, itemErr.Error())
+			}
+		}
 	}
-	return u.repo.Store(data)
+}
 
+// failItems records itemErrs (aligned with pending) into results and returns index that are still pending
+func failItems(results []m.BulkResult, pending []int, itemErrs []error) []int {
+	res := []int{}
+	for j, i := range pending {
+		if j < len(itemErrs) && itemErrs[j] != nil {
+			results[i].Error = errs.Cause(itemErrs[j]).Error()
+			continue
+		}
+		res = append(res, i)
+	}
+	return res
 }
-func (u *newsService) Update(data map[string]interface{}, id int) (*m.News, error) {
-	updatedData, e := u.repo.Update(data, id)
+
+// Update is done once the news is stored together with its outbox message, like Store.
+// Elasticsearch and cache failures are only logged since the consumer indexes the news again and the cache expires.
+func (u *newsService) Update(ctx context.Context, data map[string]interface{}, id int) (*m.News, error) {
+	updatedData, e := u.repo.Update(ctx, data, id)
 	if e != nil {
 		return updatedData, errs.Wrap(e, "service.News.Update")
 	}
-	eNews := m.ElasticNews{
-		ID:      updatedData.ID,
-		Created: updatedData.Created,
-	}
-	if e := u.elasticRepo.Update(eNews, id); e != nil {
-		return updatedData, e
+	if u.elasticRepo != nil {
+		if e := u.elasticRepo.Update(ctx, m.NewElasticNews(*updatedData), id); e != nil {
+			log.Println("service.News.Update failed to index news", id, e.Error())
+		}
 	}
-	if e := u.redisRepo.Update(*updatedData); e != nil {
-		return updatedData, e
+	if e := u.redisRepo.Update(ctx, *updatedData); e != nil {
+		log.Println("service.News.Update failed to cache news", id, e.Error())
 	}
 	return updatedData, nil
 
 }
-func (u *newsService) Delete(existingData m.News) error {
-	if e := u.repo.Delete(existingData.ID); e != nil {
+
+// Delete is done once the news is removed together with its outbox message, failures after that are only logged like in Update
+func (u *newsService) Delete(ctx context.Context, existingData m.News) error {
+	if e := u.repo.Delete(ctx, existingData.ID); e != nil {
 		return e
 	}
-	if e := u.elasticRepo.Delete(existingData.ID); e != nil {
-		return e
+	if u.elasticRepo != nil {
+		if e := u.elasticRepo.Delete(ctx, existingData.ID); e != nil {
+			log.Println("service.News.Delete failed to delete indexed news", existingData.ID, e.Error())
+		}
 	}
-	if e := u.redisRepo.Delete(existingData); e != nil {
-		return e
+	if e := u.redisRepo.Delete(ctx, existingData); e != nil {
+		log.Println("service.News.Delete failed to delete cached news", existingData.ID, e.Error())
 	}
 	return nil
 
 }
+
+// Search gets the matching news ID from elasticsearch and fetches the complete data from persistence database.
+// Unlike GetData it has no fallback, persistence database can not search text.
+func (u *newsService) Search(ctx context.Context, payload m.SearchPayload) (*m.SearchResult, error) {
+	payload.Query = strings.TrimSpace(payload.Query)
+	if payload.Query == "" {
+		return nil, errs.Wrap(helper.ErrDataInvalid, "service.News.Search query is empty")
+	}
+	if payload.Offset < 0 {
+		return nil, errs.Wrap(helper.ErrDataInvalid, "service.News.Search offset can not be less than zero")
+	}
+	if payload.Limit < 0 {
+		return nil, errs.Wrap(helper.ErrDataInvalid, "service.News.Search limit can not be less than zero")
+	}
+	if payload.Highlight.FragmentSize < 0 {
+		return nil, errs.Wrap(helper.ErrDataInvalid, "service.News.Search fragment size can not be less than zero")
+	}
+	if payload.Highlight.FragmentSize == 0 {
+		payload.Highlight.FragmentSize = defaultFragmentSize
+	}
+	// a tag without its pair would leave the fragment unbalanced
+	if (payload.Highlight.PreTag == "") != (payload.Highlight.PostTag == "") {
+		return nil, errs.Wrap(helper.ErrDataInvalid, "service.News.Search pre and post tags must be given together")
+	}
+	if payload.Highlight.PreTag == "" {
+		payload.Highlight.PreTag, payload.Highlight.PostTag = defaultPreTag, defaultPostTag
+	}
+	// the fragments are rendered as HTML, so the tags come from a fixed list instead of the query
+	if !validHighlightTags(payload.Highlight) {
+		return nil, errs.Wrapf(helper.ErrDataInvalid, "service.News.Search pre and post tags must open and close one of %s",
+			strings.Join(m.HighlightTags, ", "))
+	}
+	if u.elasticRepo == nil {
+		return nil, errs.Wrap(helper.ErrSearchUnavailable, "service.News.Search")
+	}
+
+	found, e := u.elasticRepo.Search(ctx, payload)
+	if e != nil {
+		return nil, errs.Wrap(e, "service.News.Search")
+	}
+	res := &m.SearchResult{
+		Total: found.Total,
+		Hits:  []m.SearchHit{},
+	}
+	if len(found.Hits) == 0 {
+		return res, nil
+	}
+
+	elasticData := make([]m.ElasticNews, len(found.Hits))
+	hits := map[int]m.ElasticHit{}
+	for i, v := range found.Hits {
+		elasticData[i] = v.News
+		hits[v.News.ID] = v
+	}
+	data, e := u.getDataByIDs(ctx, elasticData)
+	if e != nil {
+		return nil, errs.Wrap(e, "service.News.Search")
+	}
+	for _, v := range data {
+		res.Hits = append(res.Hits, m.SearchHit{
+			News:       v,
+			Score:      hits[v.ID].Score,
+			Highlights: hits[v.ID].Highlights,
+		})
+	}
+	return res, nil
+}
+
+// Stats fills the empty fields of payload with their defaults before counting
+func (u *newsService) Stats(ctx context.Context, payload m.StatsPayload) (*m.NewsStats, error) {
+	if payload.Interval == "" {
+		payload.Interval = defaultStatsInterval
+	}
+	if !validInterval(payload.Interval) {
+		return nil, errs.Wrapf(helper.ErrDataInvalid, "service.News.Stats interval must be one of %s", strings.Join(m.StatsIntervals, ", "))
+	}
+	if payload.TimeZone == "" {
+		payload.TimeZone = defaultStatsTimeZone
+	}
+	if _, e := time.LoadLocation(payload.TimeZone); e != nil || payload.TimeZone == "Local" {
+		return nil, errs.Wrapf(helper.ErrDataInvalid, "service.News.Stats unknown time zone %q", payload.TimeZone)
+	}
+	if payload.Authors == 0 {
+		payload.Authors = defaultStatsAuthors
+	}
+	if payload.Authors < 0 {
+		return nil, errs.Wrap(helper.ErrDataInvalid, "service.News.Stats authors can not be less than zero")
+	}
+	if !payload.From.IsZero() && !payload.To.IsZero() && !payload.To.After(payload.From) {
+		return nil, errs.Wrap(helper.ErrDataInvalid, "service.News.Stats to must be after from")
+	}
+	if statsBuckets(payload) > m.MaxStatsBuckets {
+		return nil, errs.Wrapf(helper.ErrDataInvalid, "service.News.Stats more than %d buckets, narrow the range or use a longer interval", m.MaxStatsBuckets)
+	}
+	if u.elasticRepo == nil {
+		return nil, errs.Wrap(helper.ErrSearchUnavailable, "service.News.Stats")
+	}
+
+	res, e := u.elasticRepo.Stats(ctx, payload)
+	if e != nil {
+		return nil, errs.Wrap(e, "service.News.Stats")
+	}
+	return res, nil
+}
+
+// statsBuckets is the most buckets payload may return, stopping past m.MaxStatsBuckets.
+// The histogram of an open range is only known by counting, the repository checks it.
+func statsBuckets(payload m.StatsPayload) int {
+	n := payload.Authors
+	if payload.From.IsZero() || payload.To.IsZero() {
+		return n
+	}
+	loc, _ := time.LoadLocation(payload.TimeZone)
+	for start := m.StatsBucketStart(payload.From, payload.Interval, loc); start.Before(payload.To) && n <= m.MaxStatsBuckets; start = m.NextStatsBucket(start, payload.Interval) {
+		n++
+	}
+	return n
+}
+
+func validHighlightTags(h m.Highlight) bool {
+	for _, v := range m.HighlightTags {
+		if h.PreTag == "<"+v+">" && h.PostTag == "</"+v+">" {
+			return true
+		}
+	}
+	return false
+}
+
+func validInterval(interval string) bool {
+	for _, v := range m.StatsIntervals {
+		if v == interval {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,466 @@
+package logic_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	mem "github.com/rinosukmandityo/maknews/repositories/memory"
+	"github.com/rinosukmandityo/maknews/services/logic"
+
+	"github.com/pkg/errors"
+)
+
+/*
+	==================
+	RUN FROM TERMINAL
+	==================
+	go test -v
+*/
+
+var ctx = context.Background()
+
+// unavailableElastic behaves like elasticsearch during an outage
+type unavailableElastic struct{}
+
+func (unavailableElastic) GetBy(ctx context.Context, param m.GetPayload) ([]m.ElasticNews, error) {
+	return nil, errors.New("no available connection")
+}
+func (unavailableElastic) Store(ctx context.Context, data m.ElasticNews) error {
+	return nil
+}
+func (unavailableElastic) StoreMany(ctx context.Context, data []m.ElasticNews) ([]error, error) {
+	return make([]error, len(data)), nil
+}
+func (unavailableElastic) Update(ctx context.Context, data m.ElasticNews, id int) error {
+	return errors.New("no available connection")
+}
+func (unavailableElastic) Delete(ctx context.Context, id int) error {
+	return errors.New("no available connection")
+}
+func (unavailableElastic) Search(ctx context.Context, param m.SearchPayload) (*m.ElasticSearchResult, error) {
+	return nil, errors.New("no available connection")
+}
+func (unavailableElastic) Stats(ctx context.Context, param m.StatsPayload) (*m.NewsStats, error) {
+	return nil, errors.New("no available connection")
+}
+func (unavailableElastic) CreateIndex(ctx context.Context) (string, error) {
+	return "", errors.New("no available connection")
+}
+func (unavailableElastic) StoreManyIn(ctx context.Context, index string, data []m.ElasticNews) ([]error, error) {
+	return nil, errors.New("no available connection")
+}
+func (unavailableElastic) SwapIndex(ctx context.Context, index string) ([]string, error) {
+	return nil, errors.New("no available connection")
+}
+func (unavailableElastic) DeleteIndex(ctx context.Context, index string) error {
+	return errors.New("no available connection")
+}
+func (unavailableElastic) Close() error {
+	return nil
+}
+
+type TestTable struct {
+	name        string
+	elasticRepo repo.ElasticRepository
+}
+
+func ListTestData() []m.News {
+	now := time.Now().UTC()
+	return []m.News{{
+		ID:      1,
+		Author:  "Alex",
+		Body:    "Hello this is news from Alex",
+		Created: now,
+	}, {
+		ID:      2,
+		Author:  "Bacca",
+		Body:    "Hello this is news from Bacca",
+		Created: now.Add(time.Second * 3),
+	}, {
+		ID:      3,
+		Author:  "Chicarito",
+		Body:    "Hello this is news from Chicarito",
+		Created: now.Add(time.Second * 5),
+	}}
+}
+
+func TestGetDataFallback(t *testing.T) {
+	tts := []TestTable{
+		{
+			name:        "Case: Elasticsearch Available",
+			elasticRepo: mem.NewElasticRepository(),
+		},
+		{
+			name:        "Case: Elasticsearch Not Configured",
+			elasticRepo: nil,
+		},
+		{
+			name:        "Case: Elasticsearch Unavailable",
+			elasticRepo: unavailableElastic{},
+		},
+	}
+
+	for _, tt := range tts {
+		t.Run(tt.name, func(t *testing.T) {
+			newsService := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10),
+				tt.elasticRepo)
+			if _, e := newsService.GetData(ctx, m.GetPayload{Offset: 0, Limit: 10}); errors.Cause(e) != helper.ErrDataNotFound {
+				t.Errorf("[ERROR] - It should be error '%s' %v", helper.ErrDataNotFound.Error(), e)
+			}
+			for _, data := range ListTestData() {
+				_data := data
+				if e := newsService.Store(ctx, &_data); e != nil {
+					t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+				}
+			}
+
+			res, e := newsService.GetData(ctx, m.GetPayload{Offset: 0, Limit: 2})
+			if e != nil {
+				t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+			}
+			if len(res) != 2 || res[0].ID != 3 || res[1].ID != 2 {
+				t.Errorf("[ERROR] - Incorrect order data %v", res)
+			}
+		})
+	}
+}
+
+func TestUpdateAndDeleteElasticUnavailable(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	newsService := logic.NewNewsService(newsRepo, mem.NewCacheRepository(10),
+		unavailableElastic{})
+	data := ListTestData()[0]
+	if e := newsService.Store(ctx, &data); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+
+	// the change is stored with its outbox message, elasticsearch catches up through the consumer
+	updated, e := newsService.Update(ctx, map[string]interface{}{"author": "Updated"}, data.ID)
+	if e != nil || updated.Author != "Updated" {
+		t.Fatalf("[ERROR] - Update should succeed without elasticsearch %v %v", updated, e)
+	}
+	if e := newsService.Delete(ctx, *updated); e != nil {
+		t.Fatalf("[ERROR] - Delete should succeed without elasticsearch %s", e.Error())
+	}
+	if _, e := newsRepo.GetBy(ctx, map[string]interface{}{"id": data.ID}); errors.Cause(e) != helper.ErrDataNotFound {
+		t.Errorf("[ERROR] - It should be error '%s' %v", helper.ErrDataNotFound.Error(), e)
+	}
+}
+
+func TestGetDataOutOfSyncIndex(t *testing.T) {
+	elasticRepo := mem.NewElasticRepository()
+	newsService := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10),
+		elasticRepo)
+	for _, data := range ListTestData() {
+		_data := data
+		if e := newsService.Store(ctx, &_data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+	// indexed in elasticsearch only
+	elasticRepo.Store(ctx, m.ElasticNews{ID: 4, Created: time.Now().UTC().Add(time.Second * 4)})
+
+	res, e := newsService.GetData(ctx, m.GetPayload{Offset: 0, Limit: 10})
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to get data %s", e.Error())
+	}
+	if len(res) != 3 || res[0].ID != 3 || res[1].ID != 2 || res[2].ID != 1 {
+		t.Errorf("[ERROR] - Incorrect data %v", res)
+	}
+}
+
+func TestStoreMany(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	newsService := logic.NewNewsService(newsRepo, mem.NewCacheRepository(10),
+		mem.NewElasticRepository())
+	existing := ListTestData()[0]
+	if e := newsService.Store(ctx, &existing); e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+
+	res, e := newsService.StoreMany(ctx, ListTestData())
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+	if len(res) != 3 {
+		t.Fatalf("[ERROR] - Expected 3 result but got %d", len(res))
+	}
+	if res[0].Success || res[0].Error != helper.ErrDataExists.Error() {
+		t.Errorf("[ERROR] - It should be error '%s' %+v", helper.ErrDataExists.Error(), res[0])
+	}
+	for _, v := range res[1:] {
+		if !v.Success {
+			t.Errorf("[ERROR] - Failed to save data %+v", v)
+		}
+		if _, e := newsRepo.GetBy(ctx, map[string]interface{}{"id": v.ID}); e != nil {
+			t.Errorf("[ERROR] - Failed to get data %s", e.Error())
+		}
+	}
+}
+
+// chunkFailingRepo stores the first chunk of StoreMany and fails the next one, like an adapter whose second transaction fails
+type chunkFailingRepo struct {
+	repo.NewsRepository
+	chunk int
+}
+
+func (r chunkFailingRepo) StoreMany(ctx context.Context, data []m.News) ([]error, error) {
+	res, e := r.NewsRepository.StoreMany(ctx, data[:r.chunk])
+	if e != nil {
+		return res, e
+	}
+	failure := errors.New("second chunk failed")
+	for range data[r.chunk:] {
+		res = append(res, failure)
+	}
+	return res, failure
+}
+
+func TestStoreManySecondChunkFails(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	newsService := logic.NewNewsService(chunkFailingRepo{newsRepo, 2}, mem.NewCacheRepository(10),
+		mem.NewElasticRepository())
+
+	data := ListTestData()
+	res, e := newsService.StoreMany(ctx, data)
+	if e == nil {
+		t.Errorf("[ERROR] - StoreMany should return the batch error")
+	}
+	if len(res) != len(data) {
+		t.Fatalf("[ERROR] - Expected %d result but got %d", len(data), len(res))
+	}
+	for i, v := range res {
+		_, getErr := newsRepo.GetBy(ctx, map[string]interface{}{"id": data[i].ID})
+		if i < 2 {
+			if !v.Success || getErr != nil {
+				t.Errorf("[ERROR] - News of the committed chunk should be successful %+v %v", v, getErr)
+			}
+			continue
+		}
+		if v.Success || v.Error == "" {
+			t.Errorf("[ERROR] - News of the failed chunk should fail %+v", v)
+		}
+		if errors.Cause(getErr) != helper.ErrDataNotFound {
+			t.Errorf("[ERROR] - News of the failed chunk should not be stored %v", getErr)
+		}
+	}
+}
+
+// bulkRecordingElastic records the size of every StoreMany bulk and fails the first one
+type bulkRecordingElastic struct {
+	repo.ElasticRepository
+	bulks *[]int
+}
+
+func (r bulkRecordingElastic) StoreMany(ctx context.Context, data []m.ElasticNews) ([]error, error) {
+	*r.bulks = append(*r.bulks, len(data))
+	if len(*r.bulks) == 1 {
+		return nil, errors.New("request entity too large")
+	}
+	return r.ElasticRepository.StoreMany(ctx, data)
+}
+
+func TestStoreManyIndexChunks(t *testing.T) {
+	elasticRepo := mem.NewElasticRepository()
+	bulks := []int{}
+	newsService := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10),
+		bulkRecordingElastic{elasticRepo, &bulks})
+
+	data := []m.News{}
+	for id := 1; id <= 250; id++ {
+		news := ListTestData()[0]
+		news.ID = id
+		data = append(data, news)
+	}
+	res, e := newsService.StoreMany(ctx, data)
+	if e != nil {
+		t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+	}
+	for _, v := range res {
+		if !v.Success {
+			t.Errorf("[ERROR] - Stored news should be successful even when it is not indexed %+v", v)
+		}
+	}
+	if len(bulks) != 3 || bulks[0] != 100 || bulks[1] != 100 || bulks[2] != 50 {
+		t.Fatalf("[ERROR] - Expected bulks of 100, 100 and 50 news but got %v", bulks)
+	}
+	// the failed first bulk does not stop the next ones
+	indexed, e := elasticRepo.GetBy(ctx, m.GetPayload{Limit: 1000})
+	if e != nil || len(indexed) != 150 {
+		t.Fatalf("[ERROR] - Expected 150 indexed news but got %d %v", len(indexed), e)
+	}
+	for _, v := range indexed {
+		if v.ID <= 100 {
+			t.Errorf("[ERROR] - News %d of the failed bulk should not be indexed", v.ID)
+		}
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	newsService := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10),
+		nil)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	data := ListTestData()[0]
+	if e := newsService.Store(cancelled, &data); errors.Cause(e) != context.Canceled {
+		t.Errorf("[ERROR] - It should be error '%s' %v", context.Canceled.Error(), e)
+	}
+	if _, e := newsService.GetById(ctx, data.ID); errors.Cause(e) != helper.ErrDataNotFound {
+		t.Errorf("[ERROR] - It should be error '%s' %v", helper.ErrDataNotFound.Error(), e)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	newsService := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10),
+		mem.NewElasticRepository())
+	data := append(ListTestData(), m.News{
+		ID:      4,
+		Author:  "Alex",
+		Body:    "Alex writes about Bacca",
+		Created: time.Now().UTC().Add(time.Second * 7),
+	})
+	for _, v := range data {
+		_data := v
+		if e := newsService.Store(ctx, &_data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+
+	tts := []struct {
+		name     string
+		payload  m.SearchPayload
+		total    int64
+		expected []int
+	}{
+		{"Case: Match Author Before Body", m.SearchPayload{Query: "alex"}, 2, []int{4, 1}},
+		{"Case: Equal Score Latest First", m.SearchPayload{Query: "Bacca"}, 2, []int{4, 2}},
+		{"Case: Paginated", m.SearchPayload{Query: "hello", Offset: 1, Limit: 1}, 3, []int{2}},
+		{"Case: No Match", m.SearchPayload{Query: "weather"}, 0, []int{}},
+	}
+	for _, tt := range tts {
+		t.Run(tt.name, func(t *testing.T) {
+			res, e := newsService.Search(ctx, tt.payload)
+			if e != nil {
+				t.Fatalf("[ERROR] - Failed to search %s", e.Error())
+			}
+			if res.Total != tt.total || len(res.Hits) != len(tt.expected) {
+				t.Fatalf("[ERROR] - Expected %d of %d hits but got %d of %d", len(tt.expected), tt.total, len(res.Hits), res.Total)
+			}
+			for i, id := range tt.expected {
+				if res.Hits[i].News.ID != id || res.Hits[i].News.Body == "" || res.Hits[i].Score <= 0 {
+					t.Errorf("[ERROR] - Expected news %d at %d but got %+v", id, i, res.Hits[i])
+				}
+			}
+		})
+	}
+
+	t.Run("Case: Highlight With Default Tags", func(t *testing.T) {
+		res, e := newsService.Search(ctx, m.SearchPayload{Query: "bacca", Limit: 1})
+		if e != nil || len(res.Hits) != 1 {
+			t.Fatalf("[ERROR] - Failed to search %+v %v", res, e)
+		}
+		if body := res.Hits[0].Highlights["body"]; len(body) != 1 || body[0] != "Alex writes about <em>Bacca</em>" {
+			t.Errorf("[ERROR] - Expected highlighted body but got %q", body)
+		}
+		if _, ok := res.Hits[0].Highlights["author"]; ok {
+			t.Errorf("[ERROR] - Author without match should not be highlighted %q", res.Hits[0].Highlights)
+		}
+	})
+
+	for _, payload := range []m.SearchPayload{
+		{Query: "  "},
+		{Query: "alex", Highlight: m.Highlight{FragmentSize: -1}},
+		{Query: "alex", Highlight: m.Highlight{PreTag: "<b>"}},
+		{Query: "alex", Highlight: m.Highlight{PreTag: "<b>", PostTag: "</i>"}},
+		{Query: "alex", Highlight: m.Highlight{PreTag: `<img src=x onerror="alert(1)">`, PostTag: "</img>"}},
+	} {
+		if _, e := newsService.Search(ctx, payload); errors.Cause(e) != helper.ErrDataInvalid {
+			t.Errorf("[ERROR] - It should be error '%s' for %+v %v", helper.ErrDataInvalid.Error(), payload, e)
+		}
+	}
+	noElastic := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10), nil)
+	if _, e := noElastic.Search(ctx, m.SearchPayload{Query: "alex"}); errors.Cause(e) != helper.ErrSearchUnavailable {
+		t.Errorf("[ERROR] - It should be error '%s' %v", helper.ErrSearchUnavailable.Error(), e)
+	}
+}
+
+func TestStats(t *testing.T) {
+	newsService := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10),
+		mem.NewElasticRepository())
+	// 2020-05-24T23:30:00Z is already 2020-05-25 in Asia/Jakarta (UTC+7)
+	day := time.Date(2020, time.May, 24, 23, 30, 0, 0, time.UTC)
+	data := []m.News{
+		{ID: 1, Author: "Alex", Body: "first", Created: day},
+		{ID: 2, Author: "Bacca", Body: "second", Created: day.Add(-8 * time.Hour)},
+		{ID: 3, Author: "Alex", Body: "third", Created: day.AddDate(0, 0, 2)},
+		{ID: 4, Author: "Chicarito", Body: "fourth", Created: day.AddDate(0, 0, 2)},
+	}
+	for _, v := range data {
+		_data := v
+		if e := newsService.Store(ctx, &_data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+
+	t.Run("Case: Default Daily In UTC", func(t *testing.T) {
+		res, e := newsService.Stats(ctx, m.StatsPayload{})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to get stats %s", e.Error())
+		}
+		if res.Total != 4 || len(res.Authors) != 3 || res.Authors[0] != (m.AuthorCount{Author: "Alex", Count: 2}) {
+			t.Errorf("[ERROR] - Expected Alex with 2 of 4 news first but got %+v", res)
+		}
+		counts := []int64{2, 0, 2}
+		if len(res.Histogram) != len(counts) {
+			t.Fatalf("[ERROR] - Expected %d daily buckets but got %+v", len(counts), res.Histogram)
+		}
+		for i, v := range counts {
+			date := time.Date(2020, time.May, 24+i, 0, 0, 0, 0, time.UTC)
+			if !res.Histogram[i].Date.Equal(date) || res.Histogram[i].Count != v {
+				t.Errorf("[ERROR] - Expected %d news on %s but got %+v", v, date, res.Histogram[i])
+			}
+		}
+	})
+	t.Run("Case: Time Zone Moves Bucket", func(t *testing.T) {
+		res, e := newsService.Stats(ctx, m.StatsPayload{TimeZone: "Asia/Jakarta", Authors: 1})
+		if e != nil {
+			t.Fatalf("[ERROR] - Failed to get stats %s", e.Error())
+		}
+		if len(res.Histogram) != 4 || res.Histogram[0].Count != 1 || res.Histogram[1].Count != 1 {
+			t.Errorf("[ERROR] - Expected news 1 on the next day in Asia/Jakarta but got %+v", res.Histogram)
+		}
+		if len(res.Authors) != 1 || res.OtherAuthors != 2 {
+			t.Errorf("[ERROR] - Expected 2 news of other authors but got %+v", res)
+		}
+	})
+	t.Run("Case: Range And Interval", func(t *testing.T) {
+		res, e := newsService.Stats(ctx, m.StatsPayload{Interval: "month", From: day, To: day.AddDate(0, 0, 1)})
+		if e != nil || res.Total != 1 || len(res.Histogram) != 1 || res.Histogram[0].Count != 1 {
+			t.Errorf("[ERROR] - Expected only news 1 in May but got %+v %v", res, e)
+		}
+	})
+	t.Run("Case: Negative Test", func(t *testing.T) {
+		for _, payload := range []m.StatsPayload{
+			{Interval: "fortnight"},
+			{TimeZone: "Mars/Olympus"},
+			{Authors: -1},
+			{From: day, To: day},
+			{Authors: m.MaxStatsBuckets + 1},
+			// a week of minutes
+			{Interval: "minute", From: day, To: day.AddDate(0, 0, 7)},
+		} {
+			if _, e := newsService.Stats(ctx, payload); errors.Cause(e) != helper.ErrDataInvalid {
+				t.Errorf("[ERROR] - It should be error '%s' for %+v %v", helper.ErrDataInvalid.Error(), payload, e)
+			}
+		}
+		noElastic := logic.NewNewsService(mem.NewNewsRepository(), mem.NewCacheRepository(10), nil)
+		if _, e := noElastic.Stats(ctx, m.StatsPayload{}); errors.Cause(e) != helper.ErrSearchUnavailable {
+			t.Errorf("[ERROR] - It should be error '%s' %v", helper.ErrSearchUnavailable.Error(), e)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package logic
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	svc "github.com/rinosukmandityo/maknews/services"
+
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// defaultBatchSize is the number of outbox messages published in one kafka batch
+	defaultBatchSize = 100
+	// defaultLease is how long claimed messages are hidden from the relays of other replicas
+	defaultLease = 30 * time.Second
+)
+
+type outboxService struct {
+	outboxRepo repo.OutboxRepository
+	kafkaRepo  repo.KafkaRepository
+	batchSize  int
+	lease      time.Duration
+}
+
+// NewOutboxService relays batchSize messages at once, a batch is claimed for lease so every replica can run the relay
+func NewOutboxService(outboxRepo repo.OutboxRepository, kafkaRepo repo.KafkaRepository, batchSize int, lease time.Duration) svc.OutboxService {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if lease <= 0 {
+		lease = defaultLease
+	}
+	return &outboxService{
+		outboxRepo,
+		kafkaRepo,
+		batchSize,
+		lease,
+	}
+}
+
+// Relay marks messages sent only after kafka accepts them, so a message is published at least once.
+// When marking fails the batch is published again once its lease passes and the consumer skips events that are already applied.
+// A message that can not be decoded is logged and marked sent without publishing, it would fail the same way on every claim.
+func (u *outboxService) Relay(ctx context.Context) (int, error) {
+	pending, e := u.outboxRepo.Claim(ctx, u.batchSize, u.lease)
+	if e != nil {
+		return 0, errs.Wrap(e, "service.Outbox.Relay")
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	events := make([]m.Event, 0, len(pending))
+	ids := make([]int64, len(pending))
+	for i, v := range pending {
+		ids[i] = v.ID
+		// outbox written before the event envelope carries plain news, it is published as created event
+		event, e := m.DecodeEvent(v.Payload)
+		if e != nil {
+			log.Printf("service.Outbox.Relay dropped outbox %d of news %d: %s", v.ID, v.NewsID, e.Error())
+			continue
+		}
+		events = append(events, *event)
+	}
+	if len(events) > 0 {
+		if e := u.kafkaRepo.WriteEvents(ctx, events); e != nil {
+			return 0, errs.Wrap(e, "service.Outbox.Relay")
+		}
+	}
+	if e := u.outboxRepo.MarkSent(ctx, ids); e != nil {
+		return 0, errs.Wrap(e, "service.Outbox.Relay")
+	}
+	return len(events), nil
+}
+
+func (u *outboxService) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// keep relaying while there are full batches waiting, a batch that has started
+		// is finished even when ctx is cancelled meanwhile
+		for ctx.Err() == nil {
+			n, e := u.Relay(helper.Detach(ctx))
+			if e != nil {
+				log.Println(e.Error())
+				break
+			}
+			if n < u.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package logic_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rinosukmandityo/maknews/helper"
+	m "github.com/rinosukmandityo/maknews/models"
+	repo "github.com/rinosukmandityo/maknews/repositories"
+	mem "github.com/rinosukmandityo/maknews/repositories/memory"
+	"github.com/rinosukmandityo/maknews/services/logic"
+
+	"github.com/pkg/errors"
+)
+
+func TestOutboxRelay(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	kafkaRepo := mem.NewKafkaConnection()
+	newsService := logic.NewNewsService(newsRepo, mem.NewCacheRepository(10), nil)
+	outboxService := logic.NewOutboxService(mem.NewOutboxRepository(newsRepo), kafkaRepo, 2, time.Minute)
+
+	for _, data := range ListTestData() {
+		_data := data
+		if e := newsService.Store(ctx, &_data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+	duplicate := ListTestData()[0]
+	if e := newsService.Store(ctx, &duplicate); errors.Cause(e) != helper.ErrDataExists {
+		t.Fatalf("[ERROR] - It should be error '%s' %v", helper.ErrDataExists.Error(), e)
+	}
+
+	for _, expected := range []int{2, 1, 0} {
+		n, e := outboxService.Relay(ctx)
+		if e != nil || n != expected {
+			t.Errorf("[ERROR] - Expected %d published message but got %d %v", expected, n, e)
+		}
+	}
+
+	dataChan := make(chan []byte, 10)
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go kafkaRepo.ReadMessage(readCtx, func(ctx context.Context, msg m.Message) error {
+		dataChan <- msg.Value
+		return nil
+	})
+	for _, expected := range ListTestData() {
+		select {
+		case msg := <-dataChan:
+			event, e := m.DecodeEvent(msg)
+			if e != nil || event.Type != m.NewsCreated || event.Payload.ID != expected.ID {
+				t.Errorf("[ERROR] - Expected created event of news %d but got %s %v", expected.ID, msg, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("[ERROR] - Message was not delivered")
+		}
+	}
+	select {
+	case msg := <-dataChan:
+		t.Errorf("[ERROR] - Unexpected message %s", msg)
+	default:
+	}
+}
+
+// unavailableKafka fails every write like kafka during an outage
+type unavailableKafka struct {
+	repo.KafkaRepository
+}
+
+func (unavailableKafka) WriteEvents(ctx context.Context, events []m.Event) error {
+	return errors.New("no available broker")
+}
+
+func TestOutboxRelayReplicas(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	kafkaRepo := mem.NewKafkaConnection()
+	outboxRepo := mem.NewOutboxRepository(newsRepo)
+	for _, data := range ListTestData() {
+		_data := data
+		if e := newsRepo.Store(ctx, &_data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+
+	// a replica that crashed long ago claimed every message without publishing them
+	crashed := logic.NewOutboxService(outboxRepo, unavailableKafka{kafkaRepo}, 10, time.Nanosecond)
+	if _, e := crashed.Relay(ctx); e == nil {
+		t.Fatalf("[ERROR] - Relay should fail when kafka is unavailable")
+	}
+	time.Sleep(time.Millisecond)
+
+	// its lease has passed, so the messages are claimed again
+	failing := logic.NewOutboxService(outboxRepo, unavailableKafka{kafkaRepo}, 10, time.Minute)
+	if _, e := failing.Relay(ctx); e == nil {
+		t.Fatalf("[ERROR] - Expired claim should be claimed again")
+	}
+	replica := logic.NewOutboxService(outboxRepo, kafkaRepo, 10, time.Minute)
+	if n, e := replica.Relay(ctx); e != nil || n != 0 {
+		t.Errorf("[ERROR] - Claimed messages should be skipped by other replicas, got %d %v", n, e)
+	}
+}
+
+// corruptOutbox claims the messages of the wrapped repository and replaces the payload of news corrupt
+type corruptOutbox struct {
+	repo.OutboxRepository
+	corrupt int
+}
+
+func (r corruptOutbox) Claim(ctx context.Context, n int, lease time.Duration) ([]m.Outbox, error) {
+	res, e := r.OutboxRepository.Claim(ctx, n, lease)
+	for i := range res {
+		if res[i].NewsID == r.corrupt {
+			res[i].Payload = []byte("{not json")
+		}
+	}
+	return res, e
+}
+
+func TestOutboxRelayCorruptPayload(t *testing.T) {
+	newsRepo := mem.NewNewsRepository()
+	kafkaRepo := mem.NewKafkaConnection()
+	outboxRepo := mem.NewOutboxRepository(newsRepo)
+	data := ListTestData()
+	for _, v := range data {
+		_data := v
+		if e := newsRepo.Store(ctx, &_data); e != nil {
+			t.Fatalf("[ERROR] - Failed to save data %s", e.Error())
+		}
+	}
+
+	relay := logic.NewOutboxService(corruptOutbox{outboxRepo, data[1].ID}, kafkaRepo, 10, time.Minute)
+	if n, e := relay.Relay(ctx); e != nil || n != len(data)-1 {
+		t.Fatalf("[ERROR] - Expected %d published message but got %d %v", len(data)-1, n, e)
+	}
+	// the corrupt message is done too, it does not come back once its lease passes
+	if res, e := outboxRepo.Claim(ctx, 10, -time.Minute); e != nil || len(res) != 0 {
+		t.Errorf("[ERROR] - Expected empty outbox but got %v %v", res, e)
+	}
+
+	dataChan := make(chan []byte, 10)
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go kafkaRepo.ReadMessage(readCtx, func(ctx context.Context, msg m.Message) error {
+		dataChan <- msg.Value
+		return nil
+	})
+	for _, expected := range []m.News{data[0], data[2]} {
+		select {
+		case msg := <-dataChan:
+			event, e := m.DecodeEvent(msg)
+			if e != nil || event.Payload.ID != expected.ID {
+				t.Errorf("[ERROR] - Expected event of news %d but got %s %v", expected.ID, msg, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("[ERROR] - Message was not delivered")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package logic
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	m "github.com/rinosukmandityo/maknews/models"
+)
+
+// workerQueue is the number of messages waiting for one worker, ReadMessage fetches
+// batches of workers * workerQueue messages so every batch fits in the queues
+const workerQueue = 16
+
+// defaultWorkers is used when the number of workers is not set
+const defaultWorkers = 4
+
+// workerPool handles messages with a fixed number of goroutines. Messages with the same key
+// always go to the same worker, so the changes of one news are applied in their order.
+type workerPool struct {
+	queues []chan job
+	wg     sync.WaitGroup
+}
+
+type job struct {
+	ctx    context.Context
+	msg    m.Message
+	batch  *batch
+	index  int
+	worker int
+}
+
+type batch struct {
+	wg   sync.WaitGroup
+	errs []error
+	// failed is written only by its own worker, after a failure the rest of the batch of that worker
+	// is skipped so a later change of the same news is never applied before an earlier one
+	failed []bool
+}
+
+func newWorkerPool(workers int, handle func(ctx context.Context, msg m.Message) error) *workerPool {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	p := &workerPool{
+		queues: make([]chan job, workers),
+	}
+	p.wg.Add(workers)
+	for i := range p.queues {
+		p.queues[i] = make(chan job, workerQueue)
+		go p.work(p.queues[i], handle)
+	}
+	return p
+}
+
+func (p *workerPool) work(queue chan job, handle func(ctx context.Context, msg m.Message) error) {
+	defer p.wg.Done()
+	for j := range queue {
+		if !j.batch.failed[j.worker] {
+			if e := handle(j.ctx, j.msg); e != nil {
+				j.batch.errs[j.index] = e
+				j.batch.failed[j.worker] = true
+			}
+		}
+		j.batch.wg.Done()
+	}
+}
+
+// BatchSize is the number of messages that fills every queue
+func (p *workerPool) BatchSize() int {
+	return len(p.queues) * workerQueue
+}
+
+func (p *workerPool) worker(key []byte) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// Run hands msgs to the workers and waits until every message is handled, it returns the first error in the order of msgs.
+// Sending blocks while the queue of a worker is full, so the reader never fetches more than the workers can take.
+func (p *workerPool) Run(ctx context.Context, msgs []m.Message) error {
+	b := &batch{
+		errs:   make([]error, len(msgs)),
+		failed: make([]bool, len(p.queues)),
+	}
+	b.wg.Add(len(msgs))
+	for i, msg := range msgs {
+		w := p.worker(msg.Key)
+		p.queues[w] <- job{ctx: ctx, msg: msg, batch: b, index: i, worker: w}
+	}
+	b.wg.Wait()
+
+	for _, e := range b.errs {
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// Stop waits for the workers to finish the queued messages
+func (p *workerPool) Stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"context"
+
+	m "github.com/rinosukmandityo/maknews/models"
+)
+
+// MaintenanceService backs the operational commands, with dryRun nothing is written and the result tells what would be
+type MaintenanceService interface {
+	// Reindex indexes every news of persistence database into a new elasticsearch index, batchSize news at a time,
+	// then swaps the alias to it and indexes the news changed meanwhile again. With deleteOld the indices
+	// the alias pointed to before are deleted.
+	Reindex(ctx context.Context, batchSize int, deleteOld, dryRun bool) (*m.ReindexResult, error)
+	// WarmCache caches the latest limit news the way GetData does and returns the number of cached news
+	WarmCache(ctx context.Context, limit int, dryRun bool) (int, error)
+	// Seed stores data like StoreMany, with dryRun every news is only validated
+	Seed(ctx context.Context, data []m.News, dryRun bool) ([]m.BulkResult, error)
+}
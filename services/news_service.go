@@ -1,13 +1,22 @@
 package services
 
 import (
+	"context"
+
 	m "github.com/rinosukmandityo/maknews/models"
 )
 
 type NewsService interface {
-	GetData(payload m.GetPayload) ([]m.News, error)
-	GetById(id int) (*m.News, error)
-	Store(data *m.News) error
-	Update(data map[string]interface{}, id int) (*m.News, error)
-	Delete(data m.News) error
+	GetData(ctx context.Context, payload m.GetPayload) ([]m.News, error)
+	GetById(ctx context.Context, id int) (*m.News, error)
+	Store(ctx context.Context, data *m.News) error
+	// StoreMany reports every news, the report is also returned with the error of a batch stopped after some news are stored
+	StoreMany(ctx context.Context, data []m.News) ([]m.BulkResult, error)
+	Update(ctx context.Context, data map[string]interface{}, id int) (*m.News, error)
+	Delete(ctx context.Context, data m.News) error
+	// Search returns one page of news matching payload.Query ordered by relevance together with their scores
+	// and highlighted fragments
+	Search(ctx context.Context, payload m.SearchPayload) (*m.SearchResult, error)
+	// Stats counts the news of payload range by author and by created date, it needs elasticsearch like Search
+	Stats(ctx context.Context, payload m.StatsPayload) (*m.NewsStats, error)
 }
@@ -3,12 +3,14 @@
 package services_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/rinosukmandityo/maknews/config"
 	"github.com/rinosukmandityo/maknews/helper"
 	m "github.com/rinosukmandityo/maknews/models"
 	rh "github.com/rinosukmandityo/maknews/repositories/helper"
@@ -28,20 +30,21 @@ import (
 	=======
 	MySQL
 	=======
-	set url=root:Password.1@tcp(127.0.0.1:3306)/news
-	set timeout=10
-	set db=news
-	set driver=mysql
+	set MAKNEWS_DATABASE_URL=root:Password.1@tcp(127.0.0.1:3306)/news
+	set MAKNEWS_DATABASE_TIMEOUT=10s
+	set MAKNEWS_DATABASE_NAME=news
+	set MAKNEWS_DATABASE_DRIVER=mysql
 	=======
 	MongoDB
 	=======
-	set url=mongodb://localhost:27017/local
-	set timeout=10
-	set db=local
-	set driver=mongo
+	set MAKNEWS_DATABASE_URL=mongodb://localhost:27017/local
+	set MAKNEWS_DATABASE_TIMEOUT=10s
+	set MAKNEWS_DATABASE_NAME=news
+	set MAKNEWS_DATABASE_DRIVER=mongo
 */
 
 var (
+	ctx         = context.Background()
 	newsService NewsService
 )
 
@@ -75,11 +78,23 @@ func ListTestData() []m.News {
 }
 
 func init() {
-	repo := rh.ChooseRepo()
-	cacheRepo := rh.RedisRepo()
-	elasticRepo := rh.ElasticRepo()
-	kafkaRepo := rh.KafkaConnection()
-	newsService = logic.NewNewsService(repo, cacheRepo, elasticRepo, kafkaRepo)
+	cfg, e := config.Load("")
+	if e != nil {
+		panic(e)
+	}
+	repo, e := rh.ChooseRepo(cfg)
+	if e != nil {
+		panic(e)
+	}
+	cacheRepo, e := rh.RedisRepo(cfg)
+	if e != nil {
+		panic(e)
+	}
+	elasticRepo, e := rh.ElasticRepo(cfg)
+	if e != nil {
+		panic(e)
+	}
+	newsService = logic.NewNewsService(repo, cacheRepo, elasticRepo)
 }
 
 func TestNewsService(t *testing.T) {
@@ -103,17 +118,17 @@ func InsertData(t *testing.T) {
 
 	// Clean test data if any
 	for _, _data := range ListTestData() {
-		newsService.Delete(_data)
+		newsService.Delete(ctx, _data)
 	}
 	time.Sleep(time.Second * 1)
 
 	for _, tt := range tts {
 		for _, _data := range tt.data {
 			t.Run(tt.name, func(t *testing.T) {
-				if e := newsService.Store(&_data); e != tt.expectedErr {
+				if e := newsService.Store(ctx, &_data); e != tt.expectedErr {
 					t.Errorf("%s %s ", tt.errMsg, e.Error())
 				}
-				res, e := newsService.GetById(_data.ID)
+				res, e := newsService.GetById(ctx, _data.ID)
 				if e != nil || res.ID == 0 {
 					t.Errorf("[ERROR] - Failed to get data")
 				}
@@ -151,7 +166,7 @@ func UpdateData(t *testing.T) {
 		testdata := tt.data
 		for i, _data := range testdata {
 			t.Run(tt.name, func(t *testing.T) {
-				if _, e := newsService.Update(tt.updatedData[i], _data.ID); e != tt.expectedErr {
+				if _, e := newsService.Update(ctx, tt.updatedData[i], _data.ID); e != tt.expectedErr {
 					if !strings.Contains(e.Error(), tt.expectedErr.Error()) {
 						t.Errorf("%s %s ", tt.errMsg, e.Error())
 					}
@@ -183,7 +198,7 @@ func DeleteData(t *testing.T) {
 		testdata := tt.data
 		for _, data := range testdata {
 			t.Run(tt.name, func(t *testing.T) {
-				if e := newsService.Delete(data); e != tt.expectedErr {
+				if e := newsService.Delete(ctx, data); e != tt.expectedErr {
 					if !strings.Contains(e.Error(), tt.expectedErr.Error()) {
 						t.Errorf("%s %s", tt.errMsg, e.Error())
 					}
@@ -214,7 +229,7 @@ func GetDataById(t *testing.T) {
 	for _, tt := range tts {
 		for _, filter := range tt.filter {
 			t.Run(tt.name, func(t *testing.T) {
-				if _, e := newsService.GetById(filter["id"].(int)); e != tt.expectedErr {
+				if _, e := newsService.GetById(ctx, filter["id"].(int)); e != tt.expectedErr {
 					if !strings.Contains(e.Error(), tt.expectedErr.Error()) {
 						t.Errorf("%s %s ", tt.errMsg, e.Error())
 					}
@@ -249,7 +264,7 @@ func GetData(t *testing.T) {
 					Offset: filter["offset"].(int),
 					Limit:  filter["limit"].(int),
 				}
-				if _, e := newsService.GetData(payload); e != tt.expectedErr {
+				if _, e := newsService.GetData(ctx, payload); e != tt.expectedErr {
 					if !strings.Contains(e.Error(), tt.expectedErr.Error()) {
 						t.Errorf("%s %s ", tt.errMsg, e.Error())
 					}
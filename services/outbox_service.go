@@ -0,0 +1,13 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+type OutboxService interface {
+	// Relay publishes one batch of pending outbox messages to kafka and returns how many were published
+	Relay(ctx context.Context) (int, error)
+	// Run relays every interval until ctx is cancelled
+	Run(ctx context.Context, interval time.Duration) error
+}